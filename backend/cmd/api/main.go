@@ -10,7 +10,10 @@ import (
 	"skillswap/backend/internal/handlers/chat"
 	"skillswap/backend/internal/config"
 	"skillswap/backend/internal/handlers/courses"
+	"skillswap/backend/internal/handlers/matches"
+	"skillswap/backend/internal/handlers/reviews"
 	"skillswap/backend/internal/handlers/skills"
+	"skillswap/backend/internal/handlers/swaps"
 	"skillswap/backend/internal/handlers/users"
 	"skillswap/backend/internal/handlers/video"
 	"skillswap/backend/internal/database"
@@ -35,6 +38,15 @@ func main() {
 	// Start the WebSocket hub for chat functionality
 	go chat.StartHub()
 
+	// Expire swap requests teachers did not answer in time
+	go swaps.RunExpiry()
+
+	// Delete expired and revoked sessions
+	go auth.RunSessionCleanup()
+
+	// Store call quality reports sent over video signaling sockets
+	go video.RunQualityWriter()
+
 	// Izveido jaunu rūteri ar stingru pārbaudi slīpsvītrām, kas nozīmē, ka maršruti ar un bez beigu slīpsvītras tiek uzskatīti par atšķirīgiem.
 	server := mux.NewRouter().StrictSlash(true)
 
@@ -46,9 +58,16 @@ func main() {
 
 	// Public routes (no authentication required)
 	server.HandleFunc("/api/login", middleware.LoginRateLimit(auth.Login)).Methods("POST")
+	server.HandleFunc("/api/login/2fa", middleware.LoginRateLimit(auth.LoginSecondFactor)).Methods("POST")
 	server.HandleFunc("/api/register", middleware.LoginRateLimit(auth.Register)).Methods("POST")
 	server.HandleFunc("/api/logout", auth.Logout).Methods("POST")
+	server.HandleFunc("/api/password/forgot", auth.ForgotPassword).Methods("POST")
+	server.HandleFunc("/api/email/verify", auth.ConfirmEmail).Methods("POST")
+	server.HandleFunc("/api/password/reset", middleware.LoginRateLimit(auth.ResetPassword)).Methods("POST")
 	server.HandleFunc("/api/cookieUser", auth.CheckSession).Methods("GET")
+	server.HandleFunc("/api/oidc/providers", auth.GetOIDCProviders).Methods("GET")
+	server.HandleFunc("/api/oidc/{provider}/login", middleware.LoginRateLimit(auth.OIDCLogin)).Methods("GET")
+	server.HandleFunc("/api/oidc/{provider}/callback", middleware.LoginRateLimit(auth.OIDCCallback)).Methods("GET")
 	
 	// Public search and user info routes
 	server.HandleFunc("/api/search", database.Search).Methods("POST")
@@ -65,19 +84,60 @@ func main() {
 	server.HandleFunc("/api/course/video", courses.ServeModuleVideo).Methods("GET")
 	server.HandleFunc("/api/course/{id}/stream", courses.StreamCourseAsset).Methods("GET")
 	server.HandleFunc("/api/getSkills", skills.GetSkills).Methods("GET")
+	server.HandleFunc("/api/skills/endorsements", skills.GetEndorsements).Methods("GET")
+	server.HandleFunc("/api/skills/verifications", skills.GetVerifications).Methods("GET")
+	server.HandleFunc("/api/reviews", reviews.GetUserReviews).Methods("GET")
 	
-	// Protected routes (authentication required)
-	server.HandleFunc("/api/updateUser", middleware.AuthMiddleware(users.UpdateUser)).Methods("POST")
-	server.HandleFunc("/api/profile/picture", middleware.AuthMiddleware(users.UploadProfilePicture)).Methods("POST")
+	// Protected routes (authentication required). Routes that name scopes also accept
+	// API tokens with those scopes; the others need a logged-in session.
+	server.HandleFunc("/api/sessions", middleware.AuthMiddleware(auth.GetSessions)).Methods("GET")
+	server.HandleFunc("/api/sessions/revoke", middleware.AuthMiddleware(auth.RevokeSession)).Methods("POST")
+	server.HandleFunc("/api/sessions/revoke-others", middleware.AuthMiddleware(auth.RevokeOtherSessions)).Methods("POST")
+	server.HandleFunc("/api/email/resend", middleware.AuthMiddleware(auth.ResendVerification)).Methods("POST")
+	server.HandleFunc("/api/2fa/status", middleware.AuthMiddleware(auth.GetTwoFactorStatus)).Methods("GET")
+	server.HandleFunc("/api/2fa/setup", middleware.AuthMiddleware(auth.SetupTwoFactor)).Methods("POST")
+	server.HandleFunc("/api/2fa/confirm", middleware.AuthMiddleware(auth.ConfirmTwoFactor)).Methods("POST")
+	server.HandleFunc("/api/2fa/disable", middleware.AuthMiddleware(auth.DisableTwoFactor)).Methods("POST")
+	server.HandleFunc("/api/2fa/recovery-codes", middleware.AuthMiddleware(auth.RegenerateRecoveryCodes)).Methods("POST")
+	server.HandleFunc("/api/oidc/{provider}/link", middleware.AuthMiddleware(auth.LinkOIDCProvider)).Methods("POST")
+	server.HandleFunc("/api/identities", middleware.AuthMiddleware(auth.GetIdentities)).Methods("GET")
+	server.HandleFunc("/api/identities/unlink", middleware.AuthMiddleware(auth.UnlinkIdentityRequest)).Methods("POST")
+	server.HandleFunc("/api/tokens", middleware.AuthMiddleware(auth.GetAPITokens)).Methods("GET")
+	server.HandleFunc("/api/tokens", middleware.AuthMiddleware(auth.CreateAPITokenRequest)).Methods("POST")
+	server.HandleFunc("/api/tokens/revoke", middleware.AuthMiddleware(auth.RevokeAPITokenRequest)).Methods("POST")
+	server.HandleFunc("/api/updateUser", middleware.AuthMiddleware(users.UpdateUser, auth.ScopeProfileWrite)).Methods("POST")
+	server.HandleFunc("/api/profile/picture", middleware.AuthMiddleware(users.UploadProfilePicture, auth.ScopeProfileWrite)).Methods("POST")
 	
-	server.HandleFunc("/api/chat", middleware.AuthMiddleware(chat.SimpleWebSocketEndpoint))
-	server.HandleFunc("/api/createChat", middleware.AuthMiddleware(chat.CreateChat))
-	server.HandleFunc("/api/getChats", middleware.AuthMiddleware(chat.GetChatsFromUserID))
-	server.HandleFunc("/api/getChatInfo", middleware.AuthMiddleware(chat.GetMessagesFromUID))
+	server.HandleFunc("/api/chat", middleware.AuthMiddleware(chat.SimpleWebSocketEndpoint, auth.ScopeChatRead, auth.ScopeChatWrite))
+	server.HandleFunc("/api/createChat", middleware.AuthMiddleware(chat.CreateChat, auth.ScopeChatWrite))
+	server.HandleFunc("/api/getChats", middleware.AuthMiddleware(chat.GetChatsFromUserID, auth.ScopeChatRead))
+	server.HandleFunc("/api/getChatInfo", middleware.AuthMiddleware(chat.GetMessagesFromUID, auth.ScopeChatRead))
 	server.HandleFunc("/api/video", middleware.AuthMiddleware(video.HandleWebSocket)).Methods("GET")
+	server.HandleFunc("/api/video/sessions", middleware.AuthMiddleware(video.GetCallSessions)).Methods("GET")
+	server.HandleFunc("/api/video/whiteboard", middleware.AuthMiddleware(video.ExportWhiteboard)).Methods("GET")
+	server.HandleFunc("/api/video/stats", middleware.AuthMiddleware(video.SubmitCallQuality)).Methods("POST")
 	
-	server.HandleFunc("/api/course/add", middleware.AuthMiddleware(courses.AddCourse)).Methods("POST")
-	server.HandleFunc("/api/course/upload", middleware.AuthMiddleware(courses.UploadCourseAsset)).Methods("POST")
+	server.HandleFunc("/api/swaps/requests", middleware.AuthMiddleware(swaps.GetSwapRequests, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/swaps/requests", middleware.VerifiedMiddleware(swaps.CreateSwapRequest, auth.ScopeSwapsWrite)).Methods("POST")
+	server.HandleFunc("/api/swaps/request", middleware.AuthMiddleware(swaps.GetSwapRequestByID, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/swaps/request/accept", middleware.VerifiedMiddleware(swaps.AcceptSwapRequest, auth.ScopeSwapsWrite)).Methods("POST")
+	server.HandleFunc("/api/swaps/request/decline", middleware.AuthMiddleware(swaps.DeclineSwapRequest, auth.ScopeSwapsWrite)).Methods("POST")
+	server.HandleFunc("/api/swaps/request/cancel", middleware.AuthMiddleware(swaps.CancelSwapRequest, auth.ScopeSwapsWrite)).Methods("POST")
+	server.HandleFunc("/api/swaps/request/complete", middleware.AuthMiddleware(swaps.CompleteSwapRequest, auth.ScopeSwapsWrite)).Methods("POST")
+	server.HandleFunc("/api/swaps/request/dispute", middleware.AuthMiddleware(swaps.DisputeSwapRequest, auth.ScopeSwapsWrite)).Methods("POST")
+	server.HandleFunc("/api/swaps/history", middleware.AuthMiddleware(swaps.GetSwapHistory, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/swaps/history/export", middleware.AuthMiddleware(swaps.ExportSwapHistory, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/swaps/history/summary", middleware.AuthMiddleware(swaps.GetSwapSummary, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/matches", middleware.AuthMiddleware(matches.GetMatches, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/skills/endorse", middleware.AuthMiddleware(skills.EndorseSkill, auth.ScopeSkillsWrite)).Methods("POST")
+	server.HandleFunc("/api/skills/verification/request", middleware.AuthMiddleware(skills.RequestSkillVerification, auth.ScopeSkillsWrite)).Methods("POST")
+	server.HandleFunc("/api/skills/verification/course", middleware.AuthMiddleware(skills.VerifySkillByCourse, auth.ScopeSkillsWrite)).Methods("POST")
+	server.HandleFunc("/api/reviews", middleware.AuthMiddleware(reviews.SubmitReview, auth.ScopeReviewsWrite)).Methods("POST")
+	server.HandleFunc("/api/reviews/swap", middleware.AuthMiddleware(reviews.GetSwapReviews, auth.ScopeSwapsRead)).Methods("GET")
+	server.HandleFunc("/api/reviews/report", middleware.AuthMiddleware(reviews.ReportReviewRequest, auth.ScopeReviewsWrite)).Methods("POST")
+
+	server.HandleFunc("/api/course/add", middleware.VerifiedMiddleware(courses.AddCourse, auth.ScopeCoursesWrite)).Methods("POST")
+	server.HandleFunc("/api/course/upload", middleware.VerifiedMiddleware(courses.UploadCourseAsset, auth.ScopeCoursesWrite)).Methods("POST")
 
 	// Admin routes (admin authentication required)
 	server.HandleFunc("/api/admin/stats", middleware.AdminMiddleware(admin.GetAllStats)).Methods("GET")
@@ -85,13 +145,24 @@ func main() {
 	server.HandleFunc("/api/admin/user/toggle-admin", middleware.AdminMiddleware(admin.ToggleUserAdmin)).Methods("POST")
 	server.HandleFunc("/api/admin/user/delete", middleware.AdminMiddleware(admin.DeleteUser)).Methods("POST", "DELETE")
 	server.HandleFunc("/api/admin/user/swaps", middleware.AdminMiddleware(admin.UpdateUserSwaps)).Methods("POST")
+	server.HandleFunc("/api/admin/user/unlock", middleware.AdminMiddleware(admin.UnlockUser)).Methods("POST")
+	server.HandleFunc("/api/admin/swaps/grant-skill", middleware.AdminMiddleware(admin.BulkGrantSwaps)).Methods("POST")
+	server.HandleFunc("/api/admin/swaps/disputes", middleware.AdminMiddleware(admin.GetSwapDisputes)).Methods("GET")
+	server.HandleFunc("/api/admin/swaps/dispute/resolve", middleware.AdminMiddleware(admin.ResolveSwapDispute)).Methods("POST")
 	server.HandleFunc("/api/admin/courses", middleware.AdminMiddleware(admin.GetAllCourses)).Methods("GET")
 	server.HandleFunc("/api/admin/course/delete", middleware.AdminMiddleware(admin.DeleteCourse)).Methods("POST", "DELETE")
 	server.HandleFunc("/api/admin/skills", middleware.AdminMiddleware(admin.GetAllSkills)).Methods("GET")
 	server.HandleFunc("/api/admin/skill/add", middleware.AdminMiddleware(admin.AddSkill)).Methods("POST")
 	server.HandleFunc("/api/admin/skill/update", middleware.AdminMiddleware(admin.UpdateSkill)).Methods("POST", "PUT")
 	server.HandleFunc("/api/admin/skill/delete", middleware.AdminMiddleware(admin.DeleteSkill)).Methods("POST", "DELETE")
+	server.HandleFunc("/api/admin/skill-verifications", middleware.AdminMiddleware(admin.GetSkillVerificationRequests)).Methods("GET")
+	server.HandleFunc("/api/admin/skill-verification/review", middleware.AdminMiddleware(admin.ReviewSkillVerification)).Methods("POST")
+	server.HandleFunc("/api/admin/review-reports", middleware.AdminMiddleware(admin.GetReviewReports)).Methods("GET")
+	server.HandleFunc("/api/admin/review-report/resolve", middleware.AdminMiddleware(admin.ResolveReviewReport)).Methods("POST")
 	server.HandleFunc("/api/admin/health", middleware.AdminMiddleware(admin.GetSystemHealth)).Methods("GET")
+	server.HandleFunc("/api/admin/call-quality/rooms", middleware.AdminMiddleware(admin.GetCallQualityByRoom)).Methods("GET")
+	server.HandleFunc("/api/admin/call-quality/users", middleware.AdminMiddleware(admin.GetCallQualityByUser)).Methods("GET")
+	server.HandleFunc("/api/admin/call-quality/session", middleware.AdminMiddleware(admin.GetCallQualityReports)).Methods("GET")
 
 	server.PathPrefix("/uploads/").Handler(http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads/"))))
 	// Vienkārša "dummy" funkcija aizmugursistēmas (backend) darbības pārbaudei.
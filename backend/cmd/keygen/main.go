@@ -0,0 +1,47 @@
+// Command keygen generates session signing and encryption keys.
+//
+// Printed keys go at the front of SESSION_KEYS, which is newest first:
+//
+//	go run ./cmd/keygen
+//
+// With -file the new key is added to the top of a SESSION_KEY_FILE instead, which is created
+// if needed. Older keys stay below it so existing sessions keep working; remove them once the
+// longest session lifetime has passed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+)
+
+func main() {
+	file := flag.String("file", "", "add the key to the top of this session key file instead of printing it")
+	flag.Parse()
+
+	key, err := config.NewSessionKey()
+	if err != nil {
+		log.Fatalf("Failed to generate a key: %v", err)
+	}
+	if *file == "" {
+		fmt.Println(key)
+		return
+	}
+
+	existing, err := os.ReadFile(*file)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to read %s: %v", *file, err)
+	}
+	var contents strings.Builder
+	fmt.Fprintf(&contents, "# added %s\n%s\n", time.Now().UTC().Format(time.RFC3339), key)
+	contents.Write(existing)
+	if err := os.WriteFile(*file, []byte(contents.String()), 0o600); err != nil {
+		log.Fatalf("Failed to write %s: %v", *file, err)
+	}
+	fmt.Printf("Added a new session key to %s. Restart the server to start using it.\n", *file)
+}
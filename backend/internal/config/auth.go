@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// PasswordResetTTL returns how long a password reset link stays valid.
+// Configured with PASSWORD_RESET_TTL_MINUTES.
+func PasswordResetTTL() time.Duration {
+	return time.Duration(envInt("PASSWORD_RESET_TTL_MINUTES", 60)) * time.Minute
+}
+
+// PasswordResetLimits returns how many password resets may be requested per hour for
+// one email address and from one IP address. Configured with PASSWORD_RESET_EMAIL_LIMIT
+// and PASSWORD_RESET_IP_LIMIT.
+func PasswordResetLimits() (perEmail, perIP int) {
+	return envInt("PASSWORD_RESET_EMAIL_LIMIT", 3), envInt("PASSWORD_RESET_IP_LIMIT", 10)
+}
+
+// EmailVerificationTTL returns how long an email verification link stays valid.
+// Configured with EMAIL_VERIFICATION_TTL_HOURS.
+func EmailVerificationTTL() time.Duration {
+	return time.Duration(envInt("EMAIL_VERIFICATION_TTL_HOURS", 48)) * time.Hour
+}
+
+// EmailVerificationResendLimit returns how many verification emails a user may request
+// per hour. Configured with EMAIL_VERIFICATION_RESEND_LIMIT.
+func EmailVerificationResendLimit() int {
+	return envInt("EMAIL_VERIFICATION_RESEND_LIMIT", 3)
+}
+
+// Password hashers
+const (
+	PasswordHasherBcrypt   = "bcrypt"
+	PasswordHasherArgon2id = "argon2id"
+)
+
+// PasswordHasher returns the algorithm new password hashes are made with: "bcrypt" or
+// "argon2id". Configured with PASSWORD_HASHER, defaulting to bcrypt.
+func PasswordHasher() string {
+	if strings.ToLower(os.Getenv("PASSWORD_HASHER")) == PasswordHasherArgon2id {
+		return PasswordHasherArgon2id
+	}
+	return PasswordHasherBcrypt
+}
+
+// BcryptCost returns the cost of new bcrypt hashes. Configured with BCRYPT_COST.
+func BcryptCost() int {
+	return envInt("BCRYPT_COST", 12)
+}
+
+// Argon2Params returns the memory in KiB, iterations and threads of new argon2id hashes.
+// Configured with ARGON2_MEMORY_KB, ARGON2_ITERATIONS and ARGON2_THREADS.
+func Argon2Params() (memoryKB, iterations, threads int) {
+	return envInt("ARGON2_MEMORY_KB", 64*1024), envInt("ARGON2_ITERATIONS", 3), envInt("ARGON2_THREADS", 2)
+}
+
+// PasswordMinLength returns the shortest password an account may have.
+// Configured with PASSWORD_MIN_LENGTH.
+func PasswordMinLength() int {
+	return envInt("PASSWORD_MIN_LENGTH", 8)
+}
+
+// BreachedPasswordsFile returns a file of known breached passwords, one per line, refused
+// in addition to the built-in list. Configured with BREACHED_PASSWORDS_FILE.
+func BreachedPasswordsFile() string {
+	return os.Getenv("BREACHED_PASSWORDS_FILE")
+}
+
+// LoginThrottleSettings describes how failed logins slow down and lock out further attempts
+type LoginThrottleSettings struct {
+	// AccountFreeAttempts and IPFreeAttempts are how many failures are allowed without a delay
+	AccountFreeAttempts int
+	IPFreeAttempts      int
+	// BaseDelay doubles with every further failure, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold failures lock the account for LockoutDuration; zero disables lockout
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// FailureWindow is how long without failures it takes for the count to start over
+	FailureWindow time.Duration
+}
+
+// LoginThrottle returns the failed login settings. Configured with LOGIN_ACCOUNT_FREE_ATTEMPTS,
+// LOGIN_IP_FREE_ATTEMPTS, LOGIN_BASE_DELAY_SECONDS, LOGIN_MAX_DELAY_SECONDS,
+// LOGIN_LOCKOUT_THRESHOLD, LOGIN_LOCKOUT_MINUTES and LOGIN_FAILURE_WINDOW_MINUTES.
+func LoginThrottle() LoginThrottleSettings {
+	return LoginThrottleSettings{
+		AccountFreeAttempts: envInt("LOGIN_ACCOUNT_FREE_ATTEMPTS", 3),
+		IPFreeAttempts:      envInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+		BaseDelay:           time.Duration(envInt("LOGIN_BASE_DELAY_SECONDS", 1)) * time.Second,
+		MaxDelay:            time.Duration(envInt("LOGIN_MAX_DELAY_SECONDS", 300)) * time.Second,
+		LockoutThreshold:    envInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LockoutDuration:     time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+		FailureWindow:       time.Duration(envInt("LOGIN_FAILURE_WINDOW_MINUTES", 60)) * time.Minute,
+	}
+}
+
+// APITokenMaxLifetime returns the longest an API token may stay valid, and the lifetime of
+// tokens created without one. Configured with API_TOKEN_MAX_DAYS and API_TOKEN_DEFAULT_DAYS.
+func APITokenMaxLifetime() (max, def time.Duration) {
+	return time.Duration(envInt("API_TOKEN_MAX_DAYS", 365)) * 24 * time.Hour,
+		time.Duration(envInt("API_TOKEN_DEFAULT_DAYS", 30)) * 24 * time.Hour
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// Mail drivers
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+	MailDriverSMTP = "smtp"
+)
+
+// SMTPTimeout bounds connecting to the SMTP server and the whole exchange with it
+const SMTPTimeout = 30 * time.Second
+
+var (
+	ErrUnknownMailDriver   = errors.New("unknown MAIL_DRIVER, use smtp, file or log")
+	ErrLogMailInProduction = errors.New("refusing to print mail with its reset and verification links to the log in production, configure MAIL_DRIVER")
+)
+
+// MailDriver returns how outgoing mail is delivered: "smtp", "file" (written to MailDir)
+// or "log" (printed to the server log). Configured with MAIL_DRIVER, defaulting to log.
+// The log driver prints live tokens, so ENVIRONMENT=production refuses it.
+func MailDriver() (string, error) {
+	driver := strings.ToLower(strings.TrimSpace(os.Getenv("MAIL_DRIVER")))
+	switch driver {
+	case MailDriverFile, MailDriverSMTP:
+		return driver, nil
+	case "", MailDriverLog:
+		if os.Getenv("ENVIRONMENT") == "production" {
+			return "", ErrLogMailInProduction
+		}
+		return MailDriverLog, nil
+	default:
+		return "", ErrUnknownMailDriver
+	}
+}
+
+// MailFrom returns the sender address of outgoing mail. Configured with MAIL_FROM.
+func MailFrom() string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	return "SkillSwap <no-reply@skillswap.local>"
+}
+
+// MailDir returns the directory the file mail driver writes messages to. Configured with MAIL_DIR.
+func MailDir() string {
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return dir
+	}
+	return "mail"
+}
+
+// SMTPSettings describes the server the smtp mail driver sends through
+type SMTPSettings struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// SMTP returns the SMTP server settings. Configured with SMTP_HOST, SMTP_PORT, SMTP_USERNAME
+// and SMTP_PASSWORD; without credentials mail is sent unauthenticated, as to a local stand-in.
+func SMTP() SMTPSettings {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	return SMTPSettings{
+		Host:     host,
+		Port:     envInt("SMTP_PORT", 1025),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+// AppURL returns the base URL of the frontend, used for links in mail. Configured with APP_URL.
+func AppURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
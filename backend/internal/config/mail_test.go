@@ -0,0 +1,28 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMailDriver(t *testing.T) {
+	tests := []struct {
+		env, driver, want string
+		err               error
+	}{
+		{"", "", MailDriverLog, nil},
+		{"", "SMTP", MailDriverSMTP, nil},
+		{"", "smpt", "", ErrUnknownMailDriver},
+		{"production", "", "", ErrLogMailInProduction},
+		{"production", "log", "", ErrLogMailInProduction},
+		{"production", "smtp", MailDriverSMTP, nil},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENVIRONMENT", tt.env)
+		t.Setenv("MAIL_DRIVER", tt.driver)
+		got, err := MailDriver()
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("MailDriver() with ENVIRONMENT=%q MAIL_DRIVER=%q = %q, %v; expected %q, %v", tt.env, tt.driver, got, err, tt.want, tt.err)
+		}
+	}
+}
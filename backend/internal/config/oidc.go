@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// OIDCProviderSettings describes an OpenID Connect provider users can sign in with
+type OIDCProviderSettings struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// OIDCProviders returns the OpenID Connect providers listed in OIDC_PROVIDERS, a comma
+// separated list of names. Each provider NAME is configured with OIDC_NAME_ISSUER,
+// OIDC_NAME_CLIENT_ID, OIDC_NAME_CLIENT_SECRET and optionally OIDC_NAME_DISPLAY_NAME and
+// OIDC_NAME_SCOPES (space separated, "openid email profile" by default). Providers
+// without an issuer or client ID are skipped.
+func OIDCProviders() []OIDCProviderSettings {
+	var providers []OIDCProviderSettings
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := OIDCProviderSettings{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       strings.TrimRight(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			continue
+		}
+		if p.DisplayName == "" {
+			p.DisplayName = name
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// OIDCRedirectBaseURL returns the public URL the API is reached at, which providers redirect
+// back to. Configured with OIDC_REDIRECT_BASE_URL, defaulting to APP_URL behind the proxy.
+func OIDCRedirectBaseURL() string {
+	if url := os.Getenv("OIDC_REDIRECT_BASE_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return AppURL()
+}
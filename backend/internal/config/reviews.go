@@ -0,0 +1,9 @@
+package config
+
+import "time"
+
+// ReviewWindow returns how long after a swap is completed its parties may review each other.
+// Reviews are published once both are in or the window closes. Configured with REVIEW_WINDOW_DAYS.
+func ReviewWindow() time.Duration {
+	return time.Duration(envInt("REVIEW_WINDOW_DAYS", 14)) * 24 * time.Hour
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DevSessionKey signs sessions when no key is configured outside production
+const DevSessionKey = "skillswap-dev-session-key-change-in-production"
+
+// Session key sizes. The hash key signs cookies with HMAC-SHA256; the block key encrypts them with AES-256.
+const (
+	SessionHashKeyLength  = 64
+	SessionBlockKeyLength = 32
+)
+
+var ErrDevSessionKey = errors.New("refusing to sign sessions with the development key in production, configure SESSION_KEYS or SESSION_KEY_FILE")
+
+// SessionKeys returns the session signing and encryption key pairs, newest first, as
+// securecookie.CodecsFromPairs takes them. New cookies use the first pair and cookies made
+// with any of the others are still accepted, so keys can be rotated without logging users out.
+//
+// Pairs are configured with SESSION_KEYS, a comma separated list, or SESSION_KEY_FILE, a file
+// with one pair per line where # starts a comment. A pair is written "hash:block" with both keys
+// base64 encoded, as cmd/keygen prints it. The older single SESSION_KEY is accepted as the
+// oldest pair, a signing key without encryption. With nothing configured the development key
+// is used, which ENVIRONMENT=production refuses.
+func SessionKeys() ([][]byte, error) {
+	list := os.Getenv("SESSION_KEYS")
+	file := os.Getenv("SESSION_KEY_FILE")
+	if list != "" && file != "" {
+		return nil, errors.New("set SESSION_KEYS or SESSION_KEY_FILE, not both")
+	}
+	var lines []string
+	if list != "" {
+		lines = strings.Split(list, ",")
+	}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read session key file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line, _, _ = strings.Cut(line, "#")
+			lines = append(lines, line)
+		}
+	}
+
+	var pairs [][]byte
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		hashKey, blockKey, err := ParseSessionKey(line)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, hashKey, blockKey)
+	}
+	if legacy := os.Getenv("SESSION_KEY"); legacy != "" {
+		pairs = append(pairs, []byte(legacy), nil)
+	}
+
+	production := os.Getenv("ENVIRONMENT") == "production"
+	if len(pairs) == 0 {
+		if production {
+			return nil, ErrDevSessionKey
+		}
+		return [][]byte{[]byte(DevSessionKey), nil}, nil
+	}
+	if production {
+		for _, key := range pairs {
+			if string(key) == DevSessionKey {
+				return nil, ErrDevSessionKey
+			}
+		}
+	}
+	return pairs, nil
+}
+
+// ParseSessionKey decodes a "hash:block" key pair. The block key may be left out, in which
+// case cookies are signed but not encrypted.
+func ParseSessionKey(pair string) (hashKey, blockKey []byte, err error) {
+	hashText, blockText, hasBlock := strings.Cut(strings.TrimSpace(pair), ":")
+	hashKey, err = base64.StdEncoding.DecodeString(hashText)
+	if err != nil || len(hashKey) < 32 {
+		return nil, nil, errors.New("session hash keys must be at least 32 base64 encoded bytes")
+	}
+	if !hasBlock {
+		return hashKey, nil, nil
+	}
+	blockKey, err = base64.StdEncoding.DecodeString(blockText)
+	if err != nil || (len(blockKey) != 16 && len(blockKey) != 24 && len(blockKey) != 32) {
+		return nil, nil, errors.New("session block keys must be 16, 24 or 32 base64 encoded bytes")
+	}
+	return hashKey, blockKey, nil
+}
+
+// NewSessionKey generates a random key pair written as SessionKeys reads it
+func NewSessionKey() (string, error) {
+	hashKey := make([]byte, SessionHashKeyLength)
+	blockKey := make([]byte, SessionBlockKeyLength)
+	if _, err := rand.Read(hashKey); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(blockKey); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(hashKey) + ":" + base64.StdEncoding.EncodeToString(blockKey), nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+func TestSessionKeyRotation(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("SESSION_KEYS", "")
+	t.Setenv("SESSION_KEY_FILE", "")
+	t.Setenv("SESSION_KEY", "old-single-session-key")
+
+	old, err := SessionKeys()
+	if err != nil {
+		t.Fatalf("SessionKeys failed: %v", err)
+	}
+	cookie, err := securecookie.EncodeMulti("authentication", "token", securecookie.CodecsFromPairs(old...)...)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	newest, _ := NewSessionKey()
+	previous, _ := NewSessionKey()
+	file := filepath.Join(t.TempDir(), "session.keys")
+	os.WriteFile(file, []byte("# newest first\n"+newest+"\n"+previous+" # rotated last month\n"), 0o600)
+	t.Setenv("SESSION_KEY_FILE", file)
+	pairs, err := SessionKeys()
+	if err != nil {
+		t.Fatalf("SessionKeys failed: %v", err)
+	}
+	if len(pairs) != 6 {
+		t.Fatalf("Expected three key pairs, got %d keys", len(pairs))
+	}
+	codecs := securecookie.CodecsFromPairs(pairs...)
+	var value string
+	if err := securecookie.DecodeMulti("authentication", cookie, &value, codecs...); err != nil || value != "token" {
+		t.Errorf("Expected a cookie made with the old key to still decode, got %q (%v)", value, err)
+	}
+	fresh, _ := securecookie.EncodeMulti("authentication", "token", codecs...)
+	if err := securecookie.DecodeMulti("authentication", fresh, &value, codecs[0]); err != nil {
+		t.Errorf("Expected new cookies to use the newest key, got %v", err)
+	}
+
+	t.Setenv("SESSION_KEYS", newest)
+	if _, err := SessionKeys(); err == nil {
+		t.Error("Expected SESSION_KEYS and SESSION_KEY_FILE together to be refused")
+	}
+	t.Setenv("SESSION_KEY_FILE", "")
+	t.Setenv("SESSION_KEYS", "c2hvcnQ=")
+	if _, err := SessionKeys(); err == nil {
+		t.Error("Expected a short hash key to be refused")
+	}
+}
+
+func TestSessionKeysRefuseDevKeyInProduction(t *testing.T) {
+	t.Setenv("SESSION_KEYS", "")
+	t.Setenv("SESSION_KEY_FILE", "")
+	t.Setenv("SESSION_KEY", "")
+
+	t.Setenv("ENVIRONMENT", "development")
+	if pairs, err := SessionKeys(); err != nil || string(pairs[0]) != DevSessionKey {
+		t.Errorf("Expected the development key outside production, got %v", err)
+	}
+	t.Setenv("ENVIRONMENT", "production")
+	if _, err := SessionKeys(); !errors.Is(err, ErrDevSessionKey) {
+		t.Errorf("Expected no key to be refused in production, got %v", err)
+	}
+	t.Setenv("SESSION_KEY", DevSessionKey)
+	if _, err := SessionKeys(); !errors.Is(err, ErrDevSessionKey) {
+		t.Errorf("Expected the development key to be refused in production, got %v", err)
+	}
+	key, _ := NewSessionKey()
+	t.Setenv("SESSION_KEY", "")
+	t.Setenv("SESSION_KEYS", key)
+	if _, err := SessionKeys(); err != nil {
+		t.Errorf("Expected a generated key to be accepted in production, got %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+package config
+
+// EndorsementThreshold returns how many swap partners must endorse a skill before it
+// is verified. Configured with SKILL_ENDORSEMENT_THRESHOLD.
+func EndorsementThreshold() int {
+	threshold := envInt("SKILL_ENDORSEMENT_THRESHOLD", 3)
+	if threshold < 1 {
+		return 1
+	}
+	return threshold
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultMinCallMinutes is how long both parties must share a call before a swap is settled
+const defaultMinCallMinutes = 10
+
+// MinCallDuration returns the minimum time both swap participants must be connected
+// to the same video room before the swap is fulfilled. Configured with SWAP_MIN_CALL_MINUTES.
+func MinCallDuration() time.Duration {
+	return time.Duration(envInt("SWAP_MIN_CALL_MINUTES", defaultMinCallMinutes)) * time.Minute
+}
+
+// SignupCredits returns the number of swap credits a new account starts with.
+// Configured with SWAP_SIGNUP_CREDITS.
+func SignupCredits() int {
+	return envInt("SWAP_SIGNUP_CREDITS", 2)
+}
+
+// SwapRequestTTL returns how long a swap request may wait for the teacher's answer
+// before it expires. Configured with SWAP_REQUEST_TTL_HOURS.
+func SwapRequestTTL() time.Duration {
+	return time.Duration(envInt("SWAP_REQUEST_TTL_HOURS", 72)) * time.Hour
+}
+
+// SwapCompletionWindow returns how long after its proposed time (or acceptance, if it has none)
+// an accepted swap may stay incomplete before it expires and the learner is refunded.
+// Configured with SWAP_COMPLETION_WINDOW_HOURS.
+func SwapCompletionWindow() time.Duration {
+	return time.Duration(envInt("SWAP_COMPLETION_WINDOW_HOURS", 168)) * time.Hour
+}
+
+// MonthlyAllowance returns the number of free swap credits each user receives once per
+// calendar month, or zero when there is no allowance. Configured with SWAP_MONTHLY_ALLOWANCE.
+func MonthlyAllowance() int {
+	return envInt("SWAP_MONTHLY_ALLOWANCE", 0)
+}
+
+// MaxBalance returns the most swap credits a user may hold, or zero for no cap.
+// Configured with SWAP_MAX_BALANCE.
+func MaxBalance() int {
+	return envInt("SWAP_MAX_BALANCE", 0)
+}
+
+// PartnerCooldown returns how long two users must wait after one swap was accepted
+// before they can arrange another. Configured with SWAP_PARTNER_COOLDOWN_HOURS.
+func PartnerCooldown() time.Duration {
+	return time.Duration(envInt("SWAP_PARTNER_COOLDOWN_HOURS", 0)) * time.Hour
+}
+
+// envInt reads a non-negative integer environment variable, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// VideoSFUEnabled reports whether new video rooms default to SFU mode, where media is
+// forwarded through the server instead of a peer-to-peer mesh. Configured with VIDEO_MODE=sfu.
+func VideoSFUEnabled() bool {
+	return strings.EqualFold(os.Getenv("VIDEO_MODE"), "sfu")
+}
+
+// SFUPublicIPs returns the public addresses the SFU advertises as host candidates when it
+// runs behind 1:1 NAT (e.g. inside a container). Configured as a comma-separated SFU_PUBLIC_IP.
+func SFUPublicIPs() []string {
+	var ips []string
+	for _, ip := range strings.Split(os.Getenv("SFU_PUBLIC_IP"), ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// SFUPortRange returns the UDP port range the SFU may use for media, or zeros for any port.
+// Configured with SFU_UDP_PORT_MIN and SFU_UDP_PORT_MAX.
+func SFUPortRange() (uint16, uint16) {
+	portMin := envInt("SFU_UDP_PORT_MIN", 0)
+	portMax := envInt("SFU_UDP_PORT_MAX", 0)
+	if portMin <= 0 || portMax < portMin || portMax > 65535 {
+		return 0, 0
+	}
+	return uint16(portMin), uint16(portMax)
+}
+
+// VideoResumeGrace returns how long a disconnected signaling client keeps its identity so a
+// reconnect can resume it. Zero disables resumption. Configured with VIDEO_RESUME_GRACE_SECONDS.
+func VideoResumeGrace() time.Duration {
+	return time.Duration(envInt("VIDEO_RESUME_GRACE_SECONDS", 20)) * time.Second
+}
@@ -0,0 +1,54 @@
+package credentials
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"skillswap/backend/internal/config"
+)
+
+// breachedList holds common passwords from public breach corpora, one per line
+//
+//go:embed breached.txt
+var breachedList string
+
+var (
+	breachedOnce sync.Once
+	breached     map[string]bool
+)
+
+// isBreached reports whether the lowercased password is on the built-in breached list
+// or in BREACHED_PASSWORDS_FILE
+func isBreached(lower string) bool {
+	breachedOnce.Do(func() {
+		breached = make(map[string]bool)
+		addBreached(strings.NewReader(breachedList))
+		if path := config.BreachedPasswordsFile(); path != "" {
+			f, err := os.Open(path)
+			if err != nil {
+				log.Printf("Failed to open breached password list: %v", err)
+				return
+			}
+			defer f.Close()
+			addBreached(f)
+		}
+	})
+	return breached[lower]
+}
+
+func addBreached(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			breached[strings.ToLower(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Failed to read breached password list: %v", err)
+	}
+}
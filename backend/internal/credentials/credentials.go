@@ -0,0 +1,186 @@
+// Package credentials hashes and verifies account passwords and enforces the password policy.
+package credentials
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"skillswap/backend/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher makes password hashes with one algorithm and its settings
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+	Memory     uint32 // KiB
+	Iterations uint32
+	Threads    uint8
+}
+
+// Default is the hasher new passwords are hashed with. Tests may replace it.
+var Default = FromConfig()
+
+// FromConfig returns the hasher selected by PASSWORD_HASHER and its settings
+func FromConfig() Hasher {
+	cost := config.BcryptCost()
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	memory, iterations, threads := config.Argon2Params()
+	if threads < 1 || threads > 255 {
+		threads = 2
+	}
+	return Hasher{
+		Algorithm:  config.PasswordHasher(),
+		BcryptCost: cost,
+		Memory:     uint32(max(memory, 8*threads)),
+		Iterations: uint32(max(iterations, 1)),
+		Threads:    uint8(threads),
+	}
+}
+
+// Hash hashes the password with the default hasher
+func Hash(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// Verify checks the password against a stored hash with the default hasher.
+// See Hasher.Verify.
+func Verify(hash, password string) (ok, rehash bool) {
+	return Default.Verify(hash, password)
+}
+
+// Hash hashes the password into a self-describing string: a bcrypt hash, or an
+// argon2id hash in the PHC string format
+func (h Hasher) Hash(password string) (string, error) {
+	if h.Algorithm != config.PasswordHasherArgon2id {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Threads, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks the password against a bcrypt, argon2id or legacy unsalted MD5 hash.
+// rehash reports that the password matched but the hash was not made with the hasher's
+// algorithm and settings, so it should be replaced with a new one.
+func (h Hasher) Verify(hash, password string) (ok, rehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		var version int
+		var memory, iterations uint32
+		var threads uint8
+		parts := strings.Split(hash, "$")
+		if len(parts) != 6 {
+			return false, false
+		}
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return false, false
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+			return false, false
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false, false
+		}
+		key, err := base64.RawStdEncoding.DecodeString(parts[5])
+		if err != nil || len(key) == 0 {
+			return false, false
+		}
+		actual := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false
+		}
+		return true, h.Algorithm != config.PasswordHasherArgon2id ||
+			memory != h.Memory || iterations != h.Iterations || threads != h.Threads
+
+	case strings.HasPrefix(hash, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return true, err != nil || h.Algorithm != config.PasswordHasherBcrypt || cost != h.BcryptCost
+
+	case isLegacyMD5(hash):
+		sum := md5.Sum([]byte(password))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hash))) != 1 {
+			return false, false
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// isLegacyMD5 reports whether the hash is a hex MD5 digest, as early accounts were stored
+func isLegacyMD5(hash string) bool {
+	if len(hash) != md5.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// maxLength is the longest password the hasher uses all of; bcrypt ignores anything past 72 bytes
+func (h Hasher) maxLength() int {
+	if h.Algorithm == config.PasswordHasherArgon2id {
+		return 256
+	}
+	return 72
+}
+
+var (
+	ErrTooShort        = errors.New("password is too short")
+	ErrTooLong         = errors.New("password is too long")
+	ErrBreached        = errors.New("this password has appeared in a data breach, please choose another one")
+	ErrContainsAccount = errors.New("password must not contain your username or email")
+)
+
+// IsPolicyError reports whether the error is a password the policy refuses
+func IsPolicyError(err error) bool {
+	return errors.Is(err, ErrTooShort) || errors.Is(err, ErrTooLong) ||
+		errors.Is(err, ErrBreached) || errors.Is(err, ErrContainsAccount)
+}
+
+// CheckPolicy refuses passwords that are too short or too long for the hasher, that are
+// known from data breaches, or that contain the account's username or email
+func CheckPolicy(password string, account ...string) error {
+	if minLength := config.PasswordMinLength(); len([]rune(password)) < minLength {
+		return fmt.Errorf("%w, use at least %d characters", ErrTooShort, minLength)
+	}
+	if maxLength := Default.maxLength(); len(password) > maxLength {
+		return fmt.Errorf("%w, use at most %d bytes", ErrTooLong, maxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if isBreached(lower) {
+		return ErrBreached
+	}
+	for _, name := range account {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if at := strings.IndexByte(name, '@'); at >= 0 {
+			name = name[:at]
+		}
+		if len(name) >= 3 && strings.Contains(lower, name) {
+			return ErrContainsAccount
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package credentials
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"skillswap/backend/internal/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hashers := []Hasher{
+		{Algorithm: config.PasswordHasherBcrypt, BcryptCost: bcrypt.MinCost},
+		{Algorithm: config.PasswordHasherArgon2id, Memory: 64, Iterations: 1, Threads: 1},
+	}
+	for _, h := range hashers {
+		t.Run(h.Algorithm, func(t *testing.T) {
+			hash, err := h.Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatalf("Hash failed: %v", err)
+			}
+			if ok, rehash := h.Verify(hash, "correct horse battery staple"); !ok || rehash {
+				t.Errorf("Expected the password to match without a rehash, got ok=%v rehash=%v", ok, rehash)
+			}
+			if ok, _ := h.Verify(hash, "wrong horse battery staple"); ok {
+				t.Error("Expected a wrong password not to match")
+			}
+			other, _ := h.Hash("correct horse battery staple")
+			if other == hash {
+				t.Error("Expected hashes of the same password to be salted differently")
+			}
+		})
+	}
+}
+
+func TestVerifyAsksForRehash(t *testing.T) {
+	bcryptHasher := Hasher{Algorithm: config.PasswordHasherBcrypt, BcryptCost: bcrypt.MinCost}
+	argonHasher := Hasher{Algorithm: config.PasswordHasherArgon2id, Memory: 64, Iterations: 1, Threads: 1}
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hash   func() string
+	}{
+		{"legacy md5", bcryptHasher, func() string { return "5f4dcc3b5aa765d61d8327deb882cf99" }},
+		{"bcrypt cost changed", Hasher{Algorithm: config.PasswordHasherBcrypt, BcryptCost: bcrypt.MinCost + 1}, func() string {
+			hash, _ := bcryptHasher.Hash("password")
+			return hash
+		}},
+		{"bcrypt to argon2id", argonHasher, func() string {
+			hash, _ := bcryptHasher.Hash("password")
+			return hash
+		}},
+		{"argon2id memory changed", Hasher{Algorithm: config.PasswordHasherArgon2id, Memory: 128, Iterations: 1, Threads: 1}, func() string {
+			hash, _ := argonHasher.Hash("password")
+			return hash
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := tt.hash()
+			if ok, rehash := tt.hasher.Verify(hash, "password"); !ok || !rehash {
+				t.Errorf("Expected a match that needs a rehash, got ok=%v rehash=%v", ok, rehash)
+			}
+			if ok, _ := tt.hasher.Verify(hash, "Password"); ok {
+				t.Error("Expected a wrong password not to match")
+			}
+		})
+	}
+
+	if ok, _ := bcryptHasher.Verify("", ""); ok {
+		t.Error("Expected an empty hash never to match")
+	}
+	if ok, _ := argonHasher.Verify("$argon2id$v=19$m=64,t=1,p=1$bad", "password"); ok {
+		t.Error("Expected a malformed hash never to match")
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{"short", ErrTooShort},
+		{strings.Repeat("x", 73) + "Z9!", ErrTooLong},
+		{"Password123", ErrBreached},
+		{"QWERTYUIOP", ErrBreached},
+		{"janis-is-great", ErrContainsAccount},
+		{"my-mail-janis.b", ErrContainsAccount},
+		{"plum tractor window", nil},
+	}
+	previous := Default
+	Default = Hasher{Algorithm: config.PasswordHasherBcrypt, BcryptCost: bcrypt.MinCost}
+	defer func() { Default = previous }()
+
+	for _, tt := range tests {
+		err := CheckPolicy(tt.password, "Janis", "janis.b@example.com")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("CheckPolicy(%q) = %v, want %v", tt.password, err, tt.want)
+		}
+		if tt.want != nil && !IsPolicyError(err) {
+			t.Errorf("Expected %v to be a policy error", err)
+		}
+	}
+}
@@ -182,6 +182,11 @@ func QueryRow(query string, args ...interface{}) *sql.Row {
 	return db.QueryRow(query, args...)
 }
 
+// Begin starts a transaction on the main database connection
+func Begin() (*sql.Tx, error) {
+	return db.Begin()
+}
+
 func Close() error {
 	return db.Close()
 }
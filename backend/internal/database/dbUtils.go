@@ -1,6 +1,7 @@
 package database
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"skillswap/backend/internal/models"
 	"skillswap/backend/internal/utils"
@@ -66,15 +67,63 @@ func GetAllSkills() ([]models.Skill, error) {
 	return skills, nil
 }
 
+// SkillFilter narrows a search to users with a skill of a teaching style, at least a
+// proficiency level or number of years of experience, or verified. Empty fields do not filter.
+// When set, only the skills that pass the filter are listed in the results.
+type SkillFilter struct {
+	TeachingStyle  string `json:"teaching_style"`
+	MinProficiency string `json:"min_proficiency"`
+	MinYears       int    `json:"min_years"`
+	VerifiedOnly   bool   `json:"verified_only"`
+}
+
+// clause returns the SQL conditions on user_skills (aliased us) for the filter
+func (f SkillFilter) clause() (string, []interface{}, error) {
+	var where string
+	var args []interface{}
+	if f.TeachingStyle != "" {
+		if !models.ValidTeachingStyle(f.TeachingStyle) {
+			return "", nil, fmt.Errorf("invalid teaching style %q", f.TeachingStyle)
+		}
+		where += " AND us.teaching_skill = ?"
+		args = append(args, f.TeachingStyle)
+	}
+	if f.MinProficiency != "" {
+		rank := models.ProficiencyRank(f.MinProficiency)
+		if rank < 0 {
+			return "", nil, fmt.Errorf("invalid proficiency %q", f.MinProficiency)
+		}
+		// ENUM values compare by their position, starting at 1
+		where += " AND us.proficiency + 0 >= ?"
+		args = append(args, rank+1)
+	}
+	if f.MinYears > 0 {
+		where += " AND us.years_experience >= ?"
+		args = append(args, f.MinYears)
+	}
+	if f.VerifiedOnly {
+		where += " AND us.verified = 1"
+	}
+	return where, args, nil
+}
+
+// ReputationColumns selects the average rating and number of the published swap reviews about user "u"
+const ReputationColumns = `
+	COALESCE((SELECT ROUND(AVG(rv.rating), 2) FROM swap_reviews rv
+		WHERE rv.reviewee_id = u.id AND rv.visible_at <= NOW() AND rv.removed_at IS NULL), 0) AS reputation_rating,
+	(SELECT COUNT(*) FROM swap_reviews rv
+		WHERE rv.reviewee_id = u.id AND rv.visible_at <= NOW() AND rv.removed_at IS NULL) AS reputation_reviews`
+
 // Search decodes a JSON body containing a "query" string and returns up to five users matching the query along with their aggregated skills.
 //
-// The request body must be JSON with the field `query`. The handler responds with a JSON array of `models.SearchResult` entries,
-// each containing the user's ID, username, email, and a comma-separated `SkillsFound` string. If the JSON body cannot be decoded,
+// The request body must be JSON with the field `query` and may carry the SkillFilter fields. The handler responds with a JSON array of `models.SearchResult` entries,
+// each containing the user's ID, username, email, reputation, and a comma-separated `SkillsFound` string. If the JSON body cannot be decoded,
 // the handler responds with HTTP 200 and a JSON error message. On database/query errors the error is recorded and the handler returns without writing a further response.
 func Search(w http.ResponseWriter, req *http.Request) {
 
 	var requestBody struct {
 		Query string `json:"query"`
+		SkillFilter
 	}
 	err := json.NewDecoder(req.Body).Decode(&requestBody)
 	if err != nil {
@@ -85,6 +134,12 @@ func Search(w http.ResponseWriter, req *http.Request) {
 	}
 
 	searchQuery := "%" + requestBody.Query + "%"
+	filter, filterArgs, err := requestBody.SkillFilter.clause()
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	args := append([]interface{}{searchQuery, searchQuery, searchQuery, searchQuery}, filterArgs...)
 
 	// Use a corrected and more efficient SQL query with LEFT JOINs to include users without skills
 	rows, err := Query(`
@@ -92,15 +147,15 @@ func Search(w http.ResponseWriter, req *http.Request) {
             u.id,
             u.username,
             u.email,
-            COALESCE(GROUP_CONCAT(s.name SEPARATOR ', '), '') AS skills_found
+            COALESCE(GROUP_CONCAT(s.name SEPARATOR ', '), '') AS skills_found,`+ReputationColumns+`
         FROM users AS u
         LEFT JOIN user_skills AS us ON u.id = us.user_id
         LEFT JOIN skills AS s ON us.skill_id = s.id
-        WHERE u.username LIKE ? OR u.email LIKE ? OR s.name LIKE ? OR s.description LIKE ?
+        WHERE (u.username LIKE ? OR u.email LIKE ? OR s.name LIKE ? OR s.description LIKE ?)`+filter+`
         GROUP BY u.id, u.username, u.email
         ORDER BY u.id
 		LIMIT 5
-    `, searchQuery, searchQuery, searchQuery, searchQuery)
+    `, args...)
 	if err != nil {
 		utils.HandleError(err)
 		return
@@ -112,7 +167,7 @@ func Search(w http.ResponseWriter, req *http.Request) {
 
 	for rows.Next() {
 		var r models.SearchResult
-		if err := rows.Scan(&r.User.ID, &r.User.Username, &r.User.Email, &r.SkillsFound); err != nil {
+		if err := rows.Scan(&r.User.ID, &r.User.Username, &r.User.Email, &r.SkillsFound, &r.User.Reputation.Rating, &r.User.Reputation.Reviews); err != nil {
 			utils.HandleError(err)
 			return
 		}
@@ -130,9 +185,9 @@ func Search(w http.ResponseWriter, req *http.Request) {
 // FullSearch searches users by username, email, skill name, or skill description and writes matching users
 // with aggregated skill names to the response as a JSON array of models.SearchResult.
 //
-// The request must provide a JSON body with a `query` string field; the server performs a wildcard search
+// The request must provide a JSON body with a `query` string field and may carry the SkillFilter fields; the server performs a wildcard search
 // around that query and returns each matched user's ID, username, email, about-me text, profession,
-// comma-separated skills (may be empty), and join/created timestamp.
+// comma-separated skills (may be empty), join/created timestamp, and reputation.
 //
 // On JSON decode failure it logs the error and responds with HTTP 200 and a JSON error object
 // (`{"error":"Failed to get shit"}`). On database/query errors it logs the error and returns without
@@ -141,6 +196,7 @@ func FullSearch(w http.ResponseWriter, req *http.Request) {
 
 	var requestBody struct {
 		Query string `json:"query"`
+		SkillFilter
 	}
 	err := json.NewDecoder(req.Body).Decode(&requestBody)
 	if err != nil {
@@ -151,6 +207,12 @@ func FullSearch(w http.ResponseWriter, req *http.Request) {
 	}
 
 	searchQuery := "%" + requestBody.Query + "%"
+	filter, filterArgs, err := requestBody.SkillFilter.clause()
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	args := append([]interface{}{searchQuery, searchQuery, searchQuery, searchQuery}, filterArgs...)
 
 	// Use a corrected and more efficient SQL query with LEFT JOINs to include users without skills
 	rows, err := db.Query(`
@@ -161,15 +223,15 @@ func FullSearch(w http.ResponseWriter, req *http.Request) {
 	        COALESCE(u.aboutme, '') as aboutme,
 	        COALESCE(u.profession, '') as profession,
 	        COALESCE(GROUP_CONCAT(s.name SEPARATOR ', '), '') AS skills_found,
-	        u.created_at
+	        u.created_at,`+ReputationColumns+`
 	    FROM users AS u
 
         LEFT JOIN user_skills AS us ON u.id = us.user_id
         LEFT JOIN skills AS s ON us.skill_id = s.id
-        WHERE u.username LIKE ? OR u.email LIKE ? OR s.name LIKE ? OR s.description LIKE ?
+        WHERE (u.username LIKE ? OR u.email LIKE ? OR s.name LIKE ? OR s.description LIKE ?)`+filter+`
         GROUP BY u.id, u.username, u.email
         ORDER BY u.id
-    `, searchQuery, searchQuery, searchQuery, searchQuery)
+    `, args...)
 	if err != nil {
 		utils.HandleError(err)
 		return
@@ -181,7 +243,7 @@ func FullSearch(w http.ResponseWriter, req *http.Request) {
 
 	for rows.Next() {
 		var r models.SearchResult
-		if err := rows.Scan(&r.User.ID, &r.User.Username, &r.User.Email, &r.User.AboutMe, &r.User.Professions, &r.SkillsFound, &r.User.Joined); err != nil {
+		if err := rows.Scan(&r.User.ID, &r.User.Username, &r.User.Email, &r.User.AboutMe, &r.User.Professions, &r.SkillsFound, &r.User.Joined, &r.User.Reputation.Rating, &r.User.Reputation.Reviews); err != nil {
 			utils.HandleError(err)
 			return
 		}
@@ -526,3 +526,30 @@ func TestQueryRow(t *testing.T) {
 		t.Errorf("Expected email 'test@example.com', got '%s'", email)
 	}
 }
+
+func TestSkillFilterClause(t *testing.T) {
+	where, args, err := SkillFilter{}.clause()
+	if err != nil || where != "" || len(args) != 0 {
+		t.Errorf("Expected an empty filter to add nothing, got %q %v %v", where, args, err)
+	}
+
+	where, args, err = SkillFilter{TeachingStyle: "Professor", MinProficiency: "advanced", MinYears: 3, VerifiedOnly: true}.clause()
+	if err != nil {
+		t.Fatalf("clause() error = %v", err)
+	}
+	for _, cond := range []string{"us.teaching_skill = ?", "us.proficiency + 0 >= ?", "us.years_experience >= ?", "us.verified = 1"} {
+		if !strings.Contains(where, cond) {
+			t.Errorf("Expected %q in %q", cond, where)
+		}
+	}
+	if len(args) != 3 || args[1] != 3 {
+		t.Errorf("Expected advanced to be ENUM position 3, got args %v", args)
+	}
+
+	if _, _, err := (SkillFilter{TeachingStyle: "Lecture"}).clause(); err == nil {
+		t.Error("Expected an invalid teaching style to be refused")
+	}
+	if _, _, err := (SkillFilter{MinProficiency: "guru"}).clause(); err == nil {
+		t.Error("Expected an invalid proficiency to be refused")
+	}
+}
@@ -0,0 +1,54 @@
+-- Migration: 004_add_call_sessions.sql
+-- Description: Records video call sessions held in signaling rooms so swaps are
+-- only settled once both parties were actually connected.
+
+CREATE TABLE IF NOT EXISTS call_sessions (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  room_id VARCHAR(191) NOT NULL,
+  chat_id BIGINT UNSIGNED NULL,
+  started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ended_at TIMESTAMP NULL DEFAULT NULL,
+  overlap_seconds INT UNSIGNED NOT NULL DEFAULT 0,
+  fulfilled_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_call_sessions_chat
+    FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE SET NULL,
+
+  KEY idx_call_sessions_room (room_id),
+  KEY idx_call_sessions_chat (chat_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS call_session_participants (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  session_id BIGINT UNSIGNED NOT NULL,
+  user_id BIGINT UNSIGNED NOT NULL,
+  joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  left_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_call_participants_session
+    FOREIGN KEY (session_id) REFERENCES call_sessions(id) ON DELETE CASCADE,
+  CONSTRAINT fk_call_participants_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_call_participants_session (session_id),
+  KEY idx_call_participants_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Chats double as the swap record: the learner pays when the chat is created
+-- and the teacher is credited once a call reaches the minimum duration.
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'chats' AND COLUMN_NAME = 'swap_fulfilled_at');
+
+SET @query = IF(@col_exists = 0,
+  'ALTER TABLE chats ADD COLUMN swap_fulfilled_at TIMESTAMP NULL DEFAULT NULL AFTER user2_id',
+  'SELECT "Column swap_fulfilled_at already exists" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- Chats created before this migration already exchanged their credits when they were opened
+UPDATE chats SET swap_fulfilled_at = created_at WHERE swap_fulfilled_at IS NULL;
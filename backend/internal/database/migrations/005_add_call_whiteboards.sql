@@ -0,0 +1,14 @@
+-- Migration: 005_add_call_whiteboards.sql
+-- Description: Stores the shared whiteboard of each call session so it can be
+-- exported after the call ends.
+
+CREATE TABLE IF NOT EXISTS call_whiteboards (
+  session_id BIGINT UNSIGNED NOT NULL,
+  ops LONGTEXT NOT NULL,
+  last_seq BIGINT UNSIGNED NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (session_id),
+  CONSTRAINT fk_call_whiteboards_session
+    FOREIGN KEY (session_id) REFERENCES call_sessions(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
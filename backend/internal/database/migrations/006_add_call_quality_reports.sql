@@ -0,0 +1,28 @@
+-- Migration: 006_add_call_quality_reports.sql
+-- Description: Stores periodic WebRTC stats reported by call participants so
+-- support can diagnose poor calls, relay usage and NAT problems.
+
+CREATE TABLE IF NOT EXISTS call_quality_reports (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  session_id BIGINT UNSIGNED NOT NULL,
+  user_id BIGINT UNSIGNED NOT NULL,
+  room_id VARCHAR(191) NOT NULL,
+  reported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  rtt_ms DOUBLE NULL DEFAULT NULL,
+  packet_loss DOUBLE NULL DEFAULT NULL,
+  jitter_ms DOUBLE NULL DEFAULT NULL,
+  inbound_kbps DOUBLE NULL DEFAULT NULL,
+  outbound_kbps DOUBLE NULL DEFAULT NULL,
+  local_candidate_type VARCHAR(16) NULL DEFAULT NULL,
+  remote_candidate_type VARCHAR(16) NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_call_quality_session
+    FOREIGN KEY (session_id) REFERENCES call_sessions(id) ON DELETE CASCADE,
+  CONSTRAINT fk_call_quality_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_call_quality_session (session_id),
+  KEY idx_call_quality_user (user_id),
+  KEY idx_call_quality_room (room_id, reported_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,34 @@
+-- Migration: 007_add_swap_transactions.sql
+-- Description: Records every movement of swap credits in a ledger. users.swaps
+-- is kept as a cached balance that is only changed together with a ledger entry.
+-- A NULL from_user_id means credits were issued, a NULL to_user_id means they were spent.
+
+CREATE TABLE IF NOT EXISTS swap_transactions (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  from_user_id BIGINT UNSIGNED NULL,
+  to_user_id BIGINT UNSIGNED NULL,
+  amount INT UNSIGNED NOT NULL,
+  reason VARCHAR(32) NOT NULL,
+  reference VARCHAR(191) NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_swap_transactions_from
+    FOREIGN KEY (from_user_id) REFERENCES users(id) ON DELETE SET NULL,
+  CONSTRAINT fk_swap_transactions_to
+    FOREIGN KEY (to_user_id) REFERENCES users(id) ON DELETE SET NULL,
+
+  KEY idx_swap_transactions_from (from_user_id, created_at),
+  KEY idx_swap_transactions_to (to_user_id, created_at),
+  KEY idx_swap_transactions_reference (reference)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Open the ledger with each user's current balance
+INSERT INTO swap_transactions (from_user_id, to_user_id, amount, reason, reference)
+SELECT NULL, id, swaps, 'opening_balance', NULL FROM users WHERE swaps > 0;
+
+INSERT INTO swap_transactions (from_user_id, to_user_id, amount, reason, reference)
+SELECT id, NULL, -swaps, 'opening_balance', NULL FROM users WHERE swaps < 0;
+
+-- New accounts start empty and receive their signup credits through the ledger
+ALTER TABLE users MODIFY COLUMN swaps INT NOT NULL DEFAULT 0;
@@ -0,0 +1,72 @@
+-- Migration: 008_add_swap_requests.sql
+-- Description: Swaps become explicit requests from a learner to a teacher.
+-- The learner is charged when the teacher accepts and the teacher is paid when
+-- the swap is completed, so chats no longer carry swap state.
+-- Learners who paid when opening a chat whose call never reached the minimum
+-- duration are refunded before the column is dropped; converting those charges
+-- into requests was not possible because chats do not record the skill.
+
+CREATE TABLE IF NOT EXISTS swap_requests (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  learner_id BIGINT UNSIGNED NOT NULL,
+  teacher_id BIGINT UNSIGNED NOT NULL,
+  skill_id BIGINT UNSIGNED NOT NULL,
+  status ENUM('proposed', 'accepted', 'declined', 'cancelled', 'completed', 'expired') NOT NULL DEFAULT 'proposed',
+  proposed_time DATETIME NULL DEFAULT NULL,
+  message TEXT NULL,
+  chat_id BIGINT UNSIGNED NULL DEFAULT NULL,
+  cancelled_by BIGINT UNSIGNED NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  responded_at TIMESTAMP NULL DEFAULT NULL,
+  completed_at TIMESTAMP NULL DEFAULT NULL,
+  expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_swap_requests_learner
+    FOREIGN KEY (learner_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_requests_teacher
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_requests_skill
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_requests_chat
+    FOREIGN KEY (chat_id) REFERENCES chats(id) ON DELETE SET NULL,
+
+  KEY idx_swap_requests_learner (learner_id, status),
+  KEY idx_swap_requests_teacher (teacher_id, status),
+  KEY idx_swap_requests_chat (chat_id, status),
+  KEY idx_swap_requests_expiry (status, expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'chats' AND COLUMN_NAME = 'swap_fulfilled_at');
+
+-- Refund the learner (the chat's first user) of every chat that is still unsettled
+SET @query = IF(@col_exists = 1,
+  'INSERT INTO swap_transactions (from_user_id, to_user_id, amount, reason, reference)
+   SELECT NULL, user1_id, 1, ''swap_refund'', CONCAT(''chat:'', id) FROM chats WHERE swap_fulfilled_at IS NULL',
+  'SELECT "No unsettled chats to refund" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @query = IF(@col_exists = 1,
+  'UPDATE users u
+   JOIN (SELECT user1_id, COUNT(*) AS refunds FROM chats WHERE swap_fulfilled_at IS NULL GROUP BY user1_id) c
+     ON c.user1_id = u.id
+   SET u.swaps = u.swaps + c.refunds',
+  'SELECT "No unsettled chats to refund" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @query = IF(@col_exists = 1,
+  'ALTER TABLE chats DROP COLUMN swap_fulfilled_at',
+  'SELECT "Column swap_fulfilled_at already dropped" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,55 @@
+-- Migration: 009_add_swap_escrow.sql
+-- Description: Credits of an accepted swap are held in escrow until the swap is
+-- completed (released to the teacher), called off (refunded to the learner) or
+-- settled by an admin after a dispute. Ledger entries that move credits into or
+-- out of escrow reference the swap request they belong to.
+
+CREATE TABLE IF NOT EXISTS swap_escrows (
+  request_id BIGINT UNSIGNED NOT NULL,
+  learner_id BIGINT UNSIGNED NULL,
+  teacher_id BIGINT UNSIGNED NULL,
+  amount INT UNSIGNED NOT NULL,
+  status ENUM('held', 'released', 'refunded', 'split') NOT NULL DEFAULT 'held',
+  disputed_by BIGINT UNSIGNED NULL DEFAULT NULL,
+  dispute_reason TEXT NULL,
+  disputed_at TIMESTAMP NULL DEFAULT NULL,
+  settled_by BIGINT UNSIGNED NULL DEFAULT NULL,
+  settlement_note TEXT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  settled_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (request_id),
+  CONSTRAINT fk_swap_escrows_request
+    FOREIGN KEY (request_id) REFERENCES swap_requests(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_escrows_learner
+    FOREIGN KEY (learner_id) REFERENCES users(id) ON DELETE SET NULL,
+  CONSTRAINT fk_swap_escrows_teacher
+    FOREIGN KEY (teacher_id) REFERENCES users(id) ON DELETE SET NULL,
+
+  KEY idx_swap_escrows_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'swap_transactions' AND COLUMN_NAME = 'escrow_request_id');
+
+SET @query = IF(@col_exists = 0,
+  'ALTER TABLE swap_transactions ADD COLUMN escrow_request_id BIGINT UNSIGNED NULL DEFAULT NULL AFTER reference, ADD KEY idx_swap_transactions_escrow (escrow_request_id)',
+  'SELECT "Column escrow_request_id already exists" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+-- Disputed swaps wait for an admin
+ALTER TABLE swap_requests MODIFY COLUMN status
+  ENUM('proposed', 'accepted', 'disputed', 'declined', 'cancelled', 'completed', 'expired') NOT NULL DEFAULT 'proposed';
+
+-- Swaps accepted before escrow existed already charged the learner; hold that credit
+INSERT INTO swap_escrows (request_id, learner_id, teacher_id, amount, status)
+SELECT id, learner_id, teacher_id, 1, 'held' FROM swap_requests WHERE status = 'accepted';
+
+UPDATE swap_transactions t
+JOIN swap_requests r ON t.reference = CONCAT('swap_request:', r.id)
+SET t.escrow_request_id = r.id, t.reason = 'escrow_hold'
+WHERE t.reason = 'swap_payment' AND r.status = 'accepted';
@@ -0,0 +1,15 @@
+-- Migration: 010_add_swap_transaction_audit.sql
+-- Description: Admin credit adjustments record the acting admin and the reason
+-- they gave, so every manual change to a balance can be traced.
+
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'swap_transactions' AND COLUMN_NAME = 'actor_id');
+
+SET @query = IF(@col_exists = 0,
+  'ALTER TABLE swap_transactions ADD COLUMN actor_id BIGINT UNSIGNED NULL DEFAULT NULL AFTER escrow_request_id, ADD COLUMN note VARCHAR(500) NULL DEFAULT NULL AFTER actor_id, ADD KEY idx_swap_transactions_actor (actor_id, created_at), ADD CONSTRAINT fk_swap_transactions_actor FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL',
+  'SELECT "Column actor_id already exists" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
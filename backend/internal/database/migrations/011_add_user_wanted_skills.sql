@@ -0,0 +1,17 @@
+-- Migration: 011_add_user_wanted_skills.sql
+-- Description: Users list the skills they want to learn next to the ones they
+-- teach, so the platform can suggest reciprocal swaps.
+
+CREATE TABLE IF NOT EXISTS user_wanted_skills (
+  user_id BIGINT UNSIGNED NOT NULL,
+  skill_id BIGINT UNSIGNED NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (user_id, skill_id),
+  CONSTRAINT fk_user_wanted_skills_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_user_wanted_skills_skill
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE,
+
+  KEY idx_user_wanted_skills_skill (skill_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
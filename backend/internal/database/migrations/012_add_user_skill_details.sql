@@ -0,0 +1,15 @@
+-- Migration: 012_add_user_skill_details.sql
+-- Description: Skills on a profile carry a proficiency level and years of
+-- experience next to the existing teaching style.
+
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'user_skills' AND COLUMN_NAME = 'proficiency');
+
+SET @query = IF(@col_exists = 0,
+  'ALTER TABLE user_skills ADD COLUMN proficiency ENUM(''beginner'', ''intermediate'', ''advanced'', ''expert'') NOT NULL DEFAULT ''intermediate'' AFTER teaching_skill, ADD COLUMN years_experience TINYINT UNSIGNED NOT NULL DEFAULT 0 AFTER proficiency',
+  'SELECT "Column proficiency already exists" AS msg');
+
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
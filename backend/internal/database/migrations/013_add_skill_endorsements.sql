@@ -0,0 +1,72 @@
+-- Migration: 013_add_skill_endorsements.sql
+-- Description: Skill verification becomes server-controlled. A skill is verified
+-- by admin approval, by enough endorsements from swap partners, or by completing
+-- a course in that skill; each grant is kept as evidence. user_skills.verified is
+-- a cached flag derived from skill_verifications.
+
+CREATE TABLE IF NOT EXISTS skill_endorsements (
+  endorser_id BIGINT UNSIGNED NOT NULL,
+  user_id BIGINT UNSIGNED NOT NULL,
+  skill_id BIGINT UNSIGNED NOT NULL,
+  swap_request_id BIGINT UNSIGNED NULL DEFAULT NULL,
+  comment VARCHAR(500) NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (endorser_id, user_id, skill_id),
+  CONSTRAINT fk_skill_endorsements_endorser
+    FOREIGN KEY (endorser_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_endorsements_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_endorsements_skill
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_endorsements_swap
+    FOREIGN KEY (swap_request_id) REFERENCES swap_requests(id) ON DELETE SET NULL,
+
+  KEY idx_skill_endorsements_user (user_id, skill_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS skill_verifications (
+  user_id BIGINT UNSIGNED NOT NULL,
+  skill_id BIGINT UNSIGNED NOT NULL,
+  method ENUM('admin', 'endorsements', 'course') NOT NULL,
+  evidence TEXT NULL,
+  course_id BIGINT UNSIGNED NULL DEFAULT NULL,
+  approved_by BIGINT UNSIGNED NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (user_id, skill_id, method),
+  CONSTRAINT fk_skill_verifications_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_verifications_skill
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_verifications_course
+    FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE SET NULL,
+  CONSTRAINT fk_skill_verifications_admin
+    FOREIGN KEY (approved_by) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS skill_verification_requests (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id BIGINT UNSIGNED NOT NULL,
+  skill_id BIGINT UNSIGNED NOT NULL,
+  evidence TEXT NOT NULL,
+  status ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending',
+  reviewed_by BIGINT UNSIGNED NULL DEFAULT NULL,
+  review_note VARCHAR(500) NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  reviewed_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_skill_verification_requests_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_verification_requests_skill
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE,
+  CONSTRAINT fk_skill_verification_requests_admin
+    FOREIGN KEY (reviewed_by) REFERENCES users(id) ON DELETE SET NULL,
+
+  KEY idx_skill_verification_requests_status (status, created_at),
+  KEY idx_skill_verification_requests_user (user_id, skill_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Flags set so far came from clients and cannot be trusted
+UPDATE user_skills SET verified = 0;
@@ -0,0 +1,64 @@
+-- Migration: 014_add_swap_reviews.sql
+-- Description: Both parties of a completed swap can review each other. A review
+-- stays hidden until its counterpart is submitted or the review window closes
+-- (visible_at), so neither side can retaliate. Reviews can be reported and
+-- removed by an admin, which also takes them out of the user's reputation.
+
+CREATE TABLE IF NOT EXISTS swap_reviews (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  swap_request_id BIGINT UNSIGNED NOT NULL,
+  reviewer_id BIGINT UNSIGNED NOT NULL,
+  reviewee_id BIGINT UNSIGNED NOT NULL,
+  rating TINYINT UNSIGNED NOT NULL CHECK (rating >= 1 AND rating <= 5),
+  review_text TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  visible_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  removed_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_swap_reviews_reviewer (swap_request_id, reviewer_id),
+  CONSTRAINT fk_swap_reviews_request
+    FOREIGN KEY (swap_request_id) REFERENCES swap_requests(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_reviews_reviewer
+    FOREIGN KEY (reviewer_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_reviews_reviewee
+    FOREIGN KEY (reviewee_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_swap_reviews_reviewee (reviewee_id, visible_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS swap_review_skills (
+  review_id BIGINT UNSIGNED NOT NULL,
+  skill_id BIGINT UNSIGNED NOT NULL,
+  rating TINYINT UNSIGNED NOT NULL CHECK (rating >= 1 AND rating <= 5),
+  feedback TEXT,
+
+  PRIMARY KEY (review_id, skill_id),
+  CONSTRAINT fk_swap_review_skills_review
+    FOREIGN KEY (review_id) REFERENCES swap_reviews(id) ON DELETE CASCADE,
+  CONSTRAINT fk_swap_review_skills_skill
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS review_reports (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  review_id BIGINT UNSIGNED NOT NULL,
+  reporter_id BIGINT UNSIGNED NOT NULL,
+  reason VARCHAR(1000) NOT NULL,
+  status ENUM('open', 'dismissed', 'removed') NOT NULL DEFAULT 'open',
+  resolved_by BIGINT UNSIGNED NULL DEFAULT NULL,
+  resolution_note VARCHAR(500) NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  resolved_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_review_reports_reporter (review_id, reporter_id),
+  CONSTRAINT fk_review_reports_review
+    FOREIGN KEY (review_id) REFERENCES swap_reviews(id) ON DELETE CASCADE,
+  CONSTRAINT fk_review_reports_reporter
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_review_reports_admin
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL,
+
+  KEY idx_review_reports_status (status, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
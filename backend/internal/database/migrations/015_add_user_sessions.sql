@@ -0,0 +1,26 @@
+-- Migration: 015_add_user_sessions.sql
+-- Description: Sessions are kept server-side so they can be listed per device and
+-- revoked remotely. The cookie only carries a signed random token; the table keeps
+-- its SHA-256 hash, the encoded session values and where the session was last seen.
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  token_hash CHAR(64) NOT NULL,
+  user_id BIGINT UNSIGNED NULL DEFAULT NULL,
+  data TEXT NOT NULL,
+  user_agent VARCHAR(500) NULL DEFAULT NULL,
+  device VARCHAR(100) NULL DEFAULT NULL,
+  ip_address VARCHAR(45) NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_user_sessions_token (token_hash),
+  CONSTRAINT fk_user_sessions_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_user_sessions_user (user_id, revoked_at),
+  KEY idx_user_sessions_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,39 @@
+-- Migration: 017_add_email_verification.sql
+-- Description: Email addresses must be confirmed. users.email_verified_at is set when
+-- a verification link is opened; a changed email only replaces users.email once the
+-- new address is confirmed. Accounts that existed before this migration count as verified.
+
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'users' AND COLUMN_NAME = 'email_verified_at');
+
+SET @query = IF(@col_exists = 0,
+  'ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL DEFAULT NULL AFTER email',
+  'SELECT "Column email_verified_at already exists" AS msg');
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @query = IF(@col_exists = 0,
+  'UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL',
+  'SELECT "Existing users already migrated" AS msg');
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id BIGINT UNSIGNED NOT NULL,
+  email VARCHAR(191) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_email_verifications_token (token_hash),
+  CONSTRAINT fk_email_verifications_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_email_verifications_user (user_id, used_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,30 @@
+-- Migration: 018_add_two_factor.sql
+-- Description: TOTP two-factor authentication. A secret counts once it is confirmed
+-- with a first code; last_used_step stops a code from being used twice. Recovery
+-- codes are single-use and only their SHA-256 hashes are stored.
+
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id BIGINT UNSIGNED NOT NULL,
+  secret VARCHAR(64) NOT NULL,
+  confirmed_at TIMESTAMP NULL DEFAULT NULL,
+  last_used_step BIGINT NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (user_id),
+  CONSTRAINT fk_user_totp_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id BIGINT UNSIGNED NOT NULL,
+  code_hash CHAR(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  used_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  CONSTRAINT fk_user_recovery_codes_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_user_recovery_codes_user (user_id, used_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,25 @@
+-- Migration: 019_add_login_throttling.sql
+-- Description: Failed logins are counted per account (by email) and per IP address
+-- so that delays and lockouts survive restarts. users.last_login_at and last_login_ip
+-- record the latest successful login.
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+  throttle_key VARCHAR(191) NOT NULL,
+  failures INT UNSIGNED NOT NULL DEFAULT 0,
+  last_failed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  locked_until TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (throttle_key),
+  KEY idx_login_throttles_last_failed (last_failed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+SET @dbname = DATABASE();
+SET @col_exists = (SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS
+  WHERE TABLE_SCHEMA = @dbname AND TABLE_NAME = 'users' AND COLUMN_NAME = 'last_login_at');
+
+SET @query = IF(@col_exists = 0,
+  'ALTER TABLE users ADD COLUMN last_login_at TIMESTAMP NULL DEFAULT NULL, ADD COLUMN last_login_ip VARCHAR(45) NULL DEFAULT NULL',
+  'SELECT "Column last_login_at already exists" AS msg');
+PREPARE stmt FROM @query;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
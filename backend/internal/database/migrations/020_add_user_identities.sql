@@ -0,0 +1,23 @@
+-- Migration: 020_add_user_identities.sql
+-- Description: Accounts can sign in through OpenID Connect providers. Each linked
+-- provider account is identified by its issuer subject. Accounts created through a
+-- provider have no local password, so users.password_hash becomes nullable.
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id BIGINT UNSIGNED NOT NULL,
+  provider VARCHAR(64) NOT NULL,
+  subject VARCHAR(191) NOT NULL,
+  email VARCHAR(191) NULL DEFAULT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_login_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_user_identities_subject (provider, subject),
+  CONSTRAINT fk_user_identities_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_user_identities_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE users MODIFY password_hash VARCHAR(255) NULL DEFAULT NULL;
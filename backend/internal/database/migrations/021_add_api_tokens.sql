@@ -0,0 +1,26 @@
+-- Migration: 021_add_api_tokens.sql
+-- Description: Personal access tokens let scripts call the API without the session
+-- cookie. Only the SHA-256 hash of a token is kept, next to its first characters so
+-- users can tell their tokens apart. Scopes are space-separated, as in OAuth.
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  user_id BIGINT UNSIGNED NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  token_prefix VARCHAR(12) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  last_used_at TIMESTAMP NULL DEFAULT NULL,
+  last_used_ip VARCHAR(45) NULL DEFAULT NULL,
+  revoked_at TIMESTAMP NULL DEFAULT NULL,
+
+  PRIMARY KEY (id),
+  UNIQUE KEY uq_api_tokens_token (token_hash),
+  CONSTRAINT fk_api_tokens_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+
+  KEY idx_api_tokens_user (user_id, revoked_at),
+  KEY idx_api_tokens_expires (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 
+	"skillswap/backend/internal/models"
+
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -67,6 +69,17 @@ func ClearTestData() error {
 		log.Printf("Warning: failed to clear test user_skills: %v", err)
 	}
 
+	_, err = TestDB.Exec(`
+		DELETE FROM swap_transactions WHERE from_user_id IN (
+			SELECT id FROM users WHERE username LIKE '%test%' OR email LIKE '%test%'
+		) OR to_user_id IN (
+			SELECT id FROM users WHERE username LIKE '%test%' OR email LIKE '%test%'
+		)
+	`)
+	if err != nil {
+		log.Printf("Warning: failed to clear test swap transactions: %v", err)
+	}
+
 	_, err = TestDB.Exec(`
 		DELETE FROM users WHERE username LIKE '%test%' OR email LIKE '%test%'
 	`)
@@ -98,13 +111,37 @@ func InsertTestUser(username, email, password string) (int64, error) {
 	}
 
 	result, err := TestDB.Exec(
-		"INSERT INTO users (username, email, password_hash) VALUES (?, ?, MD5(?))",
+		"INSERT INTO users (username, email, password_hash, swaps) VALUES (?, ?, MD5(?), 2)",
 		username, email, password,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// Record the starting credits in the ledger like registration does
+	_, err = TestDB.Exec(
+		"INSERT INTO swap_transactions (to_user_id, amount, reason) VALUES (?, 2, ?)",
+		id, models.ReasonSignupBonus,
+	)
+	return id, err
+}
+
+// InsertTestUsers creates n test users named prefix0, prefix1, ... with their emails at example.com
+func InsertTestUsers(prefix string, n int) ([]int64, error) {
+	ids := make([]int64, n)
+	for i := range ids {
+		name := fmt.Sprintf("%s%d", prefix, i)
+		id, err := InsertTestUser(name, name+"@example.com", "password123")
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
 }
 
 // InsertTestSkill creates a test skill in the database
@@ -138,3 +175,20 @@ func InsertTestChat(user1ID, user2ID int64) (int64, error) {
 	}
 	return result.LastInsertId()
 }
+
+// InsertTestCompletedSwap records a swap request in which the teacher taught the skill,
+// completed the given number of days ago
+func InsertTestCompletedSwap(learnerID, teacherID, skillID int64, daysAgo int) (int64, error) {
+	if TestDB == nil {
+		return 0, fmt.Errorf("test database not initialized")
+	}
+
+	result, err := TestDB.Exec(
+		"INSERT INTO swap_requests (learner_id, teacher_id, skill_id, status, completed_at) VALUES (?, ?, ?, 'completed', NOW() - INTERVAL ? DAY)",
+		learnerID, teacherID, skillID, daysAgo,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// UnlockUser - clear a user's failed logins and lift a lockout (admin only)
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid request data",
+		})
+		return
+	}
+
+	if err := auth.UnlockAccount(req.UserID); err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{
+				"error": "User not found",
+			})
+			return
+		}
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to unlock user",
+		})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"message": "User unlocked",
+	})
+}
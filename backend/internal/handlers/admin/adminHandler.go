@@ -3,14 +3,25 @@ package admin
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/handlers/swaps"
 	"skillswap/backend/internal/utils"
 	"strconv"
 	"time"
 )
 
+// currentAdminID - ID of the admin making the request, as resolved by AdminMiddleware
+func currentAdminID(r *http.Request) int64 {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return p.ID
+	}
+	return 0
+}
+
 // AdminStats - big picture numbers for admin dashboard
 type AdminStats struct {
 	TotalUsers       int `json:"total_users"`
@@ -75,7 +86,7 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		searchTerm := "%" + search + "%"
 		rows, err = database.Query(`
 			SELECT u.id, u.username, u.email, u.profile_picture, u.aboutme,
-				   u.profession, u.location, u.swaps, u.is_admin, u.created_at
+				   u.profession, u.location, u.swaps, u.is_admin, u.created_at, u.last_login_at
 			FROM users u
 			WHERE u.username LIKE ? OR u.email LIKE ?
 			ORDER BY u.created_at DESC
@@ -83,7 +94,7 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	} else {
 		rows, err = database.Query(`
 			SELECT u.id, u.username, u.email, u.profile_picture, u.aboutme,
-				   u.profession, u.location, u.swaps, u.is_admin, u.created_at
+				   u.profession, u.location, u.swaps, u.is_admin, u.created_at, u.last_login_at
 			FROM users u
 			ORDER BY u.created_at DESC
 		`)
@@ -111,12 +122,13 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 			Swaps          int
 			IsAdmin        int
 			CreatedAt      time.Time
+			LastLoginAt    sql.NullTime
 		}
 
 		err := rows.Scan(
 			&user.ID, &user.Username, &user.Email, &user.ProfilePicture,
 			&user.AboutMe, &user.Profession, &user.Location, &user.Swaps,
-			&user.IsAdmin, &user.CreatedAt,
+			&user.IsAdmin, &user.CreatedAt, &user.LastLoginAt,
 		)
 		if err != nil {
 			utils.HandleError(err)
@@ -134,6 +146,7 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 			"swaps":           user.Swaps,
 			"is_admin":        user.IsAdmin == 1,
 			"created_at":      user.CreatedAt.Format("2006-01-02"),
+			"last_login_at":   nil,
 		}
 
 		if user.AboutMe.Valid {
@@ -145,6 +158,9 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		if user.Location.Valid {
 			userMap["location"] = user.Location.String
 		}
+		if user.LastLoginAt.Valid {
+			userMap["last_login_at"] = user.LastLoginAt.Time
+		}
 
 		users = append(users, userMap)
 	}
@@ -177,8 +193,7 @@ func ToggleUserAdmin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Safety: cannot remove own admin status
-	adminID, ok := r.Context().Value("userID").(int)
-	if ok && adminID == req.UserID && !req.SetAdmin {
+	if currentAdminID(r) == int64(req.UserID) && !req.SetAdmin {
 		utils.SendJSONResponse(w, http.StatusForbidden, map[string]string{
 			"error": "Cannot remove your own admin privileges",
 		})
@@ -232,8 +247,7 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Safety: cannot delete yourself
-	adminID, ok := r.Context().Value("userID").(int)
-	if ok && adminID == req.UserID {
+	if currentAdminID(r) == int64(req.UserID) {
 		utils.SendJSONResponse(w, http.StatusForbidden, map[string]string{
 			"error": "Cannot delete your own account",
 		})
@@ -523,6 +537,7 @@ func DeleteSkill(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateUserSwaps - modify user's available swaps (admin only)
+// Requires a reason; the change is recorded in the ledger with the acting admin.
 func UpdateUserSwaps(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.SendJSONResponse(w, http.StatusMethodNotAllowed, map[string]string{
@@ -532,8 +547,9 @@ func UpdateUserSwaps(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		UserID int `json:"user_id"`
-		Amount int `json:"amount"`
+		UserID int    `json:"user_id"`
+		Amount int    `json:"amount"`
+		Reason string `json:"reason"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -543,12 +559,10 @@ func UpdateUserSwaps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := database.Execute("UPDATE users SET swaps = swaps + ? WHERE id = ?", req.Amount, req.UserID)
+	adminID := currentAdminID(r)
+	err := swaps.Adjust(int64(req.UserID), req.Amount, adminID, req.Reason)
 	if err != nil {
-		utils.HandleError(err)
-		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
-			"error": "Failed to update user swaps",
-		})
+		sendAdjustmentError(w, err)
 		return
 	}
 
@@ -558,6 +572,63 @@ func UpdateUserSwaps(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// BulkGrantSwaps - grant swaps to every user teaching a skill (admin only)
+// Users the grant would take past the balance cap are skipped and listed in the response.
+func BulkGrantSwaps(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SkillID int    `json:"skill_id"`
+		Amount  int    `json:"amount"`
+		Reason  string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SkillID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid request data",
+		})
+		return
+	}
+
+	adminID := currentAdminID(r)
+	result, err := swaps.GrantToSkill(int64(req.SkillID), req.Amount, adminID, req.Reason)
+	if err != nil {
+		sendAdjustmentError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, result)
+}
+
+// sendAdjustmentError maps swap adjustment errors to HTTP responses
+func sendAdjustmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, swaps.ErrReasonRequired), errors.Is(err, swaps.ErrInvalidRequest):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, swaps.ErrInvalidAmount):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Amount must not be zero",
+		})
+	case errors.Is(err, swaps.ErrInsufficientCredits):
+		utils.SendJSONResponse(w, http.StatusPaymentRequired, map[string]string{
+			"error": "User does not have that many swaps",
+		})
+	case errors.Is(err, swaps.ErrBalanceCapReached):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{
+			"error": "Amount would take the user past the swap balance cap",
+		})
+	case errors.Is(err, swaps.ErrUserNotFound):
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{
+			"error": "User not found",
+		})
+	default:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update user swaps",
+		})
+	}
+}
+
 // GetSystemHealth - check all systems go (admin only)
 func GetSystemHealth(w http.ResponseWriter, r *http.Request) {
 	health := map[string]interface{}{
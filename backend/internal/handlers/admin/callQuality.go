@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"database/sql"
+	"net/http"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/utils"
+	"strconv"
+	"time"
+)
+
+// Thresholds above which a single quality report counts as a poor call
+const (
+	poorPacketLoss = 0.05
+	poorRTTMs      = 400
+)
+
+// CallQualityAggregate - averaged call quality over a set of reports
+type CallQualityAggregate struct {
+	Sessions        int        `json:"sessions"`
+	Reports         int        `json:"reports"`
+	PoorReports     int        `json:"poor_reports"`
+	AvgRTTMs        *float64   `json:"avg_rtt_ms"`
+	MaxRTTMs        *float64   `json:"max_rtt_ms"`
+	AvgPacketLoss   *float64   `json:"avg_packet_loss"`
+	AvgJitterMs     *float64   `json:"avg_jitter_ms"`
+	AvgInboundKbps  *float64   `json:"avg_inbound_kbps"`
+	AvgOutboundKbps *float64   `json:"avg_outbound_kbps"`
+	RelayShare      float64    `json:"relay_share"` // fraction of reports routed through a TURN relay
+	LastReportAt    *time.Time `json:"last_report_at"`
+}
+
+// aggregateColumns - the SELECT list matching scanAggregate
+var aggregateColumns = `
+	COUNT(DISTINCT q.session_id), COUNT(*),
+	SUM(COALESCE(q.packet_loss, 0) > ` + strconv.FormatFloat(poorPacketLoss, 'f', -1, 64) + ` OR COALESCE(q.rtt_ms, 0) > ` + strconv.Itoa(poorRTTMs) + `),
+	AVG(q.rtt_ms), MAX(q.rtt_ms), AVG(q.packet_loss), AVG(q.jitter_ms), AVG(q.inbound_kbps), AVG(q.outbound_kbps),
+	SUM(COALESCE(q.local_candidate_type, '') = 'relay' OR COALESCE(q.remote_candidate_type, '') = 'relay') / COUNT(*),
+	MAX(q.reported_at)`
+
+// scanAggregate - read the aggregateColumns after any leading columns
+func scanAggregate(rows *sql.Rows, leading ...any) (CallQualityAggregate, error) {
+	var a CallQualityAggregate
+	var avgRTT, maxRTT, loss, jitter, inbound, outbound, relay sql.NullFloat64
+	var last sql.NullTime
+	dest := append(leading, &a.Sessions, &a.Reports, &a.PoorReports,
+		&avgRTT, &maxRTT, &loss, &jitter, &inbound, &outbound, &relay, &last)
+	if err := rows.Scan(dest...); err != nil {
+		return a, err
+	}
+	a.AvgRTTMs = nullFloat(avgRTT)
+	a.MaxRTTMs = nullFloat(maxRTT)
+	a.AvgPacketLoss = nullFloat(loss)
+	a.AvgJitterMs = nullFloat(jitter)
+	a.AvgInboundKbps = nullFloat(inbound)
+	a.AvgOutboundKbps = nullFloat(outbound)
+	a.RelayShare = relay.Float64
+	if last.Valid {
+		a.LastReportAt = &last.Time
+	}
+	return a, nil
+}
+
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// qualityWindow - read ?days= (default 7, max 90) and ?limit= (default 50, max 200)
+func qualityWindow(r *http.Request) (days int, limit int) {
+	days, limit = 7, 50
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= 90 {
+		days = d
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	return days, limit
+}
+
+// GetCallQualityByRoom - call quality per signaling room, worst packet loss first (admin only)
+// Optional: ?room=xxx to look at a single room
+func GetCallQualityByRoom(w http.ResponseWriter, r *http.Request) {
+	days, limit := qualityWindow(r)
+	query := `SELECT q.room_id,` + aggregateColumns + `
+		FROM call_quality_reports q
+		WHERE q.reported_at >= DATE_SUB(NOW(), INTERVAL ? DAY)`
+	args := []any{days}
+	if room := r.URL.Query().Get("room"); room != "" {
+		query += " AND q.room_id = ?"
+		args = append(args, room)
+	}
+	query += " GROUP BY q.room_id ORDER BY AVG(q.packet_loss) DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve call quality",
+		})
+		return
+	}
+	defer rows.Close()
+
+	rooms := []map[string]interface{}{}
+	for rows.Next() {
+		var roomID string
+		a, err := scanAggregate(rows, &roomID)
+		if err != nil {
+			utils.HandleError(err)
+			continue
+		}
+		rooms = append(rooms, map[string]interface{}{
+			"room_id": roomID,
+			"quality": a,
+		})
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"rooms": rooms,
+		"days":  days,
+	})
+}
+
+// GetCallQualityByUser - call quality per reporting user, worst packet loss first (admin only)
+// Optional: ?user_id=xxx to look at a single user
+func GetCallQualityByUser(w http.ResponseWriter, r *http.Request) {
+	days, limit := qualityWindow(r)
+	query := `SELECT q.user_id, u.username,` + aggregateColumns + `
+		FROM call_quality_reports q
+		JOIN users u ON u.id = q.user_id
+		WHERE q.reported_at >= DATE_SUB(NOW(), INTERVAL ? DAY)`
+	args := []any{days}
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		id, err := strconv.Atoi(userID)
+		if err != nil {
+			utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+				"error": "Invalid user ID",
+			})
+			return
+		}
+		query += " AND q.user_id = ?"
+		args = append(args, id)
+	}
+	query += " GROUP BY q.user_id, u.username ORDER BY AVG(q.packet_loss) DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve call quality",
+		})
+		return
+	}
+	defer rows.Close()
+
+	users := []map[string]interface{}{}
+	for rows.Next() {
+		var userID int
+		var username string
+		a, err := scanAggregate(rows, &userID, &username)
+		if err != nil {
+			utils.HandleError(err)
+			continue
+		}
+		users = append(users, map[string]interface{}{
+			"user_id":  userID,
+			"username": username,
+			"quality":  a,
+		})
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"days":  days,
+	})
+}
+
+// GetCallQualityReports - every quality report of one call session, oldest first (admin only)
+// Required: ?id=xxx call session ID
+func GetCallQualityReports(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid session ID",
+		})
+		return
+	}
+
+	rows, err := database.Query(`
+		SELECT q.user_id, u.username, q.reported_at, q.rtt_ms, q.packet_loss, q.jitter_ms,
+			   q.inbound_kbps, q.outbound_kbps, q.local_candidate_type, q.remote_candidate_type
+		FROM call_quality_reports q
+		JOIN users u ON u.id = q.user_id
+		WHERE q.session_id = ?
+		ORDER BY q.reported_at ASC`, sessionID)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve quality reports",
+		})
+		return
+	}
+	defer rows.Close()
+
+	reports := []map[string]interface{}{}
+	for rows.Next() {
+		var userID int
+		var username string
+		var reportedAt time.Time
+		var rtt, loss, jitter, inbound, outbound sql.NullFloat64
+		var local, remote sql.NullString
+		if err := rows.Scan(&userID, &username, &reportedAt, &rtt, &loss, &jitter, &inbound, &outbound, &local, &remote); err != nil {
+			utils.HandleError(err)
+			continue
+		}
+		reports = append(reports, map[string]interface{}{
+			"user_id":               userID,
+			"username":              username,
+			"reported_at":           reportedAt,
+			"rtt_ms":                nullFloat(rtt),
+			"packet_loss":           nullFloat(loss),
+			"jitter_ms":             nullFloat(jitter),
+			"inbound_kbps":          nullFloat(inbound),
+			"outbound_kbps":         nullFloat(outbound),
+			"local_candidate_type":  local.String,
+			"remote_candidate_type": remote.String,
+		})
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"reports": reports,
+		"count":   len(reports),
+	})
+}
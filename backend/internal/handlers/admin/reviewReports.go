@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"skillswap/backend/internal/handlers/reviews"
+	"skillswap/backend/internal/utils"
+)
+
+// GetReviewReports - open reports of abusive reviews, oldest first (admin only)
+func GetReviewReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := reviews.OpenReports()
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve review reports",
+		})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"reports": reports,
+	})
+}
+
+// ResolveReviewReport - dismiss a review report or remove the reported review (admin only)
+func ResolveReviewReport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ReportID int64  `json:"report_id"`
+		Remove   bool   `json:"remove"`
+		Note     string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReportID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid request data",
+		})
+		return
+	}
+
+	adminID := currentAdminID(r)
+	if err := reviews.ResolveReport(req.ReportID, adminID, req.Remove, req.Note); err != nil {
+		reviews.SendReviewError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"message": "Review report resolved",
+	})
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"skillswap/backend/internal/handlers/skills"
+	"skillswap/backend/internal/utils"
+)
+
+// GetSkillVerificationRequests - verification requests waiting for review, oldest first (admin only)
+func GetSkillVerificationRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := skills.PendingVerifications()
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve verification requests",
+		})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"requests": requests,
+	})
+}
+
+// ReviewSkillVerification - approve or reject a skill verification request (admin only)
+func ReviewSkillVerification(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RequestID int64  `json:"request_id"`
+		Approve   bool   `json:"approve"`
+		Note      string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RequestID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid request data",
+		})
+		return
+	}
+
+	adminID := currentAdminID(r)
+	if err := skills.ReviewVerification(req.RequestID, adminID, req.Approve, req.Note); err != nil {
+		skills.SendVerificationError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"message": "Verification request reviewed",
+	})
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skillswap/backend/internal/handlers/swaps"
+	"skillswap/backend/internal/utils"
+	"strings"
+)
+
+// GetSwapDisputes - disputed swaps waiting for an admin decision, oldest first (admin only)
+func GetSwapDisputes(w http.ResponseWriter, r *http.Request) {
+	disputes, err := swaps.ListDisputes()
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve swap disputes",
+		})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"disputes": disputes,
+	})
+}
+
+// ResolveSwapDispute - settle a disputed swap's escrow (admin only)
+// teacher_amount credits go to the teacher and the rest back to the learner;
+// zero reverses the swap, the full escrow completes it.
+func ResolveSwapDispute(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RequestID     int64  `json:"request_id"`
+		TeacherAmount int    `json:"teacher_amount"`
+		Note          string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RequestID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "Invalid request data",
+		})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Note == "" {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": "A resolution note is required",
+		})
+		return
+	}
+
+	adminID := currentAdminID(r)
+	request, err := swaps.ResolveDispute(req.RequestID, adminID, req.TeacherAmount, req.Note)
+	switch {
+	case errors.Is(err, swaps.ErrRequestNotFound):
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{
+			"error": "Swap request not found",
+		})
+	case errors.Is(err, swaps.ErrInvalidTransition), errors.Is(err, swaps.ErrEscrowNotHeld):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{
+			"error": "Swap is not disputed",
+		})
+	case errors.Is(err, swaps.ErrInvalidSplit):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case err != nil:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to resolve swap dispute",
+		})
+	default:
+		utils.SendJSONResponse(w, http.StatusOK, request)
+	}
+}
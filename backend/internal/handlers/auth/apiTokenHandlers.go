@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"skillswap/backend/internal/utils"
+)
+
+// GetAPITokens lists the session user's API tokens and the scopes tokens can have
+func GetAPITokens(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	tokens, err := APITokens(userID)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve API tokens"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"tokens": tokens, "scopes": Scopes})
+}
+
+// CreateAPITokenRequest makes an API token for the session user.
+// Expects {"name": "...", "scopes": ["courses:write"], "expires_in_days": 30}; the token is only returned now.
+func CreateAPITokenRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	lifetime := time.Duration(body.ExpiresInDays) * 24 * time.Hour
+	token, t, err := CreateAPIToken(userID, body.Name, body.Scopes, lifetime)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrScopeNotAllowed):
+			utils.SendJSONResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrTokenName), errors.Is(err, ErrNoScopes), errors.Is(err, ErrUnknownScope),
+			errors.Is(err, ErrTokenLifetime), errors.Is(err, ErrTooManyTokens):
+			utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			utils.HandleError(err)
+			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create API token"})
+		}
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"token":   token,
+		"details": t,
+		"message": "Copy the token now, it will not be shown again",
+	})
+}
+
+// RevokeAPITokenRequest stops one of the session user's API tokens from working. Expects {"id": 1}.
+func RevokeAPITokenRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := RevokeAPIToken(userID, body.ID); err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke API token"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "API token revoked"})
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/utils"
+)
+
+// apiTokenPrefix starts every API token so leaked tokens are easy to recognise
+const apiTokenPrefix = "ssp_"
+
+// maxAPITokens is how many unrevoked tokens a user may have
+const maxAPITokens = 20
+
+var (
+	ErrInvalidToken      = errors.New("invalid or expired API token")
+	ErrInsufficientScope = errors.New("the API token lacks the scope this route needs")
+	ErrTokenNotFound     = errors.New("API token not found")
+	ErrUnknownScope      = errors.New("unknown API token scope")
+	ErrScopeNotAllowed   = errors.New("only admins may create tokens with the admin scope")
+	ErrTokenName         = errors.New("a token needs a name of at most 100 characters")
+	ErrNoScopes          = errors.New("a token needs at least one scope")
+	ErrTokenLifetime     = errors.New("token lifetime is out of range")
+	ErrTooManyTokens     = errors.New("too many API tokens, revoke one first")
+	ErrSessionRequired   = errors.New("this route cannot be used with an API token")
+)
+
+// API token scopes
+const (
+	ScopeProfileWrite = "profile:write"
+	ScopeCoursesWrite = "courses:write"
+	ScopeChatRead     = "chat:read"
+	ScopeChatWrite    = "chat:write"
+	ScopeSwapsRead    = "swaps:read"
+	ScopeSwapsWrite   = "swaps:write"
+	ScopeSkillsWrite  = "skills:write"
+	ScopeReviewsWrite = "reviews:write"
+	ScopeAdmin        = "admin"
+)
+
+// Scope is a permission an API token can be given
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Scopes are the permissions API tokens can be given, in the order they are shown
+var Scopes = []Scope{
+	{ScopeProfileWrite, "Update your profile and profile picture"},
+	{ScopeCoursesWrite, "Create courses and upload course material"},
+	{ScopeChatRead, "Read your chats and messages"},
+	{ScopeChatWrite, "Start chats and send messages"},
+	{ScopeSwapsRead, "Read your swaps, swap history, reviews and matches"},
+	{ScopeSwapsWrite, "Request, answer and complete swaps"},
+	{ScopeSkillsWrite, "Endorse skills and request skill verification"},
+	{ScopeReviewsWrite, "Write and report reviews"},
+	{ScopeAdmin, "Use the admin API (admins only)"},
+}
+
+// APIToken describes a personal access token. The token itself is only shown when it is created.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	Expired    bool       `json:"expired"`
+}
+
+// CreateAPIToken makes a new token for the user and returns it. A zero lifetime uses the default.
+func CreateAPIToken(userID int64, name string, scopes []string, lifetime time.Duration) (string, *APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return "", nil, ErrTokenName
+	}
+	maxLifetime, defaultLifetime := config.APITokenMaxLifetime()
+	if lifetime == 0 {
+		lifetime = defaultLifetime
+	}
+	if lifetime < 0 || lifetime > maxLifetime {
+		return "", nil, ErrTokenLifetime
+	}
+
+	var isAdmin bool
+	err := database.QueryRow("SELECT is_admin FROM users WHERE id = ?", userID).Scan(&isAdmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrUserNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	scopes, err = checkScopes(scopes, isAdmin)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var count int
+	if err := database.QueryRow(
+		"SELECT COUNT(*) FROM api_tokens WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now(),
+	).Scan(&count); err != nil {
+		return "", nil, err
+	}
+	if count >= maxAPITokens {
+		return "", nil, ErrTooManyTokens
+	}
+
+	random, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := apiTokenPrefix + random
+	t := &APIToken{
+		Name:      name,
+		Prefix:    token[:len(apiTokenPrefix)+8],
+		Scopes:    scopes,
+		CreatedAt: now(),
+		ExpiresAt: now().Add(lifetime),
+	}
+	result, err := database.Execute(
+		"INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		userID, t.Name, hashToken(token), t.Prefix, strings.Join(scopes, " "), t.CreatedAt, t.ExpiresAt,
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	if t.ID, err = result.LastInsertId(); err != nil {
+		return "", nil, err
+	}
+	return token, t, nil
+}
+
+// checkScopes returns the requested scopes without duplicates, in catalogue order
+func checkScopes(requested []string, isAdmin bool) ([]string, error) {
+	wanted := make(map[string]bool)
+	for _, s := range requested {
+		wanted[strings.TrimSpace(s)] = true
+	}
+	var scopes []string
+	for _, s := range Scopes {
+		if !wanted[s.Name] {
+			continue
+		}
+		if s.Name == ScopeAdmin && !isAdmin {
+			return nil, ErrScopeNotAllowed
+		}
+		scopes = append(scopes, s.Name)
+		delete(wanted, s.Name)
+	}
+	if len(wanted) > 0 {
+		return nil, ErrUnknownScope
+	}
+	if len(scopes) == 0 {
+		return nil, ErrNoScopes
+	}
+	return scopes, nil
+}
+
+// APITokens lists the user's tokens that have not been revoked, newest first
+func APITokens(userID int64) ([]APIToken, error) {
+	rows, err := database.Query(`
+		SELECT id, name, token_prefix, scopes, created_at, expires_at, last_used_at, COALESCE(last_used_ip, '')
+		FROM api_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.Prefix, &scopes, &t.CreatedAt, &t.ExpiresAt, &lastUsed, &t.LastUsedIP); err != nil {
+			return nil, err
+		}
+		t.Scopes = strings.Fields(scopes)
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		t.Expired = !t.ExpiresAt.After(now())
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken stops one of the user's tokens from working
+func RevokeAPIToken(userID, tokenID int64) error {
+	result, err := database.Execute(
+		"UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		now(), tokenID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// revokeAPITokens stops all of the user's tokens from working
+func revokeAPITokens(userID int64) error {
+	_, err := database.Execute("UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now(), userID)
+	return err
+}
+
+// PurgeAPITokens deletes tokens that were revoked more than a day ago or expired more than 30 days ago
+func PurgeAPITokens() (int64, error) {
+	result, err := database.Execute(
+		"DELETE FROM api_tokens WHERE revoked_at < ? OR expires_at < ?",
+		now().Add(-24*time.Hour), now().Add(-30*24*time.Hour),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// authenticateToken resolves the principal behind an API token and records its use
+func authenticateToken(req *http.Request, token string) (*Principal, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	p := &Principal{Roles: []string{RoleUser}}
+	var isAdmin bool
+	var scopes string
+	err := database.QueryRow(`
+		SELECT t.id, t.scopes, u.id, u.username, u.email, u.email_verified_at IS NOT NULL, u.is_admin
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND t.revoked_at IS NULL AND t.expires_at > ?`, hashToken(token), now()).
+		Scan(&p.TokenID, &scopes, &p.ID, &p.Username, &p.Email, &p.EmailVerified, &isAdmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Scopes = strings.Fields(scopes)
+	if isAdmin {
+		p.Roles = append(p.Roles, RoleAdmin)
+	}
+
+	// Scripts may call many times a minute, so the last use is only written once a minute
+	_, err = database.Execute(
+		"UPDATE api_tokens SET last_used_at = ?, last_used_ip = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+		now(), clientIP(req), p.TokenID, now().Add(-time.Minute),
+	)
+	if err != nil {
+		utils.HandleError(err)
+	}
+	return p, nil
+}
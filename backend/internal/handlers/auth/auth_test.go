@@ -3,15 +3,25 @@ package auth
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/credentials"
 	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/mailer"
 	"skillswap/backend/internal/models"
+	"skillswap/backend/internal/oidc"
+	"skillswap/backend/internal/oidc/oidctest"
+	"skillswap/backend/internal/totp"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 )
 
@@ -25,6 +35,8 @@ func TestMain(m *testing.M) {
 
 	// Override the global store for testing
 	Store = NewTestCookieStore()
+	// Failed logins of earlier runs would slow down this one
+	database.Execute("DELETE FROM login_throttles")
 
 	code := m.Run()
 
@@ -344,3 +356,716 @@ func TestRemoveSession(t *testing.T) {
 		}
 	})
 }
+
+func TestDBStoreRevokesSessions(t *testing.T) {
+	database.ClearTestData()
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	store := NewDBStore([]byte("test-session-key-for-testing-only"))
+
+	// login creates a session for a device and returns its cookie
+	login := func(userAgent string) string {
+		req := httptest.NewRequest("POST", "/api/login", nil)
+		req.Header.Set("User-Agent", userAgent)
+		rr := httptest.NewRecorder()
+		session, _ := store.New(req, "authentication")
+		session.Values["authenticated"] = true
+		session.Values["email"] = "test@example.com"
+		if err := session.Save(req, rr); err != nil {
+			t.Fatalf("Failed to save session: %v", err)
+		}
+		return rr.Header().Get("Set-Cookie")
+	}
+	// authenticated reports whether the cookie still loads a logged in session
+	authenticated := func(cookie string) (bool, string) {
+		req := httptest.NewRequest("GET", "/api/cookieUser", nil)
+		req.Header.Set("Cookie", cookie)
+		session, err := store.New(req, "authentication")
+		if err != nil {
+			t.Fatalf("Failed to load session: %v", err)
+		}
+		return session.Values["authenticated"] == true, session.ID
+	}
+
+	laptop := login("Mozilla/5.0 (Windows NT 10.0; Win64; x64) Firefox/120.0")
+	phone := login("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Safari/604.1")
+	tablet := login("Mozilla/5.0 (Linux; Android 14) Chrome/120.0")
+
+	ok, laptopToken := authenticated(laptop)
+	if !ok {
+		t.Fatal("Expected the laptop session to be authenticated")
+	}
+	devices, err := Devices(userID, laptopToken)
+	if err != nil {
+		t.Fatalf("Devices failed: %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("Expected 3 sessions, got %+v", devices)
+	}
+	var phoneID int64
+	for _, d := range devices {
+		if d.Device == "Safari on iOS" {
+			phoneID = d.ID
+		}
+		if d.Current != (d.Device == "Firefox on Windows") {
+			t.Errorf("Expected only the laptop to be the current session, got %+v", d)
+		}
+	}
+
+	if err := Revoke(userID, phoneID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if ok, _ := authenticated(phone); ok {
+		t.Error("Expected the revoked phone session to be rejected")
+	}
+	if err := Revoke(userID, phoneID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	if n, err := RevokeOthers(userID, laptopToken); err != nil || n != 1 {
+		t.Errorf("Expected to revoke 1 other session, got %d (%v)", n, err)
+	}
+	if ok, _ := authenticated(tablet); ok {
+		t.Error("Expected the tablet session to be rejected")
+	}
+	if ok, _ := authenticated(laptop); !ok {
+		t.Error("Expected the current session to survive")
+	}
+}
+
+func TestCurrentUserPrefersContextPrincipal(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/matches", nil)
+	if _, err := CurrentUser(req); !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("Expected ErrNotAuthenticated without a session, got %v", err)
+	}
+
+	admin := &Principal{ID: 7, Username: "testadmin", Roles: []string{RoleUser, RoleAdmin}}
+	req = req.WithContext(WithPrincipal(req.Context(), admin))
+	id, err := GetSessionUserID(req)
+	if err != nil || id != 7 {
+		t.Errorf("Expected user 7 from the context, got %d (%v)", id, err)
+	}
+	if p, ok := PrincipalFromContext(req.Context()); !ok || !p.IsAdmin() {
+		t.Errorf("Expected an admin principal in the context, got %+v", p)
+	}
+}
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestPasswordResetIsSingleUseAndRevokesSessions(t *testing.T) {
+	database.ClearTestData()
+	resetLimiter = hourlyLimiter{}
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	mail := &recordingMailer{}
+	previous := mailer.Default
+	mailer.Default = mail
+	defer func() { mailer.Default = previous }()
+
+	if err := StartPasswordReset("nobody@example.com", "192.0.2.1"); err != nil || len(mail.sent) != 0 {
+		t.Fatalf("Expected unknown emails to be ignored silently, got %v and %d messages", err, len(mail.sent))
+	}
+	if err := StartPasswordReset("test@example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("StartPasswordReset failed: %v", err)
+	}
+	if len(mail.sent) != 1 || mail.sent[0].To != "test@example.com" {
+		t.Fatalf("Expected one reset email, got %+v", mail.sent)
+	}
+	body := mail.sent[0].Body
+	token := strings.Fields(body[strings.Index(body, "token=")+len("token="):])[0]
+
+	_, err = database.Execute(
+		"INSERT INTO user_sessions (token_hash, user_id, data, expires_at) VALUES (?, ?, '', NOW() + INTERVAL 1 DAY)",
+		hashToken("testsession"), userID,
+	)
+	if err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	if err := FinishPasswordReset(token, "short"); !errors.Is(err, credentials.ErrTooShort) {
+		t.Errorf("Expected ErrTooShort, got %v", err)
+	}
+	if err := FinishPasswordReset(token, "newpassword123"); err != nil {
+		t.Fatalf("FinishPasswordReset failed: %v", err)
+	}
+	if err := FinishPasswordReset(token, "anotherpassword"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("Expected the token to be single-use, got %v", err)
+	}
+
+	var hash string
+	if err := database.QueryRow("SELECT password_hash FROM users WHERE id = ?", userID).Scan(&hash); err != nil {
+		t.Fatalf("Failed to read password: %v", err)
+	}
+	if ok, _ := credentials.Verify(hash, "newpassword123"); !ok {
+		t.Error("Expected the new password to be set")
+	}
+	devices, err := Devices(userID, "")
+	if err != nil {
+		t.Fatalf("Devices failed: %v", err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("Expected every session to be revoked, got %+v", devices)
+	}
+}
+
+func TestHourlyLimiter(t *testing.T) {
+	var l hourlyLimiter
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !l.allow("email:a", 3, now) {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if l.allow("email:a", 3, now) {
+		t.Error("Expected the fourth request within the hour to be refused")
+	}
+	if !l.allow("email:b", 3, now) {
+		t.Error("Expected other keys to be counted separately")
+	}
+	if !l.allow("email:a", 3, now.Add(time.Hour)) {
+		t.Error("Expected requests to be allowed again after an hour")
+	}
+}
+
+// mailedToken returns the token from the link in the last message sent
+func mailedToken(t *testing.T, mail *recordingMailer) string {
+	t.Helper()
+	if len(mail.sent) == 0 {
+		t.Fatal("Expected an email to be sent")
+	}
+	body := mail.sent[len(mail.sent)-1].Body
+	return strings.Fields(body[strings.Index(body, "token=")+len("token="):])[0]
+}
+
+func TestEmailChangeWaitsForVerification(t *testing.T) {
+	database.ClearTestData()
+	verifyLimiter = hourlyLimiter{}
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if _, err := database.InsertTestUser("testother", "taken@example.com", "testpassword123"); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	mail := &recordingMailer{}
+	previous := mailer.Default
+	mailer.Default = mail
+	defer func() { mailer.Default = previous }()
+
+	if err := ResendEmailVerification(userID); err != nil {
+		t.Fatalf("ResendEmailVerification failed: %v", err)
+	}
+	if _, email, err := VerifyEmail(mailedToken(t, mail)); err != nil || email != "test@example.com" {
+		t.Fatalf("Expected the current address to be verified, got %q (%v)", email, err)
+	}
+	if err := ResendEmailVerification(userID); !errors.Is(err, ErrNothingToVerify) {
+		t.Errorf("Expected ErrNothingToVerify, got %v", err)
+	}
+
+	if err := ChangeEmail(userID, "taken@example.com"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+	if err := ChangeEmail(userID, "testnew@example.com"); err != nil {
+		t.Fatalf("ChangeEmail failed: %v", err)
+	}
+	if mail.sent[len(mail.sent)-1].To != "testnew@example.com" {
+		t.Errorf("Expected the link to go to the new address, got %+v", mail.sent[len(mail.sent)-1])
+	}
+	if id, err := database.GetUserIDFromEmail("test@example.com"); err != nil || id != userID {
+		t.Errorf("Expected the old address to stay active until confirmed, got %d (%v)", id, err)
+	}
+
+	if _, email, err := VerifyEmail(mailedToken(t, mail)); err != nil || email != "testnew@example.com" {
+		t.Fatalf("Expected the new address to be confirmed, got %q (%v)", email, err)
+	}
+	if id, err := database.GetUserIDFromEmail("testnew@example.com"); err != nil || id != userID {
+		t.Errorf("Expected the new address to be the login email, got %d (%v)", id, err)
+	}
+	if _, _, err := VerifyEmail(mailedToken(t, mail)); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("Expected the token to be single-use, got %v", err)
+	}
+}
+
+// postJSON calls the handler with a JSON body and the given cookies
+func postJSON(handler http.HandlerFunc, body interface{}, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/", bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	database.ClearTestData()
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	clock := time.Unix(1700000000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	secret, uri, err := BeginTOTPEnrollment(userID)
+	if err != nil {
+		t.Fatalf("BeginTOTPEnrollment failed: %v", err)
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Expected the URI to carry the secret, got %s", uri)
+	}
+	if enabled, _ := TwoFactorEnabled(userID); enabled {
+		t.Fatal("Expected two-factor authentication to wait for a first code")
+	}
+	if _, err := ConfirmTOTPEnrollment(userID, "000000"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected a wrong code to be refused, got %v", err)
+	}
+	code, _ := totp.Code(secret, clock)
+	recovery, err := ConfirmTOTPEnrollment(userID, code)
+	if err != nil {
+		t.Fatalf("ConfirmTOTPEnrollment failed: %v", err)
+	}
+	if len(recovery) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recovery))
+	}
+
+	login := models.UserInfo{Email: "test@example.com", Password: "testpassword123"}
+	rr := postJSON(Login, login, nil)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"two_factor_required":true`) {
+		t.Fatalf("Expected the login to ask for a code, got %d %s", rr.Code, rr.Body.String())
+	}
+	pending := rr.Result().Cookies()
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range pending {
+		req.AddCookie(c)
+	}
+	if _, err := Authenticate(req); err == nil {
+		t.Fatal("Expected the session to stay unauthenticated until the code is given")
+	}
+
+	// The code used for enrollment cannot log in again
+	if rr := postJSON(LoginSecondFactor, map[string]string{"code": code}, pending); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a used code to be refused, got %d", rr.Code)
+	}
+	clock = clock.Add(totp.Period)
+	code, _ = totp.Code(secret, clock)
+	rr = postJSON(LoginSecondFactor, map[string]string{"code": code}, pending)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the second step to log in, got %d %s", rr.Code, rr.Body.String())
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	for _, c := range rr.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	if p, err := Authenticate(req); err != nil || p.ID != userID {
+		t.Errorf("Expected to be logged in as %d, got %+v (%v)", userID, p, err)
+	}
+
+	if err := VerifySecondFactor(userID, recovery[0]); err != nil {
+		t.Errorf("Expected a recovery code to be accepted, got %v", err)
+	}
+	if err := VerifySecondFactor(userID, recovery[0]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected recovery codes to be single-use, got %v", err)
+	}
+	if err := VerifySecondFactor(userID, strings.ToUpper(strings.ReplaceAll(recovery[1], "-", ""))); err != nil {
+		t.Errorf("Expected recovery codes to ignore case and dashes, got %v", err)
+	}
+	if left, _ := RecoveryCodesLeft(userID); left != recoveryCodeCount-2 {
+		t.Errorf("Expected %d recovery codes left, got %d", recoveryCodeCount-2, left)
+	}
+
+	// A pending login expires
+	pending = postJSON(Login, login, nil).Result().Cookies()
+	clock = clock.Add(pendingLoginTTL + totp.Period)
+	code, _ = totp.Code(secret, clock)
+	if rr := postJSON(LoginSecondFactor, map[string]string{"code": code}, pending); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an expired login to be refused, got %d", rr.Code)
+	}
+
+	if err := RemoveTwoFactor(userID, code); err != nil {
+		t.Fatalf("RemoveTwoFactor failed: %v", err)
+	}
+	if enabled, _ := TwoFactorEnabled(userID); enabled {
+		t.Error("Expected two-factor authentication to be disabled")
+	}
+}
+
+func TestLoginRehashesLegacyPasswords(t *testing.T) {
+	database.ClearTestData()
+	// Test users are stored with the legacy MD5 hash
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	rr := postJSON(Login, models.UserInfo{Email: "test@example.com", Password: "testpassword123"}, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected a legacy password to log in, got %d %s", rr.Code, rr.Body.String())
+	}
+	var hash string
+	if err := database.QueryRow("SELECT password_hash FROM users WHERE id = ?", userID).Scan(&hash); err != nil {
+		t.Fatalf("Failed to read password: %v", err)
+	}
+	if ok, rehash := credentials.Verify(hash, "testpassword123"); !ok || rehash {
+		t.Errorf("Expected the hash to be upgraded, got %q", hash)
+	}
+
+	for _, token := range []string{"testsession", "othersession"} {
+		_, err := database.Execute(
+			"INSERT INTO user_sessions (token_hash, user_id, data, expires_at) VALUES (?, ?, '', NOW() + INTERVAL 1 DAY)",
+			hashToken(token), userID,
+		)
+		if err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+	apiToken, _, err := CreateAPIToken(userID, "sync", []string{ScopeSwapsRead}, 0)
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+
+	if err := ChangePassword(userID, "wrongpassword", "plum tractor window", "testsession"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+	if err := ChangePassword(userID, "testpassword123", "password123", "testsession"); !errors.Is(err, credentials.ErrBreached) {
+		t.Errorf("Expected ErrBreached, got %v", err)
+	}
+	if err := ChangePassword(userID, "testpassword123", "plum tractor window", "testsession"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+	if err := VerifyUserPassword(userID, "plum tractor window"); err != nil {
+		t.Errorf("Expected the new password to be set, got %v", err)
+	}
+
+	// Like a reset, a change logs out everything but the session that made it
+	devices, err := Devices(userID, "testsession")
+	if err != nil {
+		t.Fatalf("Devices failed: %v", err)
+	}
+	if len(devices) != 1 || !devices[0].Current {
+		t.Errorf("Expected only the current session to survive, got %+v", devices)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+	if _, err := Authenticate(req); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the API token to be revoked, got %v", err)
+	}
+}
+
+func TestLoginDelay(t *testing.T) {
+	s := config.LoginThrottleSettings{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures, 3, s); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	database.ClearTestData()
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	mail := &recordingMailer{}
+	previous := mailer.Default
+	mailer.Default = mail
+	clock := time.Unix(1700000000, 0)
+	now = func() time.Time { return clock }
+	defer func() {
+		mailer.Default = previous
+		now = time.Now
+		database.Execute("DELETE FROM login_throttles")
+	}()
+
+	s := config.LoginThrottle()
+	wrong := models.UserInfo{Email: "test@example.com", Password: "wrongpassword"}
+	for i := 1; i <= s.LockoutThreshold; i++ {
+		rr := postJSON(Login, wrong, nil)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected failure %d to be refused with 401, got %d %s", i, rr.Code, rr.Body.String())
+		}
+		if i == s.AccountFreeAttempts+1 {
+			// Past the free attempts the account has to wait before the next try
+			if rr := postJSON(Login, wrong, nil); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+				t.Errorf("Expected a delay after %d failures, got %d", i, rr.Code)
+			}
+		}
+		clock = clock.Add(s.MaxDelay)
+	}
+	if len(mail.sent) != 1 || mail.sent[0].To != "test@example.com" {
+		t.Fatalf("Expected one lockout notice, got %+v", mail.sent)
+	}
+
+	right := models.UserInfo{Email: "test@example.com", Password: "testpassword123"}
+	if rr := postJSON(Login, right, nil); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected a locked account to refuse even the right password, got %d", rr.Code)
+	}
+	if err := UnlockAccount(userID); err != nil {
+		t.Fatalf("UnlockAccount failed: %v", err)
+	}
+	if rr := postJSON(Login, right, nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected an unlocked account to log in, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	var lastLogin time.Time
+	var lastIP string
+	if err := database.QueryRow("SELECT last_login_at, last_login_ip FROM users WHERE id = ?", userID).Scan(&lastLogin, &lastIP); err != nil {
+		t.Fatalf("Failed to read last login: %v", err)
+	}
+	if !lastLogin.Equal(clock) || lastIP != "192.0.2.1" {
+		t.Errorf("Expected the login to be recorded at %v from 192.0.2.1, got %v from %s", clock, lastLogin, lastIP)
+	}
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	database.ClearTestData()
+	issuer := oidctest.NewIssuer("skillswap", "secret")
+	defer issuer.Close()
+	previous := Providers
+	Providers = map[string]*oidc.Provider{"company": {
+		Name:         "company",
+		Issuer:       issuer.URL(),
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "https://skillswap.test/api/oidc/company/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}}
+	defer func() { Providers = previous }()
+
+	// signIn runs the whole flow as a browser would and returns where it ends up
+	signIn := func(user oidctest.User) (string, []*http.Cookie) {
+		issuer.SignIn(user)
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/oidc/company/login?return_to=/matches", nil), map[string]string{"provider": "company"})
+		rr := httptest.NewRecorder()
+		OIDCLogin(rr, req)
+		if rr.Code != http.StatusFound {
+			t.Fatalf("Expected a redirect to the provider, got %d", rr.Code)
+		}
+		callback, err := issuer.Authorize(rr.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Authorize failed: %v", err)
+		}
+		req = mux.SetURLVars(httptest.NewRequest("GET", callback, nil), map[string]string{"provider": "company"})
+		for _, c := range rr.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		rr = httptest.NewRecorder()
+		OIDCCallback(rr, req)
+		if rr.Code != http.StatusFound {
+			t.Fatalf("Expected a redirect to the app, got %d", rr.Code)
+		}
+		return rr.Header().Get("Location"), rr.Result().Cookies()
+	}
+	authenticated := func(cookies []*http.Cookie) *Principal {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		p, _ := Authenticate(req)
+		return p
+	}
+
+	location, cookies := signIn(oidctest.User{Subject: "u-1", Email: "oidctest@example.com", EmailVerified: true, Name: "Test Ada"})
+	if location != config.AppURL()+"/matches" {
+		t.Errorf("Expected to return to /matches, got %s", location)
+	}
+	p := authenticated(cookies)
+	if p == nil || p.Email != "oidctest@example.com" || !p.EmailVerified {
+		t.Fatalf("Expected a verified new user to be signed in, got %+v", p)
+	}
+	if err := VerifyUserPassword(p.ID, ""); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected the new account to have no password, got %v", err)
+	}
+	if identities, err := Identities(p.ID); err != nil || len(identities) != 1 {
+		t.Fatalf("Expected one linked sign-in, got %+v (%v)", identities, err)
+	} else if err := UnlinkIdentity(p.ID, identities[0].ID); !errors.Is(err, ErrLastSignInMethod) {
+		t.Errorf("Expected the only sign-in method to stay, got %v", err)
+	}
+
+	// A verified email links to the existing verified account instead of creating another
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	if _, err := database.Execute("UPDATE users SET email_verified_at = NOW() WHERE id = ?", userID); err != nil {
+		t.Fatalf("Failed to verify test user: %v", err)
+	}
+	_, cookies = signIn(oidctest.User{Subject: "u-2", Email: "test@example.com", EmailVerified: true})
+	if p := authenticated(cookies); p == nil || p.ID != userID {
+		t.Errorf("Expected to sign in as the existing user %d, got %+v", userID, p)
+	}
+
+	// An unverified claim must not take over an account
+	location, cookies = signIn(oidctest.User{Subject: "u-3", Email: "test@example.com", EmailVerified: false})
+	if !strings.Contains(location, "/auth/login?error=") || authenticated(cookies) != nil {
+		t.Errorf("Expected an unverified email to be refused, got %s", location)
+	}
+	var users int
+	database.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", "test@example.com").Scan(&users)
+	if users != 1 {
+		t.Errorf("Expected no duplicate account, got %d", users)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	database.ClearTestData()
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	clock := time.Now().Truncate(time.Second)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	if _, _, err := CreateAPIToken(userID, "sync", []string{"courses:delete"}, 0); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("Expected ErrUnknownScope, got %v", err)
+	}
+	if _, _, err := CreateAPIToken(userID, "sync", []string{ScopeAdmin}, 0); !errors.Is(err, ErrScopeNotAllowed) {
+		t.Errorf("Expected only admins to get the admin scope, got %v", err)
+	}
+	token, created, err := CreateAPIToken(userID, "sync", []string{ScopeSwapsRead, ScopeChatRead, ScopeSwapsRead}, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, created.Prefix) || len(created.Scopes) != 2 {
+		t.Errorf("Unexpected token %s for %+v", token, created)
+	}
+
+	bearer := func(token string) (*Principal, error) {
+		req := httptest.NewRequest("GET", "/api/swaps/history", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return Authenticate(req)
+	}
+	p, err := bearer(token)
+	if err != nil {
+		t.Fatalf("Expected the token to authenticate, got %v", err)
+	}
+	if p.ID != userID || !p.ViaToken() || !p.HasScope(ScopeSwapsRead) || p.HasScope(ScopeCoursesWrite) {
+		t.Errorf("Unexpected principal %+v", p)
+	}
+	if _, err := bearer(token + "x"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an unknown token to be refused, got %v", err)
+	}
+	// Routes without the middleware cannot check scopes, so they only take sessions
+	req := httptest.NewRequest("GET", "/api/user", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if _, err := CurrentUser(req); !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("Expected CurrentUser to ignore API tokens, got %v", err)
+	}
+
+	tokens, err := APITokens(userID)
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("Expected one token, got %+v (%v)", tokens, err)
+	}
+	if tokens[0].LastUsedAt == nil || !tokens[0].LastUsedAt.Equal(clock) {
+		t.Errorf("Expected the use to be recorded at %v, got %v", clock, tokens[0].LastUsedAt)
+	}
+
+	clock = clock.Add(8 * 24 * time.Hour)
+	if _, err := bearer(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be refused, got %v", err)
+	}
+
+	token, created, _ = CreateAPIToken(userID, "uploads", []string{ScopeCoursesWrite}, 0)
+	if err := RevokeAPIToken(userID, created.ID); err != nil {
+		t.Fatalf("RevokeAPIToken failed: %v", err)
+	}
+	if _, err := bearer(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a revoked token to be refused, got %v", err)
+	}
+	if err := RevokeAPIToken(userID, created.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected ErrTokenNotFound, got %v", err)
+	}
+	// Resetting the password after a compromise also locks out scripts
+	token, _, _ = CreateAPIToken(userID, "backup", []string{ScopeSwapsRead}, 0)
+	mail := &recordingMailer{}
+	previous := mailer.Default
+	mailer.Default = mail
+	defer func() { mailer.Default = previous }()
+	resetLimiter = hourlyLimiter{}
+	if err := StartPasswordReset("test@example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("StartPasswordReset failed: %v", err)
+	}
+	if err := FinishPasswordReset(mailedToken(t, mail), "newpassword123"); err != nil {
+		t.Fatalf("FinishPasswordReset failed: %v", err)
+	}
+	if _, err := bearer(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a password reset to revoke API tokens, got %v", err)
+	}
+}
+
+func TestSecondFactorFailuresLockAccount(t *testing.T) {
+	database.ClearTestData()
+	userID, err := database.InsertTestUser("testuser", "test@example.com", "testpassword123")
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	mail := &recordingMailer{}
+	previous := mailer.Default
+	mailer.Default = mail
+	clock := time.Unix(1700000000, 0)
+	now = func() time.Time { return clock }
+	defer func() {
+		mailer.Default = previous
+		now = time.Now
+		database.Execute("DELETE FROM login_throttles")
+	}()
+
+	secret, _, err := BeginTOTPEnrollment(userID)
+	if err != nil {
+		t.Fatalf("BeginTOTPEnrollment failed: %v", err)
+	}
+	code, _ := totp.Code(secret, clock)
+	if _, err := ConfirmTOTPEnrollment(userID, code); err != nil {
+		t.Fatalf("ConfirmTOTPEnrollment failed: %v", err)
+	}
+
+	// Knowing the password, start a new pending login for every guess of the second factor
+	s := config.LoginThrottle()
+	right := models.UserInfo{Email: "test@example.com", Password: "testpassword123"}
+	for i := 1; i <= s.LockoutThreshold; i++ {
+		clock = clock.Add(s.MaxDelay)
+		rr := postJSON(Login, right, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected password login %d to ask for a code, got %d %s", i, rr.Code, rr.Body.String())
+		}
+		if rr := postJSON(LoginSecondFactor, map[string]string{"code": "zzzzz-zzzzz"}, rr.Result().Cookies()); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected wrong code %d to be refused, got %d %s", i, rr.Code, rr.Body.String())
+		}
+	}
+
+	clock = clock.Add(s.MaxDelay)
+	if rr := postJSON(Login, right, nil); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected wrong second factors to lock the account, got %d %s", rr.Code, rr.Body.String())
+	}
+	if len(mail.sent) != 1 {
+		t.Errorf("Expected one lockout notice, got %d", len(mail.sent))
+	}
+}
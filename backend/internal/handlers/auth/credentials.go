@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+
+	"skillswap/backend/internal/credentials"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/utils"
+)
+
+var ErrWrongPassword = errors.New("incorrect current password")
+
+// checkPassword verifies the password against the user's stored hash. A matching password
+// whose hash is legacy MD5 or was made with older settings is rehashed on the spot.
+func checkPassword(userID int64, storedHash, password string) bool {
+	ok, rehash := credentials.Verify(storedHash, password)
+	if ok && rehash {
+		hash, err := credentials.Hash(password)
+		if err == nil {
+			// Compare with the old hash so a password changed meanwhile is not overwritten
+			_, err = database.Execute("UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?", hash, userID, storedHash)
+		}
+		if err != nil {
+			utils.HandleError(err)
+		}
+	}
+	return ok
+}
+
+// VerifyUserPassword fails with ErrWrongPassword unless the password is the user's current one.
+// Accounts created through a sign-in provider have no password until they set one with a reset link.
+func VerifyUserPassword(userID int64, password string) error {
+	var hash string
+	err := database.QueryRow("SELECT COALESCE(password_hash, '') FROM users WHERE id = ?", userID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !checkPassword(userID, hash, password) {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// CheckNewPassword checks a password the user wants to set against the password policy
+func CheckNewPassword(userID int64, password string) error {
+	var username, email string
+	if err := database.QueryRow("SELECT username, email FROM users WHERE id = ?", userID).Scan(&username, &email); err != nil {
+		return err
+	}
+	return credentials.CheckPolicy(password, username, email)
+}
+
+// ChangePassword sets a new password once the current one is confirmed. Like a reset, it logs
+// the user out everywhere and revokes their API tokens, except for the session with keepSession.
+func ChangePassword(userID int64, current, password, keepSession string) error {
+	if err := VerifyUserPassword(userID, current); err != nil {
+		return err
+	}
+	if err := CheckNewPassword(userID, password); err != nil {
+		return err
+	}
+	hash, err := credentials.Hash(password)
+	if err != nil {
+		return err
+	}
+	if _, err := database.Execute("UPDATE users SET password_hash = ? WHERE id = ?", hash, userID); err != nil {
+		return err
+	}
+	if _, err := RevokeOthers(userID, keepSession); err != nil {
+		return err
+	}
+	return revokeAPITokens(userID)
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"skillswap/backend/internal/utils"
+)
+
+// SessionToken returns the token of the request's session
+func SessionToken(req *http.Request) string {
+	session, err := Store.Get(req, "authentication")
+	if err != nil {
+		return ""
+	}
+	return session.ID
+}
+
+// GetSessions lists the devices the session user is logged in on
+func GetSessions(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	devices, err := Devices(userID, SessionToken(req))
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve sessions"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"sessions": devices})
+}
+
+// RevokeSession logs the session user out on one device. Expects {"id": ...}.
+func RevokeSession(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := Revoke(userID, body.ID); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke session"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Session revoked"})
+}
+
+// RevokeOtherSessions logs the session user out everywhere except on this device
+func RevokeOtherSessions(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	revoked, err := RevokeOthers(userID, SessionToken(req))
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to revoke sessions"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"status": "ok", "revoked": revoked})
+}
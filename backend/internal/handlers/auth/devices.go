@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/database"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Device is one of a user's active sessions
+type Device struct {
+	ID         int64     `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// Devices lists the user's active sessions, most recently used first.
+// The session with the given token is marked as the current one.
+func Devices(userID int64, currentToken string) ([]Device, error) {
+	rows, err := database.Query(`
+		SELECT id, COALESCE(device, ''), COALESCE(user_agent, ''), COALESCE(ip_address, ''),
+		       created_at, last_seen_at, expires_at, token_hash = ?
+		FROM user_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC`, hashToken(currentToken), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		var d Device
+		if err := rows.Scan(&d.ID, &d.Device, &d.UserAgent, &d.IPAddress, &d.CreatedAt, &d.LastSeenAt, &d.ExpiresAt, &d.Current); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// Revoke ends one of the user's sessions
+func Revoke(userID, sessionID int64) error {
+	result, err := database.Execute(
+		"UPDATE user_sessions SET revoked_at = NOW() WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		sessionID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOthers ends all of the user's sessions except the one with the given token
+// and returns how many were ended
+func RevokeOthers(userID int64, currentToken string) (int64, error) {
+	result, err := database.Execute(
+		"UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = ? AND token_hash != ? AND revoked_at IS NULL",
+		userID, hashToken(currentToken),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeSessions deletes sessions that expired or were revoked more than a day ago
+func PurgeSessions() (int64, error) {
+	result, err := database.Execute(`
+		DELETE FROM user_sessions
+		WHERE expires_at < NOW() - INTERVAL 1 DAY OR revoked_at < NOW() - INTERVAL 1 DAY`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RunSessionCleanup periodically purges dead sessions and API tokens and stale login throttles.
+// It never returns.
+func RunSessionCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		if n, err := PurgeSessions(); err != nil {
+			log.Println("Auth: Failed to purge sessions:", err)
+		} else if n > 0 {
+			log.Printf("Auth: Purged %d sessions", n)
+		}
+		if _, err := PurgeLoginThrottles(); err != nil {
+			log.Println("Auth: Failed to purge login throttles:", err)
+		}
+		if _, err := PurgeAPITokens(); err != nil {
+			log.Println("Auth: Failed to purge API tokens:", err)
+		}
+	}
+}
+
+// deviceName describes the browser and operating system of a user agent, e.g. "Firefox on Windows"
+func deviceName(userAgent string) string {
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	system := "unknown system"
+	switch {
+	case strings.Contains(userAgent, "Windows"):
+		system = "Windows"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		system = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		system = "Android"
+	case strings.Contains(userAgent, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		system = "Linux"
+	}
+	return browser + " on " + system
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/mailer"
+	"skillswap/backend/internal/models"
+	"skillswap/backend/internal/utils"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrEmailTaken                = errors.New("email already in use")
+	ErrEmailNotVerified          = errors.New("email address not verified")
+	ErrInvalidVerificationToken  = errors.New("invalid or expired verification token")
+	ErrNothingToVerify           = errors.New("no email address is waiting for verification")
+	ErrTooManyVerificationEmails = errors.New("too many verification emails, please try again later")
+)
+
+var verifyLimiter hourlyLimiter
+
+// SendEmailVerification emails a verification link for the address to the user.
+// Links sent earlier stop working.
+func SendEmailVerification(userID int64, email string) error {
+	var username string
+	if err := database.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username); err != nil {
+		return err
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	ttl := config.EmailVerificationTTL()
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE email_verifications SET used_at = NOW() WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO email_verifications (user_id, email, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		userID, email, hashToken(token), time.Now().Add(ttl),
+	)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your SkillSwap email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm that this is your email address by opening this link within %d hours:\n\n"+
+				"%s/auth/verify-email?token=%s\n\nIf you didn't sign up for SkillSwap or change your email, ignore this email.\n",
+			username, int(ttl.Hours()), config.AppURL(), token,
+		),
+	})
+}
+
+// CheckEmailAvailable fails with ErrEmailTaken if another account uses the email
+func CheckEmailAvailable(userID int64, email string) error {
+	var taken int
+	err := database.QueryRow("SELECT COUNT(*) FROM users WHERE email = ? AND id != ?", strings.TrimSpace(email), userID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// ChangeEmail starts moving the user to a new email address. The current address keeps
+// working for login until the new one is confirmed through the emailed link.
+func ChangeEmail(userID int64, email string) error {
+	email = strings.TrimSpace(email)
+	var current string
+	if err := database.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&current); err != nil {
+		return err
+	}
+	if strings.EqualFold(email, current) {
+		return nil
+	}
+	if err := CheckEmailAvailable(userID, email); err != nil {
+		return err
+	}
+	return SendEmailVerification(userID, email)
+}
+
+// VerifyEmail confirms the address a verification token was sent to and returns the user
+// and their confirmed email. Confirming a changed address makes it the login email and
+// revokes the user's sessions, which were tied to the old one.
+func VerifyEmail(token string) (int64, string, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var email, current string
+	err = tx.QueryRow(`
+		SELECT v.user_id, v.email, u.email
+		FROM email_verifications v
+		JOIN users u ON u.id = v.user_id
+		WHERE v.token_hash = ? AND v.used_at IS NULL AND v.expires_at > NOW()
+		FOR UPDATE`, hashToken(token)).Scan(&userID, &email, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if email == current {
+		_, err = tx.Exec("UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = ?", userID)
+	} else {
+		_, err = tx.Exec("UPDATE users SET email = ?, email_verified_at = NOW() WHERE id = ?", email, userID)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			return 0, "", ErrEmailTaken
+		}
+		if err == nil {
+			_, err = tx.Exec("UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = ? AND revoked_at IS NULL", userID)
+		}
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if _, err := tx.Exec("UPDATE email_verifications SET used_at = NOW() WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return 0, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	return userID, email, nil
+}
+
+// ResendEmailVerification sends a new link for the user's pending email change,
+// or for their current address if it was never confirmed
+func ResendEmailVerification(userID int64) error {
+	var pending string
+	err := database.QueryRow(
+		"SELECT email FROM email_verifications WHERE user_id = ? AND used_at IS NULL ORDER BY id DESC LIMIT 1", userID,
+	).Scan(&pending)
+	if errors.Is(err, sql.ErrNoRows) {
+		var verified bool
+		err = database.QueryRow("SELECT email, email_verified_at IS NOT NULL FROM users WHERE id = ?", userID).Scan(&pending, &verified)
+		if err == nil && verified {
+			return ErrNothingToVerify
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if !verifyLimiter.allow(strconv.FormatInt(userID, 10), config.EmailVerificationResendLimit(), time.Now()) {
+		return ErrTooManyVerificationEmails
+	}
+	return SendEmailVerification(userID, pending)
+}
+
+// ConfirmEmail confirms an email address from an emailed link. Expects {"token": "..."}.
+// When the logged in user confirms a new address, they stay logged in under it.
+func ConfirmEmail(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Token == "" {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	before, _ := CurrentUser(req)
+	userID, email, err := VerifyEmail(body.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidVerificationToken):
+			utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrEmailTaken):
+			utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			utils.HandleError(err)
+			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to verify email"})
+		}
+		return
+	}
+
+	if before != nil && before.ID == userID && before.Email != email {
+		if err := ApplySession(w, req, &models.UserInfo{Email: email}); err != nil {
+			utils.HandleError(err)
+		}
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Email verified", "email": email})
+}
+
+// ResendVerification sends the session user a new email verification link
+func ResendVerification(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	if err := ResendEmailVerification(userID); err != nil {
+		switch {
+		case errors.Is(err, ErrNothingToVerify):
+			utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrTooManyVerificationEmails):
+			utils.SendJSONResponse(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+		default:
+			utils.HandleError(err)
+			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to send verification email"})
+		}
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Verification email sent"})
+}
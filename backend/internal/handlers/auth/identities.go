@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/oidc"
+)
+
+var (
+	ErrIdentityLinked   = errors.New("this sign-in is already linked to another account")
+	ErrIdentityNotFound = errors.New("linked sign-in not found")
+	ErrAccountExists    = errors.New("an account with this email already exists, sign in with your password and link the provider from your settings")
+	ErrNoProviderEmail  = errors.New("the provider did not share an email address")
+	ErrLastSignInMethod = errors.New("this is your only way to sign in, set a password before removing it")
+	ErrUnknownProvider  = errors.New("unknown sign-in provider")
+)
+
+// Identity is an account at a sign-in provider linked to a user
+type Identity struct {
+	ID          int64      `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// Providers are the OpenID Connect providers users can sign in with, by name. Tests may replace them.
+var Providers = providersFromConfig()
+
+func providersFromConfig() map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	for _, p := range config.OIDCProviders() {
+		providers[p.Name] = &oidc.Provider{
+			Name:         p.Name,
+			DisplayName:  p.DisplayName,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  config.OIDCRedirectBaseURL() + "/api/oidc/" + p.Name + "/callback",
+			Scopes:       p.Scopes,
+			Client:       &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return providers
+}
+
+// OIDCSignIn returns the user a provider sign-in belongs to. An unknown provider account is
+// linked to the user with the same email when both the provider and this application have
+// verified it, or gets a new account without a local password when no user has the email.
+func OIDCSignIn(provider string, claims *oidc.Claims) (int64, error) {
+	userID, err := identityUser(provider, claims)
+	if err == nil {
+		return userID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return 0, ErrNoProviderEmail
+	}
+	var verified bool
+	err = database.QueryRow("SELECT id, email_verified_at IS NOT NULL FROM users WHERE email = ?", email).Scan(&userID, &verified)
+	if err == nil {
+		if !verified || !bool(claims.EmailVerified) {
+			return 0, ErrAccountExists
+		}
+		return userID, LinkIdentity(userID, provider, claims)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	username, err := availableUsername(claims)
+	if err != nil {
+		return 0, err
+	}
+	userID, err = createUser(username, email, "")
+	if err != nil {
+		return 0, err
+	}
+	if claims.EmailVerified {
+		_, err = database.Execute("UPDATE users SET email_verified_at = NOW() WHERE id = ?", userID)
+	} else {
+		err = SendEmailVerification(userID, email)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, LinkIdentity(userID, provider, claims)
+}
+
+// identityUser returns the user the provider account is linked to and records the sign-in
+func identityUser(provider string, claims *oidc.Claims) (int64, error) {
+	var userID int64
+	err := database.QueryRow(
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, claims.Subject,
+	).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = database.Execute(
+		"UPDATE user_identities SET email = ?, last_login_at = NOW() WHERE provider = ? AND subject = ?",
+		claims.Email, provider, claims.Subject,
+	)
+	return userID, err
+}
+
+// LinkIdentity links the provider account to the user
+func LinkIdentity(userID int64, provider string, claims *oidc.Claims) error {
+	var linkedTo int64
+	err := database.QueryRow(
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, claims.Subject,
+	).Scan(&linkedTo)
+	if err == nil {
+		if linkedTo != userID {
+			return ErrIdentityLinked
+		}
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	_, err = database.Execute(
+		"INSERT INTO user_identities (user_id, provider, subject, email, last_login_at) VALUES (?, ?, ?, ?, NOW())",
+		userID, provider, claims.Subject, claims.Email,
+	)
+	return err
+}
+
+// Identities returns the provider accounts linked to the user
+func Identities(userID int64) ([]Identity, error) {
+	rows, err := database.Query(
+		"SELECT id, provider, COALESCE(email, ''), created_at, last_login_at FROM user_identities WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var i Identity
+		var lastLogin sql.NullTime
+		if err := rows.Scan(&i.ID, &i.Provider, &i.Email, &i.CreatedAt, &lastLogin); err != nil {
+			return nil, err
+		}
+		if lastLogin.Valid {
+			i.LastLoginAt = &lastLogin.Time
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity removes a linked provider account, unless the user could not sign in without it
+func UnlinkIdentity(userID, identityID int64) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hasPassword bool
+	err = tx.QueryRow("SELECT COALESCE(password_hash, '') != '' FROM users WHERE id = ? FOR UPDATE", userID).Scan(&hasPassword)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var linked int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM user_identities WHERE user_id = ?", userID).Scan(&linked); err != nil {
+		return err
+	}
+	if !hasPassword && linked <= 1 {
+		return ErrLastSignInMethod
+	}
+
+	result, err := tx.Exec("DELETE FROM user_identities WHERE id = ? AND user_id = ?", identityID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrIdentityNotFound
+	}
+	return tx.Commit()
+}
+
+// availableUsername picks an unused username from the provider's username, name or email
+func availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = claims.Name
+	}
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-', r == '.':
+			return r
+		case unicode.IsSpace(r):
+			return '_'
+		}
+		return -1
+	}, base)
+	if len([]rune(base)) > 40 {
+		base = string([]rune(base)[:40])
+	}
+	if len([]rune(base)) < 3 {
+		base = "user" + base
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 10; attempt++ {
+		var taken int
+		if err := database.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, 1000+rand.IntN(9000))
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
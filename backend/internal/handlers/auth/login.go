@@ -7,8 +7,6 @@ import (
 	"skillswap/backend/internal/database"
 	"skillswap/backend/internal/models"
 	"skillswap/backend/internal/utils"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func Login(w http.ResponseWriter, req *http.Request) {
@@ -24,11 +22,17 @@ func Login(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	row := database.QueryRow("SELECT username, email, id, password_hash FROM users WHERE email = ?", userInfo.Email)
+	ip := clientIP(req)
+	if !checkLoginThrottle(w, userInfo.Email, ip) {
+		return
+	}
+
+	row := database.QueryRow("SELECT username, email, id, COALESCE(password_hash, '') FROM users WHERE email = ?", userInfo.Email)
 	var storedID int
 	var storedUsername, storedEmail, storedHash string
 	if err := row.Scan(&storedUsername, &storedEmail, &storedID, &storedHash); err != nil {
 		if err == sql.ErrNoRows {
+			loginFailed(userInfo.Email, ip, 0)
 			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
 			return
 		}
@@ -36,22 +40,41 @@ func Login(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Verify password with bcrypt (fallback to MD5 for legacy hashes)
-	if err := verifyPassword(storedHash, userInfo.Password); err != nil {
+	// Legacy and outdated hashes are upgraded once the password matches
+	if !checkPassword(int64(storedID), storedHash, userInfo.Password) {
+		loginFailed(userInfo.Email, ip, int64(storedID))
 		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Invalid email or password"})
 		return
 	}
 
+	// With two-factor authentication the session waits for a code before it is authenticated,
+	// and the account's failed logins are only forgotten once the code is right
+	twoFactor, err := TwoFactorEnabled(int64(storedID))
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "An error occurred. Please try again later"})
+		return
+	}
+	if twoFactor {
+		if err := startPendingLogin(w, req, storedEmail); err != nil {
+			utils.HandleError(err)
+			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create session"})
+			return
+		}
+		utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+			"status":              "ok",
+			"message":             "Enter the code from your authenticator app",
+			"two_factor_required": true,
+		})
+		return
+	}
+
 	if err := ApplySession(w, req, &models.UserInfo{Username: storedUsername, Email: storedEmail, ID: storedID}); err != nil {
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create session"})
 		return
 	}
+	loginSucceeded(userInfo.Email, int64(storedID), ip)
 
 	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Login successful"})
 }
-
-// verifyPassword checks a password against a bcrypt hash.
-func verifyPassword(storedHash, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(password))
-}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/mailer"
+	"skillswap/backend/internal/utils"
+)
+
+// Failed logins are counted under two keys: the email tried, so guessing one account from
+// many addresses is slowed down, and the client IP, so one address guessing many accounts is.
+// Unknown emails are counted like real accounts, which keeps the responses from telling them apart.
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginDelay returns how long the next attempt must wait after consecutive failures:
+// nothing for the first free ones, then BaseDelay doubling with each failure up to MaxDelay
+func loginDelay(failures, free int, s config.LoginThrottleSettings) time.Duration {
+	if failures <= free {
+		return 0
+	}
+	delay := s.BaseDelay
+	for i := free + 1; i < failures && delay < s.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.MaxDelay)
+}
+
+// loginWait returns how much longer the key has to wait before it may try to log in again
+func loginWait(key string, free int, s config.LoginThrottleSettings, t time.Time) (time.Duration, error) {
+	var failures int
+	var lastFailed time.Time
+	var lockedUntil sql.NullTime
+	err := database.QueryRow(
+		"SELECT failures, last_failed_at, locked_until FROM login_throttles WHERE throttle_key = ?", key,
+	).Scan(&failures, &lastFailed, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if lockedUntil.Valid && lockedUntil.Time.After(t) {
+		return lockedUntil.Time.Sub(t), nil
+	}
+	if t.Sub(lastFailed) >= s.FailureWindow {
+		return 0, nil
+	}
+	return max(lastFailed.Add(loginDelay(failures, free, s)).Sub(t), 0), nil
+}
+
+// recordLoginFailure counts a failed login for the key. With a lockout threshold the key
+// is locked once it is reached; locked reports that this failure started the lockout.
+func recordLoginFailure(key string, lockoutThreshold int, s config.LoginThrottleSettings, t time.Time) (locked bool, err error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var failures int
+	var lastFailed time.Time
+	var lockedUntil sql.NullTime
+	err = tx.QueryRow(
+		"SELECT failures, last_failed_at, locked_until FROM login_throttles WHERE throttle_key = ? FOR UPDATE", key,
+	).Scan(&failures, &lastFailed, &lockedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	// Counting starts over after a quiet window or once a lockout has run out
+	if t.Sub(lastFailed) >= s.FailureWindow || (lockedUntil.Valid && !lockedUntil.Time.After(t)) {
+		failures = 0
+		lockedUntil = sql.NullTime{}
+	}
+
+	failures++
+	if lockoutThreshold > 0 && failures >= lockoutThreshold && !lockedUntil.Valid {
+		lockedUntil = sql.NullTime{Time: t.Add(s.LockoutDuration), Valid: true}
+		locked = true
+	}
+	_, err = tx.Exec(`
+		INSERT INTO login_throttles (throttle_key, failures, last_failed_at, locked_until) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE failures = VALUES(failures), last_failed_at = VALUES(last_failed_at), locked_until = VALUES(locked_until)`,
+		key, failures, t, lockedUntil,
+	)
+	if err != nil {
+		return false, err
+	}
+	return locked, tx.Commit()
+}
+
+// clearLoginFailures forgets the failed logins of the key
+func clearLoginFailures(key string) error {
+	_, err := database.Execute("DELETE FROM login_throttles WHERE throttle_key = ?", key)
+	return err
+}
+
+// checkLoginThrottle writes a 429 response and returns false when the IP address or the
+// account has to wait before trying to log in again. It fails closed with a 503 when the
+// failure counts cannot be read.
+func checkLoginThrottle(w http.ResponseWriter, email, ip string) bool {
+	s := config.LoginThrottle()
+	t := now()
+	wait, err := loginWait(ipThrottleKey(ip), s.IPFreeAttempts, s, t)
+	if err == nil {
+		var accountWait time.Duration
+		accountWait, err = loginWait(accountThrottleKey(email), s.AccountFreeAttempts, s, t)
+		wait = max(wait, accountWait)
+	}
+	if err != nil {
+		// Without the failure counts a lockout cannot be enforced, so logins wait for the database
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusServiceUnavailable, map[string]string{"error": "Login is temporarily unavailable. Please try again later"})
+		return false
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	seconds := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	utils.SendJSONResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+		"error":       fmt.Sprintf("Too many failed login attempts. Please try again in %s", waitText(seconds)),
+		"retry_after": seconds,
+	})
+	return false
+}
+
+// loginFailed counts a failed login for the email and IP address and tells the account's
+// owner when it gets locked. userID is zero when no account uses the email.
+func loginFailed(email, ip string, userID int64) {
+	s := config.LoginThrottle()
+	t := now()
+	if _, err := recordLoginFailure(ipThrottleKey(ip), 0, s, t); err != nil {
+		utils.HandleError(err)
+	}
+	locked, err := recordLoginFailure(accountThrottleKey(email), s.LockoutThreshold, s, t)
+	if err != nil {
+		utils.HandleError(err)
+	}
+	if locked && userID != 0 {
+		if err := sendLockoutNotice(userID, s); err != nil {
+			utils.HandleError(err)
+		}
+	}
+}
+
+// loginSucceeded forgets the account's failed logins and records the login. It must only run
+// once every factor is checked, or a known password would keep resetting the lockout.
+func loginSucceeded(email string, userID int64, ip string) {
+	if err := clearLoginFailures(accountThrottleKey(email)); err != nil {
+		utils.HandleError(err)
+	}
+	recordLogin(userID, ip)
+}
+
+// recordLogin stores when and from where the user last logged in
+func recordLogin(userID int64, ip string) {
+	if _, err := database.Execute("UPDATE users SET last_login_at = ?, last_login_ip = ? WHERE id = ?", now(), ip, userID); err != nil {
+		utils.HandleError(err)
+	}
+}
+
+// sendLockoutNotice emails the user that their account was locked after failed logins
+func sendLockoutNotice(userID int64, s config.LoginThrottleSettings) error {
+	var username, email string
+	if err := database.QueryRow("SELECT username, email FROM users WHERE id = ?", userID).Scan(&username, &email); err != nil {
+		return err
+	}
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Your SkillSwap account was temporarily locked",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nAfter %d failed attempts to sign in, your SkillSwap account is locked for %d minutes.\n\n"+
+				"If it wasn't you, someone may be guessing your password. You can choose a new one here:\n\n"+
+				"%s/auth/forgot-password\n",
+			username, s.LockoutThreshold, int(s.LockoutDuration.Minutes()), config.AppURL(),
+		),
+	})
+}
+
+// UnlockAccount clears the failed logins and any lockout of the user's account
+func UnlockAccount(userID int64) error {
+	var email string
+	err := database.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return clearLoginFailures(accountThrottleKey(email))
+}
+
+// PurgeLoginThrottles deletes failure counts that are no longer locked and older than the failure window
+func PurgeLoginThrottles() (int64, error) {
+	result, err := database.Execute(
+		"DELETE FROM login_throttles WHERE last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+		now().Add(-config.LoginThrottle().FailureWindow), now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// waitText describes a wait in whole seconds, e.g. "5 seconds" or "3 minutes"
+func waitText(seconds int) string {
+	switch {
+	case seconds == 1:
+		return "1 second"
+	case seconds < 60:
+		return fmt.Sprintf("%d seconds", seconds)
+	case seconds < 120:
+		return "1 minute"
+	default:
+		return fmt.Sprintf("%d minutes", (seconds+59)/60)
+	}
+}
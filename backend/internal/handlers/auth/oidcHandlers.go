@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+	"skillswap/backend/internal/oidc"
+	"skillswap/backend/internal/utils"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	// oidcFlowCookie carries the state of a sign-in from its start to the provider's callback
+	oidcFlowCookie = "oidc_flow"
+	// oidcFlowMaxAge is how many seconds a user has to finish signing in at the provider
+	oidcFlowMaxAge = 600
+)
+
+// oidcFlow is what the callback needs to check and finish a sign-in
+type oidcFlow struct {
+	Provider   string
+	State      string
+	Nonce      string
+	Verifier   string
+	ReturnTo   string
+	LinkUserID int64
+}
+
+// flowCodecs sign and encrypt the flow cookie with the session keys
+var flowCodecs = newFlowCodecs()
+
+func newFlowCodecs() []securecookie.Codec {
+	codecs := securecookie.CodecsFromPairs(sessionKeyPairs()...)
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(oidcFlowMaxAge)
+		}
+	}
+	return codecs
+}
+
+// GetOIDCProviders lists the providers users can sign in with
+func GetOIDCProviders(w http.ResponseWriter, req *http.Request) {
+	providers := []map[string]string{}
+	for name, p := range Providers {
+		providers = append(providers, map[string]string{
+			"name":         name,
+			"display_name": p.DisplayName,
+			"login_url":    "/api/oidc/" + name + "/login",
+		})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i]["name"] < providers[j]["name"] })
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"providers": providers})
+}
+
+// OIDCLogin sends the browser to the provider to sign in. After signing in the user
+// returns to the frontend path in ?return_to.
+func OIDCLogin(w http.ResponseWriter, req *http.Request) {
+	authURL, err := startOIDCFlow(w, req, 0)
+	if err != nil {
+		redirectToApp(w, req, "/auth/login", "error", err.Error())
+		return
+	}
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+// LinkOIDCProvider starts linking a provider account to the session user and returns the
+// URL to send the browser to. Expects {"return_to": "/settings"}, which is optional.
+func LinkOIDCProvider(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	authURL, err := startOIDCFlow(w, req, userID)
+	if err != nil {
+		if errors.Is(err, ErrUnknownProvider) {
+			utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.SendJSONResponse(w, http.StatusBadGateway, map[string]string{"error": "Failed to reach the sign-in provider"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"redirect_url": authURL})
+}
+
+// startOIDCFlow remembers a new sign-in in the flow cookie and returns the provider's
+// authorization URL. A non-zero linkUserID links the provider account to that user.
+func startOIDCFlow(w http.ResponseWriter, req *http.Request, linkUserID int64) (string, error) {
+	name := mux.Vars(req)["provider"]
+	provider, ok := Providers[name]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	returnTo := req.URL.Query().Get("return_to")
+	if linkUserID != 0 {
+		var body struct {
+			ReturnTo string `json:"return_to"`
+		}
+		if json.NewDecoder(req.Body).Decode(&body) == nil {
+			returnTo = body.ReturnTo
+		}
+	}
+	flow := oidcFlow{Provider: name, ReturnTo: returnTo, LinkUserID: linkUserID}
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			return "", err
+		}
+		*value = random
+	}
+
+	authURL, err := provider.AuthCodeURL(req.Context(), flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		utils.HandleError(err)
+		return "", err
+	}
+	encoded, err := securecookie.EncodeMulti(oidcFlowCookie, flow, flowCodecs...)
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, flowCookie(encoded, oidcFlowMaxAge))
+	return authURL, nil
+}
+
+// OIDCCallback finishes a sign-in when the provider redirects back, then sends the browser
+// on to the frontend
+func OIDCCallback(w http.ResponseWriter, req *http.Request) {
+	var flow oidcFlow
+	cookie, err := req.Cookie(oidcFlowCookie)
+	if err == nil {
+		err = securecookie.DecodeMulti(oidcFlowCookie, cookie.Value, &flow, flowCodecs...)
+	}
+	// The flow is single-use
+	http.SetCookie(w, flowCookie("", -1))
+	q := req.URL.Query()
+	if err != nil || flow.Provider != mux.Vars(req)["provider"] ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(q.Get("state"))) != 1 {
+		redirectToApp(w, req, "/auth/login", "error", "Sign-in expired, please try again")
+		return
+	}
+	failure := "/auth/login"
+	if flow.LinkUserID != 0 {
+		failure = safeReturnPath(flow.ReturnTo, "/settings")
+	}
+	if q.Get("error") != "" {
+		redirectToApp(w, req, failure, "error", "Sign-in was cancelled")
+		return
+	}
+	provider, ok := Providers[flow.Provider]
+	if !ok {
+		redirectToApp(w, req, failure, "error", ErrUnknownProvider.Error())
+		return
+	}
+
+	claims, err := provider.Exchange(req.Context(), q.Get("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		utils.HandleError(err)
+		redirectToApp(w, req, failure, "error", "Sign-in with the provider failed")
+		return
+	}
+
+	if flow.LinkUserID != 0 {
+		// Linking continues the session of the user who started it
+		if p, err := CurrentUser(req); err != nil || p.ID != flow.LinkUserID {
+			redirectToApp(w, req, "/auth/login", "error", "Sign-in expired, please try again")
+			return
+		}
+		if err := LinkIdentity(flow.LinkUserID, flow.Provider, claims); err != nil {
+			sendOIDCError(w, req, failure, err)
+			return
+		}
+		redirectToApp(w, req, safeReturnPath(flow.ReturnTo, "/settings"), "linked", flow.Provider)
+		return
+	}
+
+	userID, err := OIDCSignIn(flow.Provider, claims)
+	if err != nil {
+		sendOIDCError(w, req, failure, err)
+		return
+	}
+	var email string
+	if err := database.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		sendOIDCError(w, req, failure, err)
+		return
+	}
+
+	twoFactor, err := TwoFactorEnabled(userID)
+	if err != nil {
+		sendOIDCError(w, req, failure, err)
+		return
+	}
+	if twoFactor {
+		if err := startPendingLogin(w, req, email); err != nil {
+			sendOIDCError(w, req, failure, err)
+			return
+		}
+		redirectToApp(w, req, "/auth/login", "two_factor", "1")
+		return
+	}
+	if err := ApplySession(w, req, &models.UserInfo{Email: email}); err != nil {
+		sendOIDCError(w, req, failure, err)
+		return
+	}
+	recordLogin(userID, clientIP(req))
+	redirectToApp(w, req, safeReturnPath(flow.ReturnTo, "/"), "", "")
+}
+
+// sendOIDCError sends the browser to the frontend path with the error's message,
+// logging errors the user cannot act on
+func sendOIDCError(w http.ResponseWriter, req *http.Request, path string, err error) {
+	switch {
+	case errors.Is(err, ErrAccountExists), errors.Is(err, ErrIdentityLinked), errors.Is(err, ErrNoProviderEmail):
+		redirectToApp(w, req, path, "error", err.Error())
+	default:
+		utils.HandleError(err)
+		redirectToApp(w, req, path, "error", "Sign-in failed, please try again")
+	}
+}
+
+// GetIdentities lists the provider accounts linked to the session user
+func GetIdentities(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	identities, err := Identities(userID)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve linked sign-ins"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"identities": identities})
+}
+
+// UnlinkIdentityRequest removes a linked provider account. Expects {"id": 1}.
+func UnlinkIdentityRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+	var body struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := UnlinkIdentity(userID, body.ID); err != nil {
+		switch {
+		case errors.Is(err, ErrIdentityNotFound):
+			utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrLastSignInMethod):
+			utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			utils.HandleError(err)
+			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove linked sign-in"})
+		}
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Linked sign-in removed"})
+}
+
+func flowCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    value,
+		Path:     "/api/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   os.Getenv("ENVIRONMENT") == "production",
+		// Lax still sends the cookie on the provider's top-level redirect back
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// safeReturnPath returns the path if it stays on the frontend, otherwise the fallback
+func safeReturnPath(path, fallback string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, `\`) {
+		return fallback
+	}
+	return path
+}
+
+// redirectToApp sends the browser to a frontend path, optionally with one query parameter
+func redirectToApp(w http.ResponseWriter, req *http.Request, path, key, value string) {
+	target := config.AppURL() + path
+	if key != "" {
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		target += separator + url.Values{key: {value}}.Encode()
+	}
+	http.Redirect(w, req, target, http.StatusFound)
+}
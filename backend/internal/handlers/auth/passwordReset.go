@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/credentials"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/mailer"
+	"skillswap/backend/internal/utils"
+)
+
+var (
+	ErrTooManyResetRequests = errors.New("too many password reset requests, please try again later")
+	ErrInvalidResetToken    = errors.New("invalid or expired reset token")
+)
+
+// hourlyLimiter counts events per key over a sliding hour
+type hourlyLimiter struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// allow records an event for the key unless limit events happened in the last hour.
+// A limit of zero means no limit.
+func (l *hourlyLimiter) allow(key string, limit int, now time.Time) bool {
+	if limit == 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.hits == nil || len(l.hits) > 10000 {
+		l.sweep(now)
+	}
+
+	recent := l.hits[key][:0]
+	for _, t := range l.hits[key] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		l.hits[key] = recent
+		return false
+	}
+	l.hits[key] = append(recent, now)
+	return true
+}
+
+// sweep forgets keys without events in the last hour
+func (l *hourlyLimiter) sweep(now time.Time) {
+	if l.hits == nil {
+		l.hits = make(map[string][]time.Time)
+	}
+	for key, hits := range l.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) >= time.Hour {
+			delete(l.hits, key)
+		}
+	}
+}
+
+var resetLimiter hourlyLimiter
+
+// StartPasswordReset emails a single-use reset link to the account with the given email.
+// Unknown emails are ignored without an error, so the response does not reveal which accounts exist.
+func StartPasswordReset(email, ip string) error {
+	email = strings.TrimSpace(email)
+	perEmail, perIP := config.PasswordResetLimits()
+	now := time.Now()
+	if !resetLimiter.allow("ip:"+ip, perIP, now) || !resetLimiter.allow("email:"+strings.ToLower(email), perEmail, now) {
+		return ErrTooManyResetRequests
+	}
+
+	var userID int64
+	var username string
+	err := database.QueryRow("SELECT id, username FROM users WHERE email = ?", email).Scan(&userID, &username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	ttl := config.PasswordResetTTL()
+	_, err = database.Execute(
+		"INSERT INTO password_resets (user_id, token_hash, ip_address, expires_at) VALUES (?, ?, ?, ?)",
+		userID, hashToken(token), ip, now.Add(ttl),
+	)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Reset your SkillSwap password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your SkillSwap account. "+
+				"To choose a new password, open this link within %d minutes:\n\n%s/auth/reset-password?token=%s\n\n"+
+				"If it wasn't you, ignore this email; your password stays the same.\n",
+			username, int(ttl.Minutes()), config.AppURL(), token,
+		),
+	})
+}
+
+// FinishPasswordReset sets a new password using a reset token. The token and any other
+// outstanding tokens of the account are used up, and every session and API token of the
+// account is revoked.
+func FinishPasswordReset(token, password string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var username, email string
+	err = tx.QueryRow(`
+		SELECT r.user_id, u.username, u.email
+		FROM password_resets r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash = ? AND r.used_at IS NULL AND r.expires_at > NOW()
+		FOR UPDATE`, hashToken(token)).Scan(&userID, &username, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := credentials.CheckPolicy(password, username, email); err != nil {
+		return err
+	}
+	hash, err := credentials.Hash(password)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", hash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE password_resets SET used_at = NOW() WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = ? AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now(), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ForgotPassword emails a password reset link. Expects {"email": "..."}.
+// It answers the same whether or not the email belongs to an account.
+func ForgotPassword(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || strings.TrimSpace(body.Email) == "" {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Email is required"})
+		return
+	}
+
+	if err := StartPasswordReset(body.Email, clientIP(req)); err != nil {
+		if errors.Is(err, ErrTooManyResetRequests) {
+			utils.SendJSONResponse(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.HandleError(err)
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"message": "If the email belongs to an account, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password from an emailed reset link. Expects {"token": "...", "password": "..."}.
+func ResetPassword(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Token == "" {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := FinishPasswordReset(body.Token, body.Password); err != nil {
+		if errors.Is(err, ErrInvalidResetToken) || credentials.IsPolicyError(err) {
+			utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to reset password"})
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Password has been reset"})
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"skillswap/backend/internal/database"
+)
+
+// Roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var (
+	ErrNotAuthenticated = errors.New("not authenticated")
+	ErrInvalidSession   = errors.New("invalid session")
+	ErrUserNotFound     = errors.New("user not found")
+)
+
+// Principal is the user a request is made by
+type Principal struct {
+	ID            int64    `json:"id"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+	// TokenID and Scopes are set when the request is made with an API token
+	TokenID int64    `json:"-"`
+	Scopes  []string `json:"scopes,omitempty"`
+}
+
+// HasRole reports whether the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the principal has the admin role
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+type contextKey int
+
+const principalKey contextKey = 0
+
+// ViaToken reports whether the request is made with an API token rather than a session
+func (p *Principal) ViaToken() bool {
+	return p.TokenID != 0
+}
+
+// HasScope reports whether the principal may act within the scope. Sessions have every scope.
+func (p *Principal) HasScope(scope string) bool {
+	if !p.ViaToken() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPrincipal returns a copy of the context carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the principal stored in the context by the auth middleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok && p != nil
+}
+
+// Authenticate resolves the principal behind the request's "Authorization: Bearer" API token,
+// or its session cookie when there is no token
+func Authenticate(req *http.Request) (*Principal, error) {
+	if token, ok := bearerToken(req); ok {
+		return authenticateToken(req, token)
+	}
+	return authenticateSession(req)
+}
+
+// authenticateSession resolves the principal behind the request's session cookie
+func authenticateSession(req *http.Request) (*Principal, error) {
+	session, err := Store.Get(req, "authentication")
+	if err != nil {
+		return nil, ErrNotAuthenticated
+	}
+	if authenticated, ok := session.Values["authenticated"].(bool); !ok || !authenticated {
+		return nil, ErrNotAuthenticated
+	}
+	email, ok := session.Values["email"].(string)
+	if !ok || email == "" {
+		return nil, ErrInvalidSession
+	}
+
+	p := &Principal{Roles: []string{RoleUser}}
+	var isAdmin bool
+	err = database.QueryRow("SELECT id, username, email, email_verified_at IS NOT NULL, is_admin FROM users WHERE email = ?", email).
+		Scan(&p.ID, &p.Username, &p.Email, &p.EmailVerified, &isAdmin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isAdmin {
+		p.Roles = append(p.Roles, RoleAdmin)
+	}
+	return p, nil
+}
+
+// CurrentUser returns the principal the auth middleware resolved for the request,
+// or resolves it from the session on routes without the middleware. API tokens are only
+// accepted through the middleware, which checks their scopes.
+func CurrentUser(req *http.Request) (*Principal, error) {
+	if p, ok := PrincipalFromContext(req.Context()); ok {
+		return p, nil
+	}
+	return authenticateSession(req)
+}
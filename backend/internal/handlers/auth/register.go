@@ -1,14 +1,15 @@
 package auth
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strings"
 	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/credentials"
 	"skillswap/backend/internal/models"
 	"skillswap/backend/internal/utils"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func Register(w http.ResponseWriter, req *http.Request) {
@@ -35,20 +36,19 @@ func Register(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Validate password length
-	if len(userInfo.Password) < 8 {
-		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Password must be at least 8 characters long"})
+	// Validate password against the password policy
+	if err := credentials.CheckPolicy(userInfo.Password, userInfo.Username, userInfo.Email); err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	passwordHashBytes, err := bcrypt.GenerateFromPassword([]byte(userInfo.Password), bcrypt.DefaultCost)
+	passwordHash, err := credentials.Hash(userInfo.Password)
 	if err != nil {
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to process password"})
 		return
 	}
-	passwordHash := string(passwordHashBytes)
 
-	_, err = database.Execute("INSERT INTO users ( username, email, password_hash) VALUES (?, ?, ?)", userInfo.Username, userInfo.Email, passwordHash)
+	newID, err := createUser(userInfo.Username, userInfo.Email, passwordHash)
 	if err != nil {
 		utils.HandleError(err)
 		errStr := err.Error()
@@ -68,17 +68,14 @@ func Register(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Get the newly created user ID
-	row := database.QueryRow("SELECT id FROM users WHERE email = ?", userInfo.Email)
-	var userID int
-	if err := row.Scan(&userID); err != nil {
+	userID := int(newID)
+	userInfo.ID = userID
+
+	// The account works right away, but some actions wait until the email is confirmed
+	if err := SendEmailVerification(int64(userID), userInfo.Email); err != nil {
 		utils.HandleError(err)
-		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve user information"})
-		return
 	}
 
-	userInfo.ID = userID
-
 	// Apply session automatically after registration
 	if err := ApplySession(w, req, &userInfo); err != nil {
 		utils.HandleError(err)
@@ -88,10 +85,51 @@ func Register(w http.ResponseWriter, req *http.Request) {
 	
 	// Return success response with user data
 	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
-		"status":  "ok",
-		"message": "Registration successful",
-		"user":    userInfo.Username,
-		"email":   userInfo.Email,
-		"id":      userID,
+		"status":         "ok",
+		"message":        "Registration successful",
+		"user":           userInfo.Username,
+		"email":          userInfo.Email,
+		"email_verified": false,
+		"id":             userID,
 	})
 }
+
+// createUser inserts a new account together with the swap ledger entry for its signup credits
+// and returns its ID. An empty password hash creates an account without a local password.
+func createUser(username, email, passwordHash string) (int64, error) {
+	credits := config.SignupCredits()
+
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO users (username, email, password_hash, swaps) VALUES (?, ?, ?, ?)",
+		username, email, sql.NullString{String: passwordHash, Valid: passwordHash != ""}, credits,
+	)
+	if err != nil {
+		return 0, err
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := grantSignupCredits(tx, userID, credits); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// grantSignupCredits records the credits a new user was created with in the swap ledger
+func grantSignupCredits(tx *sql.Tx, userID int64, credits int) error {
+	if credits <= 0 {
+		return nil
+	}
+	_, err := tx.Exec(
+		"INSERT INTO swap_transactions (to_user_id, amount, reason) VALUES (?, ?, ?)",
+		userID, credits, models.ReasonSignupBonus,
+	)
+	return err
+}
@@ -2,39 +2,41 @@ package auth
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"skillswap/backend/internal/config"
 	"skillswap/backend/internal/database"
 	"skillswap/backend/internal/models"
 	"skillswap/backend/internal/utils"
-	"time"
 
 	"github.com/gorilla/sessions"
 )
 
-// Use a simple key for session Store
-var Authenticated = false
+// Store keeps the authentication sessions. Tests may swap it for a cookie store.
+var Store sessions.Store = newStore()
 
-var Store = sessions.NewCookieStore([]byte(getSessionKey()))
-
-func getSessionKey() string {
-	key := os.Getenv("SESSION_KEY")
-	if key == "" {
-		// Generate a random key at startup if not configured
-		return "skillswap-dev-session-key-change-in-production"
+// sessionKeyPairs returns the configured session key pairs, newest first. The server does not
+// start without usable keys, so cookies are never signed with the development key in production.
+func sessionKeyPairs() [][]byte {
+	pairs, err := config.SessionKeys()
+	if err != nil {
+		log.Fatalf("Auth: %v", err)
 	}
-	return key
+	return pairs
 }
 
-func init() {
+func newStore() *DBStore {
 	isDev := os.Getenv("ENVIRONMENT") != "production"
-	Store.Options = &sessions.Options{
+	store := NewDBStore(sessionKeyPairs()...)
+	store.Options = &sessions.Options{
 		Path:     "/",
-		MaxAge:   86400 * 7,
 		HttpOnly: true,
 		Secure:   !isDev,
 		SameSite: http.SameSiteLaxMode,
 	}
+	store.MaxAge(86400 * 7)
+	return store
 }
 
 func ApplySession(w http.ResponseWriter, req *http.Request, userInfo *models.UserInfo) error {
@@ -46,16 +48,19 @@ func ApplySession(w http.ResponseWriter, req *http.Request, userInfo *models.Use
 	session, err := Store.New(req, "authentication")
 	if err != nil {
 		// utils.DebugPrint("Create session failed", err)
-		Authenticated = false
 		return err
 	}
-	session.ID = fmt.Sprintf("%x", time.Now().UnixNano())
+	// Always start a new session on login rather than reusing the one in the cookie
+	if err := revokeToken(session.ID); err != nil {
+		return err
+	}
+	session.ID = ""
+	session.Values = make(map[interface{}]interface{})
 	session.Values["authenticated"] = true
 	session.Values["email"] = userInfo.Email
 
 	if err := session.Save(req, w); err != nil {
 		// utils.DebugPrint(err)
-		Authenticated = false
 		return err
 	}
 
@@ -65,44 +70,39 @@ func ApplySession(w http.ResponseWriter, req *http.Request, userInfo *models.Use
 func CheckSession(w http.ResponseWriter, req *http.Request) {
 	values, err := Store.Get(req, "authentication")
 	if err != nil {
-		Authenticated = false
 		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Invalid session"})
 		return
 	}
 
 	if values.Values["authenticated"] != true {
-		Authenticated = false
 		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
 		return
 	}
-	row, err := database.Query("SELECT username, email, id, COALESCE(profile_picture, ''), is_admin, swaps FROM users WHERE email = ?", values.Values["email"])
+	row, err := database.Query("SELECT username, email, id, COALESCE(profile_picture, ''), is_admin, swaps, email_verified_at IS NOT NULL FROM users WHERE email = ?", values.Values["email"])
 	var username string = ""
 	var email = ""
 	var id = 0
 	var profilePicture = ""
 	var isAdmin = 0
 	var swaps = 0
+	var emailVerified = false
 	if err != nil {
-		Authenticated = false
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check session"})
 		return
 	}
 	defer row.Close()
 	if !row.Next() {
-		Authenticated = false
 		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "User not found"})
 		return
 	}
-	err = row.Scan(&username, &email, &id, &profilePicture, &isAdmin, &swaps)
+	err = row.Scan(&username, &email, &id, &profilePicture, &isAdmin, &swaps, &emailVerified)
 	if err != nil {
-		Authenticated = false
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to check session"})
 		return
 	}
 	// utils.DebugPrint(values.Values)
-	Authenticated = true
 	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
 		"user":            username,
 		"email":           email,
@@ -110,6 +110,7 @@ func CheckSession(w http.ResponseWriter, req *http.Request) {
 		"profile_picture": profilePicture,
 		"is_admin":        isAdmin == 1,
 		"swaps":           swaps,
+		"email_verified":  emailVerified,
 	})
 }
 
@@ -122,17 +123,23 @@ func RemoveSession(w http.ResponseWriter, req *http.Request) error {
 
 	session, err := Store.Get(req, "authentication")
 	if err != nil {
-		Authenticated = false
 		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid session"})
 		return fmt.Errorf("invalid session")
 	}
 	session.Options.MaxAge = -1
 	if err := session.Save(req, w); err != nil {
-		Authenticated = false
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to save session"})
 		return fmt.Errorf("failed to save session")
 	}
 	// utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"message": "BAIIII"})
-	Authenticated = false
 	return nil
 }
+
+// GetSessionUserID returns the ID of the user the request is made by.
+func GetSessionUserID(req *http.Request) (int64, error) {
+	p, err := CurrentUser(req)
+	if err != nil {
+		return 0, err
+	}
+	return p.ID, nil
+}
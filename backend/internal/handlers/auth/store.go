@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/utils"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// defaultLifetime is how long a session lives in the database when its cookie has no MaxAge
+const defaultLifetime = 24 * time.Hour
+
+// touchEvery limits how often a session's last-seen time and address are refreshed
+const touchEvery = time.Minute
+
+// DBStore is a sessions.Store that keeps sessions in the user_sessions table.
+// The cookie holds a signed random token; the database holds its hash, so sessions
+// can be listed per device and revoked from anywhere.
+type DBStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+}
+
+// NewDBStore returns a store signing cookies and session data with the given key pairs,
+// as in sessions.NewCookieStore
+func NewDBStore(keyPairs ...[]byte) *DBStore {
+	store := &DBStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+	}
+	store.MaxAge(store.Options.MaxAge)
+	return store
+}
+
+// MaxAge sets the maximum age of the store's sessions and their cookies, in seconds
+func (s *DBStore) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// Get returns the named session, cached for the rest of the request
+func (s *DBStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie. A missing, tampered, expired or
+// revoked session yields a new empty session, so it no longer authenticates anyone.
+func (s *DBStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var token string
+	if err := securecookie.DecodeMulti(name, c.Value, &token, s.Codecs...); err != nil {
+		return session, nil
+	}
+
+	var data string
+	err = database.QueryRow(
+		"SELECT data FROM user_sessions WHERE token_hash = ? AND revoked_at IS NULL AND expires_at > NOW()",
+		hashToken(token),
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, data, &session.Values, s.Codecs...); err != nil {
+		session.Values = make(map[interface{}]interface{})
+		return session, nil
+	}
+
+	session.ID = token
+	session.IsNew = false
+	s.touch(r, token)
+	return session, nil
+}
+
+// Save stores the session and sets its cookie. A negative MaxAge revokes the session
+// and deletes the cookie.
+func (s *DBStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if err := revokeToken(session.ID); err != nil {
+			return err
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(lifetime(session.Options))
+	userID := sessionUser(session)
+
+	if session.ID == "" {
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		userAgent := truncate(r.UserAgent(), 500)
+		_, err = database.Execute(`
+			INSERT INTO user_sessions (token_hash, user_id, data, user_agent, device, ip_address, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			hashToken(token), userID, data, userAgent, deviceName(userAgent), clientIP(r), expires,
+		)
+		if err != nil {
+			return err
+		}
+		session.ID = token
+	} else {
+		_, err = database.Execute(`
+			UPDATE user_sessions SET user_id = ?, data = ?, expires_at = ?, last_seen_at = NOW()
+			WHERE token_hash = ? AND revoked_at IS NULL`,
+			userID, data, expires, hashToken(session.ID),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// touch refreshes when and from where the session was last used, at most once per touchEvery
+func (s *DBStore) touch(r *http.Request, token string) {
+	_, err := database.Execute(`
+		UPDATE user_sessions SET last_seen_at = NOW(), ip_address = ?
+		WHERE token_hash = ? AND last_seen_at < NOW() - INTERVAL ? SECOND`,
+		clientIP(r), hashToken(token), int(touchEvery.Seconds()),
+	)
+	if err != nil {
+		utils.HandleError(err)
+	}
+}
+
+// revokeToken ends the session with the given token, if any
+func revokeToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	_, err := database.Execute(
+		"UPDATE user_sessions SET revoked_at = NOW() WHERE token_hash = ? AND revoked_at IS NULL",
+		hashToken(token),
+	)
+	return err
+}
+
+// sessionUser returns the ID of the user the session is logged in as, or nil
+func sessionUser(session *sessions.Session) interface{} {
+	email, ok := session.Values["email"].(string)
+	if !ok || email == "" {
+		return nil
+	}
+	id, err := database.GetUserIDFromEmail(email)
+	if err != nil {
+		return nil
+	}
+	return id
+}
+
+func lifetime(opts *sessions.Options) time.Duration {
+	if opts.MaxAge > 0 {
+		return time.Duration(opts.MaxAge) * time.Second
+	}
+	return defaultLifetime
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// clientIP is the address the request came from, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return truncate(r.RemoteAddr, 45)
+	}
+	return host
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/totp"
+)
+
+const (
+	// totpIssuer names the account in authenticator apps
+	totpIssuer = "SkillSwap"
+	// totpSkew is how many periods of clock drift either way a code may have
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes a user gets at a time
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrNoPendingEnrollment  = errors.New("two-factor setup was not started")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+// now is the clock two-factor codes are checked against; tests replace it
+var now = time.Now
+
+// BeginTOTPEnrollment generates a new secret for the user and returns it with its otpauth URI.
+// Two-factor authentication is only enabled once ConfirmTOTPEnrollment accepts a first code.
+func BeginTOTPEnrollment(userID int64) (string, string, error) {
+	if enabled, err := TwoFactorEnabled(userID); err != nil {
+		return "", "", err
+	} else if enabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+
+	var email string
+	if err := database.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		return "", "", err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	_, err = database.Execute(`
+		INSERT INTO user_totp (user_id, secret) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE secret = VALUES(secret), last_used_step = NULL, created_at = NOW()`,
+		userID, secret,
+	)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(totpIssuer, email, secret), nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication once the user proves their
+// authenticator app works, and returns their first recovery codes
+func ConfirmTOTPEnrollment(userID int64, code string) ([]string, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secret string
+	var confirmed bool
+	err = tx.QueryRow("SELECT secret, confirmed_at IS NOT NULL FROM user_totp WHERE user_id = ? FOR UPDATE", userID).Scan(&secret, &confirmed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoPendingEnrollment
+	}
+	if err != nil {
+		return nil, err
+	}
+	if confirmed {
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := totp.Validate(secret, code, now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if _, err := tx.Exec("UPDATE user_totp SET confirmed_at = NOW(), last_used_step = ? WHERE user_id = ?", step, userID); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// TwoFactorEnabled reports whether the user has confirmed a TOTP secret
+func TwoFactorEnabled(userID int64) (bool, error) {
+	var enabled int
+	err := database.QueryRow("SELECT COUNT(*) FROM user_totp WHERE user_id = ? AND confirmed_at IS NOT NULL", userID).Scan(&enabled)
+	return enabled > 0, err
+}
+
+// RecoveryCodesLeft returns how many unused recovery codes the user has
+func RecoveryCodesLeft(userID int64) (int, error) {
+	var left int
+	err := database.QueryRow("SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&left)
+	return left, err
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code, using it up
+func VerifySecondFactor(userID int64, code string) error {
+	return inTwoFactorTx(func(tx *sql.Tx) error {
+		return checkSecondFactor(tx, userID, code, true)
+	})
+}
+
+// RemoveTwoFactor disables two-factor authentication after checking a TOTP or recovery code
+func RemoveTwoFactor(userID int64, code string) error {
+	return inTwoFactorTx(func(tx *sql.Tx) error {
+		if err := checkSecondFactor(tx, userID, code, true); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID)
+		return err
+	})
+}
+
+// NewRecoveryCodes replaces the user's recovery codes after checking a TOTP code
+func NewRecoveryCodes(userID int64, code string) ([]string, error) {
+	var codes []string
+	err := inTwoFactorTx(func(tx *sql.Tx) error {
+		if err := checkSecondFactor(tx, userID, code, false); err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+func inTwoFactorTx(fn func(tx *sql.Tx) error) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkSecondFactor accepts a TOTP code newer than the last one used, or with allowRecovery
+// an unused recovery code
+func checkSecondFactor(tx *sql.Tx, userID int64, code string, allowRecovery bool) error {
+	var secret string
+	var lastStep sql.NullInt64
+	err := tx.QueryRow(
+		"SELECT secret, last_used_step FROM user_totp WHERE user_id = ? AND confirmed_at IS NOT NULL FOR UPDATE", userID,
+	).Scan(&secret, &lastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if step, ok := totp.Validate(secret, code, now(), totpSkew); ok {
+		if lastStep.Valid && step <= lastStep.Int64 {
+			return ErrInvalidTwoFactorCode
+		}
+		_, err := tx.Exec("UPDATE user_totp SET last_used_step = ? WHERE user_id = ?", step, userID)
+		return err
+	}
+	if !allowRecovery {
+		return ErrInvalidTwoFactorCode
+	}
+
+	result, err := tx.Exec(
+		"UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL LIMIT 1",
+		userID, hashToken(normalizeRecoveryCode(code)),
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores new ones, returning them in clear
+func replaceRecoveryCodes(tx *sql.Tx, userID int64) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		if _, err := tx.Exec(
+			"INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID, hashToken(normalizeRecoveryCode(codes[i])),
+		); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes the user may type differently
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
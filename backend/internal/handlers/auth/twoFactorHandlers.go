@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+	"skillswap/backend/internal/utils"
+)
+
+// pendingLoginTTL is how long a password-checked login waits for its second factor.
+// Wrong codes are limited per account by the login throttle, not per pending login.
+const pendingLoginTTL = 5 * time.Minute
+
+// startPendingLogin saves a session that remembers the password was checked but is not
+// authenticated until LoginSecondFactor accepts a code
+func startPendingLogin(w http.ResponseWriter, req *http.Request, email string) error {
+	session, err := Store.New(req, "authentication")
+	if err != nil {
+		return err
+	}
+	if err := revokeToken(session.ID); err != nil {
+		return err
+	}
+	session.ID = ""
+	session.Values = make(map[interface{}]interface{})
+	session.Values["authenticated"] = false
+	session.Values["pending_email"] = email
+	session.Values["pending_since"] = now().Unix()
+	return session.Save(req, w)
+}
+
+// SendTwoFactorError writes the response for a two-factor error
+func SendTwoFactorError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, ErrInvalidTwoFactorCode):
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrTwoFactorEnabled), errors.Is(err, ErrTwoFactorNotEnabled), errors.Is(err, ErrNoPendingEnrollment):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
+
+// decodeCode reads {"code": "..."} from the request body
+func decodeCode(w http.ResponseWriter, req *http.Request) (string, bool) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Code == "" {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Code is required"})
+		return "", false
+	}
+	return body.Code, true
+}
+
+// LoginSecondFactor finishes a login that is waiting for a two-factor code. Expects {"code": "..."},
+// either a code from the authenticator app or a recovery code.
+func LoginSecondFactor(w http.ResponseWriter, req *http.Request) {
+	code, ok := decodeCode(w, req)
+	if !ok {
+		return
+	}
+
+	session, err := Store.Get(req, "authentication")
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Invalid session"})
+		return
+	}
+	email, _ := session.Values["pending_email"].(string)
+	since, _ := session.Values["pending_since"].(int64)
+	if email == "" {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "No login is waiting for a two-factor code"})
+		return
+	}
+	if now().Sub(time.Unix(since, 0)) > pendingLoginTTL {
+		session.Options.MaxAge = -1
+		if err := session.Save(req, w); err != nil {
+			utils.HandleError(err)
+		}
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Login expired, please sign in again"})
+		return
+	}
+
+	// Wrong codes count as failed logins of the account, so they are throttled and lock it
+	// however many times the password is entered again
+	ip := clientIP(req)
+	if !checkLoginThrottle(w, email, ip) {
+		return
+	}
+
+	var userID int64
+	err = database.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "An error occurred. Please try again later"})
+		return
+	}
+
+	if err := VerifySecondFactor(userID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			loginFailed(email, ip, userID)
+		}
+		SendTwoFactorError(w, err, "Failed to verify code")
+		return
+	}
+
+	if err := ApplySession(w, req, &models.UserInfo{Email: email}); err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create session"})
+		return
+	}
+	loginSucceeded(email, userID, ip)
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Login successful"})
+}
+
+// GetTwoFactorStatus returns whether the session user has two-factor authentication
+// enabled and how many recovery codes they have left
+func GetTwoFactorStatus(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	enabled, err := TwoFactorEnabled(userID)
+	if err != nil {
+		SendTwoFactorError(w, err, "Failed to get two-factor status")
+		return
+	}
+	left, err := RecoveryCodesLeft(userID)
+	if err != nil {
+		SendTwoFactorError(w, err, "Failed to get two-factor status")
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"enabled": enabled, "recovery_codes_left": left})
+}
+
+// SetupTwoFactor starts two-factor enrollment and returns the secret and otpauth URI
+// to add to an authenticator app
+func SetupTwoFactor(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	secret, uri, err := BeginTOTPEnrollment(userID)
+	if err != nil {
+		SendTwoFactorError(w, err, "Failed to start two-factor setup")
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"secret": secret, "otpauth_uri": uri})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a first code from the
+// authenticator app. Expects {"code": "..."} and returns the recovery codes.
+func ConfirmTwoFactor(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+	code, ok := decodeCode(w, req)
+	if !ok {
+		return
+	}
+
+	codes, err := ConfirmTOTPEnrollment(userID, code)
+	if err != nil {
+		SendTwoFactorError(w, err, "Failed to enable two-factor authentication")
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"status": "ok", "recovery_codes": codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off. Expects {"code": "..."}, a current
+// code or a recovery code.
+func DisableTwoFactor(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+	code, ok := decodeCode(w, req)
+	if !ok {
+		return
+	}
+
+	if err := RemoveTwoFactor(userID, code); err != nil {
+		SendTwoFactorError(w, err, "Failed to disable two-factor authentication")
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "ok", "message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the session user's recovery codes. Expects {"code": "..."},
+// a current code from the authenticator app.
+func RegenerateRecoveryCodes(w http.ResponseWriter, req *http.Request) {
+	userID, err := GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+	code, ok := decodeCode(w, req)
+	if !ok {
+		return
+	}
+
+	codes, err := NewRecoveryCodes(userID, code)
+	if err != nil {
+		SendTwoFactorError(w, err, "Failed to regenerate recovery codes")
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"status": "ok", "recovery_codes": codes})
+}
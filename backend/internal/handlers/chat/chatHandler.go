@@ -3,10 +3,7 @@ package chat
 
 import (
 	"net/http"
-	"strconv"
 
-	"skillswap/backend/internal/handlers/swaps"
-	"skillswap/backend/internal/models"
 	"skillswap/backend/internal/utils"
 )
 
@@ -29,10 +26,8 @@ func CreateChat(w http.ResponseWriter, req *http.Request) {
 		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	uid1, _ := strconv.Atoi(user1ID)
-	uid2, _ := strconv.Atoi(user2ID)
+	// Chats are free; credits only move when a swap request is accepted
 	if result.IsNew {
-		swaps.ExchangeSwaps(models.UserInfo{ID: uid1}, models.UserInfo{ID: uid2})
 		utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
 			"status":  "Created a new chat",
 			"chat_id": result.ChatID,
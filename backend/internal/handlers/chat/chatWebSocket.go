@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"time"
 
-	"skillswap/backend/internal/database"
 	"skillswap/backend/internal/handlers/auth"
 	"skillswap/backend/internal/utils"
 
@@ -256,29 +255,16 @@ func StartHub() {
 
 // SimpleWebSocketEndpoint handles the WebSocket endpoint for the chat application
 func SimpleWebSocketEndpoint(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// The auth middleware already resolved the user
+	userID, err := auth.GetSessionUserID(r)
 	if err != nil {
-		// utils.DebugPrint("Upgrade error:", err)
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
 		return
 	}
 
-	// Get authenticated user's email from session
-	session, err := auth.Store.Get(r, "authentication")
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		conn.Close()
-		return
-	}
-	email, ok := session.Values["email"].(string)
-	if !ok || email == "" {
-		conn.Close()
-		return
-	}
-
-	// Get user ID from database
-	var userID int
-	row := database.QueryRow("SELECT id FROM users WHERE email = ?", email)
-	if err := row.Scan(&userID); err != nil {
-		conn.Close()
+		// utils.DebugPrint("Upgrade error:", err)
 		return
 	}
 
@@ -286,7 +272,7 @@ func SimpleWebSocketEndpoint(w http.ResponseWriter, r *http.Request) {
 		hub:    globalHub,
 		conn:   conn,
 		send:   make(chan []byte, 256),
-		userID: userID,
+		userID: int(userID),
 	}
 	client.hub.register <- client
 	// utils.DebugPrint("New client connected. User ID:", userID, "Total clients:", len(globalHub.clients))
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 
-	"skillswap/backend/internal/database"
 	"skillswap/backend/internal/handlers/auth"
 	"skillswap/backend/internal/utils"
 )
@@ -13,7 +12,7 @@ import (
 // createCourseWithModules handles the complete course creation process
 func createCourseWithModules(r *http.Request, formData *CourseFormData, modules []ModuleFormData) (int64, string, error) {
 	// Get instructor ID from session
-	instructorID, err := getInstructorIDFromSession(r)
+	instructorID, err := auth.GetSessionUserID(r)
 	if err != nil {
 		return 0, "", err
 	}
@@ -111,26 +110,4 @@ func processModule(r *http.Request, courseID int64, moduleIndex int, module Modu
 	)
 }
 
-// getInstructorIDFromSession retrieves the instructor ID from the user's session
-func getInstructorIDFromSession(r *http.Request) (int64, error) {
-	session, err := auth.Store.Get(r, "authentication")
-	if err != nil {
-		return 0, fmt.Errorf("failed to get session: %w", err)
-	}
-
-	email, ok := session.Values["email"].(string)
-	if !ok || email == "" {
-		return 0, fmt.Errorf("invalid session")
-	}
-
-	// utils.DebugPrint("User creating course:", email)
-
-	instructorID, err := database.GetUserIDFromEmail(email)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get user ID: %w", err)
-	}
-
-	return instructorID, nil
-}
-
 
@@ -0,0 +1,42 @@
+package matches
+
+import (
+	"net/http"
+	"strconv"
+
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// GetMatches returns a page of the session user's reciprocal skill matches, best first.
+// Optional query parameters: "page" (from 1) and "limit" (default 20, max 100).
+func GetMatches(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	page, limit := 1, 20
+	if p, err := strconv.Atoi(req.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	matches, total, err := FindMatches(userID, limit, (page-1)*limit)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to find matches"})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"matches":  matches,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+		"has_more": page*limit < total,
+	})
+}
@@ -0,0 +1,216 @@
+package matches
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+)
+
+// Score weights
+const (
+	overlapPoints  = 10 // per skill that makes the swap work, in either direction
+	verifiedPoints = 5  // per verified skill the partner would teach
+)
+
+// stylePoints rewards teaching styles by how much guidance the learner gets
+var stylePoints = map[string]int{
+	models.StyleShowHow:   2,
+	models.StyleExplain:   3,
+	models.StyleHomework:  3,
+	models.StyleProfessor: 4,
+}
+
+// activityPoints rewards partners who were active recently
+var activityPoints = []struct {
+	within time.Duration
+	points int
+}{
+	{7 * 24 * time.Hour, 10},
+	{30 * 24 * time.Hour, 5},
+	{90 * 24 * time.Hour, 2},
+}
+
+// SkillMatch is a skill one side of a match can teach the other
+type SkillMatch struct {
+	SkillID       int64  `json:"skill_id"`
+	Name          string `json:"name"`
+	Verified      bool   `json:"verified"`
+	TeachingStyle string `json:"teaching_style"`
+}
+
+// Match is a user with whom the session user could swap skills both ways
+type Match struct {
+	UserID         int64        `json:"user_id"`
+	Username       string       `json:"username"`
+	ProfilePicture string       `json:"profile_picture"`
+	TheyTeach      []SkillMatch `json:"they_teach"` // skills the user wants that the partner teaches
+	YouTeach       []SkillMatch `json:"you_teach"`  // skills the partner wants that the user teaches
+	LastActive     *time.Time   `json:"last_active"`
+	Score          int          `json:"score"`
+}
+
+// Reciprocal reports whether both sides have something to teach the other
+func (m *Match) Reciprocal() bool {
+	return len(m.TheyTeach) > 0 && len(m.YouTeach) > 0
+}
+
+// score rates a match by skill overlap, the partner's verified skills and teaching styles,
+// and how recently the partner was active
+func score(m *Match, now time.Time) int {
+	total := overlapPoints * (len(m.TheyTeach) + len(m.YouTeach))
+	for _, s := range m.TheyTeach {
+		if s.Verified {
+			total += verifiedPoints
+		}
+		total += stylePoints[s.TeachingStyle]
+	}
+	if m.LastActive != nil {
+		idle := now.Sub(*m.LastActive)
+		for _, a := range activityPoints {
+			if idle <= a.within {
+				total += a.points
+				break
+			}
+		}
+	}
+	return total
+}
+
+// rank scores the reciprocal matches and sorts them best first, then by user ID
+func rank(candidates map[int64]*Match, now time.Time) []Match {
+	matches := []Match{}
+	for _, m := range candidates {
+		if !m.Reciprocal() {
+			continue
+		}
+		m.Score = score(m, now)
+		matches = append(matches, *m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].UserID < matches[j].UserID
+	})
+	return matches
+}
+
+// activityJoin adds each partner u's last message (am) and last swap request update (ar)
+const activityJoin = `
+		LEFT JOIN (
+			SELECT sender_id AS user_id, MAX(created_at) AS last_at FROM messages GROUP BY sender_id
+		) am ON am.user_id = u.id
+		LEFT JOIN (
+			SELECT user_id, MAX(updated_at) AS last_at FROM (
+				SELECT learner_id AS user_id, updated_at FROM swap_requests
+				UNION ALL
+				SELECT teacher_id AS user_id, updated_at FROM swap_requests
+			) r GROUP BY user_id
+		) ar ON ar.user_id = u.id`
+
+// FindMatches returns a page of the user's reciprocal matches, best first, and the total number of matches
+func FindMatches(userID int64, limit, offset int) ([]Match, int, error) {
+	candidates := map[int64]*Match{}
+	candidate := func(p partner) *Match {
+		m, ok := candidates[p.id]
+		if !ok {
+			m = &Match{UserID: p.id, Username: p.username, ProfilePicture: p.picture, LastActive: p.lastActive, TheyTeach: []SkillMatch{}, YouTeach: []SkillMatch{}}
+			candidates[p.id] = m
+		}
+		return m
+	}
+
+	// Partners teaching a skill the user wants
+	rows, err := database.Query(`
+		SELECT u.id, u.username, COALESCE(u.profile_picture, 'noPicture'),
+		       am.last_at, ar.last_at, COALESCE(u.updated_at, u.created_at),
+		       s.id, s.name, us.verified, us.teaching_skill
+		FROM user_wanted_skills uw
+		JOIN user_skills us ON us.skill_id = uw.skill_id AND us.user_id != uw.user_id
+		JOIN users u ON u.id = us.user_id
+		`+activityJoin+`
+		JOIN skills s ON s.id = uw.skill_id
+		WHERE uw.user_id = ?`, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = scanSkills(rows, func(p partner, s SkillMatch) {
+		m := candidate(p)
+		m.TheyTeach = append(m.TheyTeach, s)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Partners wanting a skill the user teaches
+	rows, err = database.Query(`
+		SELECT u.id, u.username, COALESCE(u.profile_picture, 'noPicture'),
+		       am.last_at, ar.last_at, COALESCE(u.updated_at, u.created_at),
+		       s.id, s.name, us.verified, us.teaching_skill
+		FROM user_skills us
+		JOIN user_wanted_skills uw ON uw.skill_id = us.skill_id AND uw.user_id != us.user_id
+		JOIN users u ON u.id = uw.user_id
+		`+activityJoin+`
+		JOIN skills s ON s.id = us.skill_id
+		WHERE us.user_id = ?`, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = scanSkills(rows, func(p partner, s SkillMatch) {
+		m := candidate(p)
+		m.YouTeach = append(m.YouTeach, s)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matches := rank(candidates, time.Now())
+	total := len(matches)
+	if offset >= total {
+		return []Match{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return matches[offset:end], total, nil
+}
+
+// partner is the user on the other side of a candidate match
+type partner struct {
+	id         int64
+	username   string
+	picture    string
+	lastActive *time.Time
+}
+
+func scanSkills(rows *sql.Rows, add func(p partner, s SkillMatch)) error {
+	defer rows.Close()
+	for rows.Next() {
+		var p partner
+		var message, request, profile sql.NullTime
+		var s SkillMatch
+		err := rows.Scan(&p.id, &p.username, &p.picture, &message, &request, &profile,
+			&s.SkillID, &s.Name, &s.Verified, &s.TeachingStyle)
+		if err != nil {
+			return err
+		}
+		p.lastActive = latest(message, request, profile)
+		add(p, s)
+	}
+	return rows.Err()
+}
+
+// latest returns the latest of the valid times, or nil if there are none
+func latest(times ...sql.NullTime) *time.Time {
+	var last *time.Time
+	for i := range times {
+		if times[i].Valid && (last == nil || times[i].Time.After(*last)) {
+			last = &times[i].Time
+		}
+	}
+	return last
+}
@@ -0,0 +1,135 @@
+package matches
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"skillswap/backend/internal/database"
+)
+
+// TestMain sets up the test environment for match tests
+func TestMain(m *testing.M) {
+	if err := database.SetupTestDB(); err != nil {
+		fmt.Printf("Failed to setup test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	database.ClearTestData()
+	database.TeardownTestDB()
+	os.Exit(code)
+}
+
+func TestRankKeepsOnlyReciprocalMatches(t *testing.T) {
+	now := time.Now()
+	candidates := map[int64]*Match{
+		1: {UserID: 1, TheyTeach: []SkillMatch{{Name: "Go"}}},
+		2: {UserID: 2, TheyTeach: []SkillMatch{{Name: "Go"}}, YouTeach: []SkillMatch{{Name: "Chess"}}},
+		3: {UserID: 3, YouTeach: []SkillMatch{{Name: "Chess"}}},
+	}
+
+	matches := rank(candidates, now)
+	if len(matches) != 1 || matches[0].UserID != 2 {
+		t.Fatalf("Expected only user 2 to match, got %+v", matches)
+	}
+}
+
+func TestRankOrdersByScore(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-24 * time.Hour)
+	stale := now.Add(-365 * 24 * time.Hour)
+	candidates := map[int64]*Match{
+		// One skill each way, unverified, long inactive
+		1: {UserID: 1, TheyTeach: []SkillMatch{{Name: "Go", TeachingStyle: "Show how it's done"}}, YouTeach: []SkillMatch{{Name: "Chess"}}, LastActive: &stale},
+		// Same overlap, but verified and recently active
+		2: {UserID: 2, TheyTeach: []SkillMatch{{Name: "Go", Verified: true, TeachingStyle: "Show how it's done"}}, YouTeach: []SkillMatch{{Name: "Chess"}}, LastActive: &recent},
+		// More overlap outweighs the rest
+		3: {UserID: 3, TheyTeach: []SkillMatch{{Name: "Go"}, {Name: "SQL"}, {Name: "Rust"}}, YouTeach: []SkillMatch{{Name: "Chess"}}},
+	}
+
+	matches := rank(candidates, now)
+	var order []int64
+	for _, m := range matches {
+		order = append(order, m.UserID)
+	}
+	if len(order) != 3 || order[0] != 3 || order[1] != 2 || order[2] != 1 {
+		t.Errorf("Expected order [3 2 1], got %v", order)
+	}
+}
+
+func TestScore(t *testing.T) {
+	now := time.Now()
+	active := now.Add(-10 * 24 * time.Hour)
+	m := &Match{
+		TheyTeach:  []SkillMatch{{Verified: true, TeachingStyle: "Professor"}},
+		YouTeach:   []SkillMatch{{}},
+		LastActive: &active,
+	}
+	want := 2*overlapPoints + verifiedPoints + stylePoints["Professor"] + 5
+	if got := score(m, now); got != want {
+		t.Errorf("Expected score %d, got %d", want, got)
+	}
+}
+
+func TestFindMatches(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testmatcher", 3)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	user, partner, oneWay := ids[0], ids[1], ids[2]
+
+	goSkill, err := database.InsertTestSkill("testmatchgo", "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	chess, err := database.InsertTestSkill("testmatchchess", "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	for _, q := range []struct {
+		query           string
+		userID, skillID int64
+	}{
+		{"INSERT INTO user_wanted_skills (user_id, skill_id) VALUES (?, ?)", user, goSkill},
+		{"INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", user, chess},
+		{"INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", partner, goSkill},
+		{"INSERT INTO user_wanted_skills (user_id, skill_id) VALUES (?, ?)", partner, chess},
+		// Teaches what the user wants but wants nothing back
+		{"INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", oneWay, goSkill},
+	} {
+		if _, err := database.Execute(q.query, q.userID, q.skillID); err != nil {
+			t.Fatalf("Failed to insert skill: %v", err)
+		}
+	}
+
+	chatID, err := database.InsertTestChat(user, partner)
+	if err != nil {
+		t.Fatalf("Failed to insert test chat: %v", err)
+	}
+	if _, err := database.Execute("INSERT INTO messages (chat_id, sender_id, content) VALUES (?, ?, 'hi')", chatID, partner); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+
+	matches, total, err := FindMatches(user, 10, 0)
+	if err != nil {
+		t.Fatalf("FindMatches failed: %v", err)
+	}
+	if total != 1 || len(matches) != 1 || matches[0].UserID != partner {
+		t.Fatalf("Expected the partner as the only match, got %d: %+v", total, matches)
+	}
+	m := matches[0]
+	if len(m.TheyTeach) != 1 || m.TheyTeach[0].SkillID != goSkill || len(m.YouTeach) != 1 || m.YouTeach[0].SkillID != chess {
+		t.Errorf("Unexpected skills in match %+v", m)
+	}
+	if m.LastActive == nil || time.Since(*m.LastActive) > time.Hour {
+		t.Errorf("Expected the partner to be recently active, got %v", m.LastActive)
+	}
+
+	if matches, total, err := FindMatches(user, 10, 1); err != nil || total != 1 || len(matches) != 0 {
+		t.Errorf("Expected an empty second page, got %d matches of %d (%v)", len(matches), total, err)
+	}
+}
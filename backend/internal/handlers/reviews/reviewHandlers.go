@@ -0,0 +1,114 @@
+package reviews
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// SendReviewError maps review and report errors to HTTP responses
+func SendReviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSwapNotFound), errors.Is(err, ErrReviewNotFound), errors.Is(err, ErrReportNotFound):
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrAlreadyReviewed), errors.Is(err, ErrAlreadyReported), errors.Is(err, ErrReportResolved),
+		errors.Is(err, ErrReviewWindowClosed):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrInvalidReview):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to process review"})
+	}
+}
+
+// SubmitReview reviews the session user's partner in a completed swap.
+// Expects {"swap_request_id": ..., "rating": 1-5, "text": "...", "skills": [{"skill_id": ..., "rating": 1-5, "feedback": "..."}]}.
+func SubmitReview(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var review Review
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil || review.SwapRequestID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	saved, err := Submit(userID, review)
+	if err != nil {
+		SendReviewError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, saved)
+}
+
+// GetUserReviews lists the published reviews about a user by the "user_id" query parameter
+func GetUserReviews(w http.ResponseWriter, req *http.Request) {
+	userID, err := strconv.ParseInt(req.URL.Query().Get("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return
+	}
+
+	reviews, err := ForUser(userID)
+	if err != nil {
+		SendReviewError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"reviews": reviews})
+}
+
+// GetSwapReviews lists the reviews of one of the session user's swaps by the "id" query parameter.
+// The partner's review is only included once it is published.
+func GetSwapReviews(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	swapID, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+	if err != nil || swapID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request ID"})
+		return
+	}
+
+	reviews, err := ForSwap(swapID, userID)
+	if err != nil {
+		SendReviewError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"reviews": reviews})
+}
+
+// ReportReviewRequest flags a published review as abusive.
+// Expects {"review_id": ..., "reason": "..."}.
+func ReportReviewRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		ReviewID int64  `json:"review_id"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ReviewID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := ReportReview(body.ReviewID, userID, body.Reason); err != nil {
+		SendReviewError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, map[string]string{"status": "Review reported"})
+}
@@ -0,0 +1,406 @@
+package reviews
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Report states
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportRemoved   = "removed"
+)
+
+var (
+	ErrSwapNotFound       = errors.New("completed swap not found")
+	ErrReviewWindowClosed = errors.New("the review window for this swap has closed")
+	ErrAlreadyReviewed    = errors.New("swap already reviewed")
+	ErrInvalidReview      = errors.New("invalid review")
+	ErrReviewNotFound     = errors.New("review not found")
+	ErrAlreadyReported    = errors.New("review already reported")
+	ErrReportNotFound     = errors.New("report not found")
+	ErrReportResolved     = errors.New("report was already resolved")
+)
+
+// SkillFeedback rates how a user did in one skill during a swap
+type SkillFeedback struct {
+	SkillID   int64  `json:"skill_id"`
+	SkillName string `json:"skill_name"`
+	Rating    int    `json:"rating"`
+	Feedback  string `json:"feedback"`
+}
+
+// Review is one party's review of the other after a completed swap
+type Review struct {
+	ID            int64           `json:"id"`
+	SwapRequestID int64           `json:"swap_request_id"`
+	ReviewerID    int64           `json:"reviewer_id"`
+	ReviewerName  string          `json:"reviewer_name"`
+	RevieweeID    int64           `json:"reviewee_id"`
+	Rating        int             `json:"rating"`
+	Text          string          `json:"text"`
+	Skills        []SkillFeedback `json:"skills"`
+	CreatedAt     time.Time       `json:"created_at"`
+	VisibleAt     time.Time       `json:"visible_at"`
+}
+
+// Report flags a review as abusive for an admin to look at
+type Report struct {
+	ID           int64     `json:"id"`
+	ReviewID     int64     `json:"review_id"`
+	ReporterID   int64     `json:"reporter_id"`
+	ReporterName string    `json:"reporter_name"`
+	Reason       string    `json:"reason"`
+	Status       string    `json:"status"`
+	Review       Review    `json:"review"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Validate checks the rating and text of a review and its skill feedback
+func (r *Review) Validate() error {
+	r.Text = strings.TrimSpace(r.Text)
+	if r.Rating < 1 || r.Rating > 5 || len(r.Text) > 5000 {
+		return ErrInvalidReview
+	}
+	seen := map[int64]bool{}
+	for i := range r.Skills {
+		s := &r.Skills[i]
+		s.Feedback = strings.TrimSpace(s.Feedback)
+		if s.SkillID <= 0 || seen[s.SkillID] || s.Rating < 1 || s.Rating > 5 || len(s.Feedback) > 2000 {
+			return ErrInvalidReview
+		}
+		seen[s.SkillID] = true
+	}
+	return nil
+}
+
+// Submit records the reviewer's review of their partner in a completed swap. The review stays
+// hidden until the partner reviews too or the review window closes; when the second review
+// arrives both are published.
+func Submit(reviewerID int64, review Review) (*Review, error) {
+	if err := review.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var learnerID, teacherID int64
+	var completedAt time.Time
+	// Swaps completed before completed_at was recorded fall back to their last update
+	err = tx.QueryRow(
+		"SELECT learner_id, teacher_id, COALESCE(completed_at, updated_at) FROM swap_requests WHERE id = ? AND status = 'completed' FOR UPDATE",
+		review.SwapRequestID,
+	).Scan(&learnerID, &teacherID, &completedAt)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && reviewerID != learnerID && reviewerID != teacherID) {
+		return nil, ErrSwapNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	deadline := completedAt.Add(config.ReviewWindow())
+	if !time.Now().Before(deadline) {
+		return nil, ErrReviewWindowClosed
+	}
+
+	revieweeID := teacherID
+	if reviewerID == teacherID {
+		revieweeID = learnerID
+	}
+	for _, s := range review.Skills {
+		var listed int
+		err := tx.QueryRow("SELECT COUNT(*) FROM user_skills WHERE user_id = ? AND skill_id = ?", revieweeID, s.SkillID).Scan(&listed)
+		if err != nil {
+			return nil, err
+		}
+		if listed == 0 {
+			return nil, ErrInvalidReview
+		}
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO swap_reviews (swap_request_id, reviewer_id, reviewee_id, rating, review_text, visible_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		review.SwapRequestID, reviewerID, revieweeID, review.Rating, review.Text, deadline,
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return nil, ErrAlreadyReviewed
+	}
+	if err != nil {
+		return nil, err
+	}
+	reviewID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range review.Skills {
+		_, err := tx.Exec(
+			"INSERT INTO swap_review_skills (review_id, skill_id, rating, feedback) VALUES (?, ?, ?, ?)",
+			reviewID, s.SkillID, s.Rating, s.Feedback,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Publish both reviews once the partner's is in
+	var both int
+	err = tx.QueryRow("SELECT COUNT(*) FROM swap_reviews WHERE swap_request_id = ?", review.SwapRequestID).Scan(&both)
+	if err != nil {
+		return nil, err
+	}
+	if both == 2 {
+		if _, err := tx.Exec("UPDATE swap_reviews SET visible_at = NOW() WHERE swap_request_id = ?", review.SwapRequestID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	reviews, err := query("WHERE r.id = ?", reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reviews) == 0 {
+		return nil, ErrReviewNotFound
+	}
+	return &reviews[0], nil
+}
+
+// ForUser returns the published reviews about a user, newest first
+func ForUser(userID int64) ([]Review, error) {
+	return query("WHERE r.reviewee_id = ? AND r.visible_at <= NOW() AND r.removed_at IS NULL ORDER BY r.visible_at DESC", userID)
+}
+
+// ForSwap returns the reviews of a swap the user took part in: their own review,
+// and their partner's once it is published
+func ForSwap(swapRequestID, userID int64) ([]Review, error) {
+	var participant int
+	err := database.QueryRow(
+		"SELECT COUNT(*) FROM swap_requests WHERE id = ? AND (learner_id = ? OR teacher_id = ?)",
+		swapRequestID, userID, userID,
+	).Scan(&participant)
+	if err != nil {
+		return nil, err
+	}
+	if participant == 0 {
+		return nil, ErrSwapNotFound
+	}
+	return query(`WHERE r.swap_request_id = ? AND r.removed_at IS NULL
+		AND (r.reviewer_id = ? OR r.visible_at <= NOW())
+		ORDER BY r.created_at`, swapRequestID, userID)
+}
+
+// ReportReview flags a published review as abusive
+func ReportReview(reviewID, reporterID int64, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > 1000 {
+		return ErrInvalidReview
+	}
+	var published int
+	err := database.QueryRow(
+		"SELECT COUNT(*) FROM swap_reviews WHERE id = ? AND visible_at <= NOW() AND removed_at IS NULL", reviewID,
+	).Scan(&published)
+	if err != nil {
+		return err
+	}
+	if published == 0 {
+		return ErrReviewNotFound
+	}
+
+	_, err = database.Execute(
+		"INSERT INTO review_reports (review_id, reporter_id, reason) VALUES (?, ?, ?)",
+		reviewID, reporterID, reason,
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrAlreadyReported
+	}
+	return err
+}
+
+// OpenReports lists reports waiting for an admin, oldest first
+func OpenReports() ([]Report, error) {
+	rows, err := database.Query(`
+		SELECT p.id, p.review_id, p.reporter_id, u.username, p.reason, p.status, p.created_at, `+reviewColumns+`
+		FROM review_reports p
+		JOIN users u ON u.id = p.reporter_id
+		JOIN swap_reviews r ON r.id = p.review_id
+		JOIN users ru ON ru.id = r.reviewer_id
+		WHERE p.status = ?
+		ORDER BY p.created_at ASC`, ReportOpen)
+	if err != nil {
+		return nil, err
+	}
+	reports := []Report{}
+	for rows.Next() {
+		var p Report
+		if err := rows.Scan(append([]interface{}{&p.ID, &p.ReviewID, &p.ReporterID, &p.ReporterName, &p.Reason, &p.Status, &p.CreatedAt},
+			reviewFields(&p.Review)...)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reports = append(reports, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reviews := make([]*Review, len(reports))
+	for i := range reports {
+		reviews[i] = &reports[i].Review
+	}
+	if err := loadSkillFeedback(reviews); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ResolveReport closes a report on an admin's decision. Removing the review hides it
+// and takes it out of the reviewee's reputation; every open report on it is closed too.
+func ResolveReport(reportID, adminID int64, remove bool, note string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var reviewID int64
+	var status string
+	err = tx.QueryRow("SELECT review_id, status FROM review_reports WHERE id = ? FOR UPDATE", reportID).Scan(&reviewID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrReportNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != ReportOpen {
+		return ErrReportResolved
+	}
+
+	decision := ReportDismissed
+	if remove {
+		decision = ReportRemoved
+		if _, err := tx.Exec("UPDATE swap_reviews SET removed_at = NOW() WHERE id = ? AND removed_at IS NULL", reviewID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			UPDATE review_reports SET status = ?, resolved_by = ?, resolution_note = ?, resolved_at = NOW()
+			WHERE review_id = ? AND status = ?`,
+			decision, adminID, nullString(strings.TrimSpace(note)), reviewID, ReportOpen)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE review_reports SET status = ?, resolved_by = ?, resolution_note = ?, resolved_at = NOW()
+			WHERE id = ?`,
+			decision, adminID, nullString(strings.TrimSpace(note)), reportID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reviewColumns are the columns of a review r written by ru, in the order reviewFields scans them
+const reviewColumns = `r.id, r.swap_request_id, r.reviewer_id, ru.username, r.reviewee_id, r.rating,
+	       COALESCE(r.review_text, ''), r.created_at, r.visible_at`
+
+const reviewQuery = `
+	SELECT ` + reviewColumns + `
+	FROM swap_reviews r
+	JOIN users ru ON ru.id = r.reviewer_id `
+
+// reviewFields returns the scan destinations for reviewColumns
+func reviewFields(r *Review) []interface{} {
+	r.Skills = []SkillFeedback{}
+	return []interface{}{&r.ID, &r.SwapRequestID, &r.ReviewerID, &r.ReviewerName, &r.RevieweeID, &r.Rating,
+		&r.Text, &r.CreatedAt, &r.VisibleAt}
+}
+
+// query loads the reviews matching the condition together with their skill feedback
+func query(condition string, args ...interface{}) ([]Review, error) {
+	rows, err := database.Query(reviewQuery+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	reviews := []Review{}
+	for rows.Next() {
+		var r Review
+		if err := rows.Scan(reviewFields(&r)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	loaded := make([]*Review, len(reviews))
+	for i := range reviews {
+		loaded[i] = &reviews[i]
+	}
+	if err := loadSkillFeedback(loaded); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// loadSkillFeedback adds their skill feedback to the reviews with one query
+func loadSkillFeedback(reviews []*Review) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+	// Several reports can carry the same review
+	byID := make(map[int64][]*Review, len(reviews))
+	args := make([]interface{}, 0, len(reviews))
+	for _, r := range reviews {
+		if _, ok := byID[r.ID]; !ok {
+			args = append(args, r.ID)
+		}
+		byID[r.ID] = append(byID[r.ID], r)
+	}
+	rows, err := database.Query(`
+		SELECT k.review_id, k.skill_id, s.name, k.rating, COALESCE(k.feedback, '')
+		FROM swap_review_skills k
+		JOIN skills s ON s.id = k.skill_id
+		WHERE k.review_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+`)
+		ORDER BY k.review_id, s.name`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reviewID int64
+		var s SkillFeedback
+		if err := rows.Scan(&reviewID, &s.SkillID, &s.SkillName, &s.Rating, &s.Feedback); err != nil {
+			return err
+		}
+		for _, r := range byID[reviewID] {
+			r.Skills = append(r.Skills, s)
+		}
+	}
+	return rows.Err()
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
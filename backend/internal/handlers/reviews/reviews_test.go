@@ -0,0 +1,179 @@
+package reviews
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"skillswap/backend/internal/database"
+)
+
+// TestMain sets up the test environment for review tests
+func TestMain(m *testing.M) {
+	if err := database.SetupTestDB(); err != nil {
+		fmt.Printf("Failed to setup test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	database.ClearTestData()
+	database.TeardownTestDB()
+	os.Exit(code)
+}
+
+func TestReviewsStayHiddenUntilBothSidesSubmit(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testreviewer", 3)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher, stranger := ids[0], ids[1], ids[2]
+
+	skillID, err := database.InsertTestSkill("testreviewedskill", "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	if _, err := database.Execute("INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", teacher, skillID); err != nil {
+		t.Fatalf("Failed to insert user skill: %v", err)
+	}
+	swapID, err := database.InsertTestCompletedSwap(learner, teacher, skillID, 1)
+	if err != nil {
+		t.Fatalf("Failed to insert swap: %v", err)
+	}
+
+	if _, err := Submit(stranger, Review{SwapRequestID: swapID, Rating: 5}); !errors.Is(err, ErrSwapNotFound) {
+		t.Errorf("Expected ErrSwapNotFound, got %v", err)
+	}
+	if _, err := Submit(learner, Review{SwapRequestID: swapID, Rating: 6}); !errors.Is(err, ErrInvalidReview) {
+		t.Errorf("Expected ErrInvalidReview, got %v", err)
+	}
+
+	review, err := Submit(learner, Review{
+		SwapRequestID: swapID,
+		Rating:        4,
+		Text:          "Patient teacher",
+		Skills:        []SkillFeedback{{SkillID: skillID, Rating: 5, Feedback: "Clear examples"}},
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if review.RevieweeID != teacher || len(review.Skills) != 1 {
+		t.Errorf("Expected a review of the teacher with skill feedback, got %+v", review)
+	}
+	if _, err := Submit(learner, Review{SwapRequestID: swapID, Rating: 4}); !errors.Is(err, ErrAlreadyReviewed) {
+		t.Errorf("Expected ErrAlreadyReviewed, got %v", err)
+	}
+
+	published, err := ForUser(teacher)
+	if err != nil {
+		t.Fatalf("ForUser failed: %v", err)
+	}
+	if len(published) != 0 {
+		t.Errorf("Expected the review to stay hidden until both sides submit, got %+v", published)
+	}
+	teacherView, err := ForSwap(swapID, teacher)
+	if err != nil {
+		t.Fatalf("ForSwap failed: %v", err)
+	}
+	if len(teacherView) != 0 {
+		t.Errorf("Expected the teacher not to see the learner's review yet, got %+v", teacherView)
+	}
+
+	if _, err := Submit(teacher, Review{SwapRequestID: swapID, Rating: 5, Text: "Keen learner"}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	published, err = ForUser(teacher)
+	if err != nil {
+		t.Fatalf("ForUser failed: %v", err)
+	}
+	if len(published) != 1 || published[0].Rating != 4 {
+		t.Errorf("Expected the learner's review to be published, got %+v", published)
+	}
+	teacherView, err = ForSwap(swapID, teacher)
+	if err != nil {
+		t.Fatalf("ForSwap failed: %v", err)
+	}
+	if len(teacherView) != 2 {
+		t.Errorf("Expected both reviews of the swap, got %+v", teacherView)
+	}
+}
+
+func TestReviewWindowAndReportRemoval(t *testing.T) {
+	database.ClearTestData()
+	t.Setenv("REVIEW_WINDOW_DAYS", "7")
+	ids, err := database.InsertTestUsers("testreviewer", 3)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher, admin := ids[0], ids[1], ids[2]
+
+	skillID, err := database.InsertTestSkill("testreportedskill", "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	late, err := database.InsertTestCompletedSwap(learner, teacher, skillID, 10)
+	if err != nil {
+		t.Fatalf("Failed to insert swap: %v", err)
+	}
+	if _, err := Submit(learner, Review{SwapRequestID: late, Rating: 1}); !errors.Is(err, ErrReviewWindowClosed) {
+		t.Errorf("Expected ErrReviewWindowClosed, got %v", err)
+	}
+
+	// Swaps completed without a completed_at are timed from their last update
+	undated, err := database.InsertTestCompletedSwap(learner, teacher, skillID, 0)
+	if err != nil {
+		t.Fatalf("Failed to insert swap: %v", err)
+	}
+	if _, err := database.Execute("UPDATE swap_requests SET completed_at = NULL WHERE id = ?", undated); err != nil {
+		t.Fatalf("Failed to clear completed_at: %v", err)
+	}
+	if _, err := Submit(learner, Review{SwapRequestID: undated, Rating: 4}); err != nil {
+		t.Errorf("Expected a swap without completed_at to be reviewable, got %v", err)
+	}
+
+	swapID, err := database.InsertTestCompletedSwap(learner, teacher, skillID, 1)
+	if err != nil {
+		t.Fatalf("Failed to insert swap: %v", err)
+	}
+	review, err := Submit(teacher, Review{SwapRequestID: swapID, Rating: 1, Text: "Abusive text"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if err := ReportReview(review.ID, learner, "Insulting"); !errors.Is(err, ErrReviewNotFound) {
+		t.Errorf("Expected hidden reviews not to be reportable, got %v", err)
+	}
+	if _, err := Submit(learner, Review{SwapRequestID: swapID, Rating: 5}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	if err := ReportReview(review.ID, learner, "Insulting"); err != nil {
+		t.Fatalf("ReportReview failed: %v", err)
+	}
+	if err := ReportReview(review.ID, learner, "Again"); !errors.Is(err, ErrAlreadyReported) {
+		t.Errorf("Expected ErrAlreadyReported, got %v", err)
+	}
+
+	reports, err := OpenReports()
+	if err != nil {
+		t.Fatalf("OpenReports failed: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Review.ID != review.ID {
+		t.Fatalf("Expected one open report of the review, got %+v", reports)
+	}
+	if err := ResolveReport(reports[0].ID, admin, true, "Abusive"); err != nil {
+		t.Fatalf("ResolveReport failed: %v", err)
+	}
+	if err := ResolveReport(reports[0].ID, admin, false, ""); !errors.Is(err, ErrReportResolved) {
+		t.Errorf("Expected ErrReportResolved, got %v", err)
+	}
+
+	published, err := ForUser(learner)
+	if err != nil {
+		t.Fatalf("ForUser failed: %v", err)
+	}
+	if len(published) != 0 {
+		t.Errorf("Expected the removed review to be hidden, got %+v", published)
+	}
+}
@@ -0,0 +1,135 @@
+package skills
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"skillswap/backend/internal/database"
+)
+
+// TestMain sets up the test environment for skill tests
+func TestMain(m *testing.M) {
+	if err := database.SetupTestDB(); err != nil {
+		fmt.Printf("Failed to setup test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	database.ClearTestData()
+	database.TeardownTestDB()
+	os.Exit(code)
+}
+
+func isVerified(t *testing.T, userID, skillID int64) bool {
+	t.Helper()
+	var verified bool
+	err := database.QueryRow("SELECT verified FROM user_skills WHERE user_id = ? AND skill_id = ?", userID, skillID).Scan(&verified)
+	if err != nil {
+		t.Fatalf("Failed to read user skill: %v", err)
+	}
+	return verified
+}
+
+func TestEndorsementsVerifySkillAtThreshold(t *testing.T) {
+	database.ClearTestData()
+	t.Setenv("SKILL_ENDORSEMENT_THRESHOLD", "2")
+	ids, err := database.InsertTestUsers("testendorser", 4)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	teacher, first, second, stranger := ids[0], ids[1], ids[2], ids[3]
+
+	skillID, err := database.InsertTestSkill("testendorsedskill", "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	if _, err := database.Execute("INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", teacher, skillID); err != nil {
+		t.Fatalf("Failed to insert user skill: %v", err)
+	}
+	if _, err := database.InsertTestCompletedSwap(first, teacher, skillID, 0); err != nil {
+		t.Fatalf("Failed to insert swap: %v", err)
+	}
+	if _, err := database.InsertTestCompletedSwap(second, teacher, skillID, 0); err != nil {
+		t.Fatalf("Failed to insert swap: %v", err)
+	}
+
+	if err := Endorse(stranger, teacher, skillID, ""); !errors.Is(err, ErrNoCompletedSwap) {
+		t.Errorf("Expected ErrNoCompletedSwap, got %v", err)
+	}
+	if err := Endorse(teacher, teacher, skillID, ""); !errors.Is(err, ErrSelfEndorsement) {
+		t.Errorf("Expected ErrSelfEndorsement, got %v", err)
+	}
+
+	if err := Endorse(first, teacher, skillID, "Great teacher"); err != nil {
+		t.Fatalf("Endorse failed: %v", err)
+	}
+	if err := Endorse(first, teacher, skillID, ""); !errors.Is(err, ErrAlreadyEndorsed) {
+		t.Errorf("Expected ErrAlreadyEndorsed, got %v", err)
+	}
+	if isVerified(t, teacher, skillID) {
+		t.Error("Expected the skill to stay unverified below the threshold")
+	}
+
+	if err := Endorse(second, teacher, skillID, ""); err != nil {
+		t.Fatalf("Endorse failed: %v", err)
+	}
+	if !isVerified(t, teacher, skillID) {
+		t.Error("Expected the skill to be verified at the threshold")
+	}
+
+	verifications, err := ListVerifications(teacher)
+	if err != nil {
+		t.Fatalf("ListVerifications failed: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Method != MethodEndorsements {
+		t.Errorf("Expected one endorsement verification, got %+v", verifications)
+	}
+	endorsements, err := ListEndorsements(teacher, skillID)
+	if err != nil {
+		t.Fatalf("ListEndorsements failed: %v", err)
+	}
+	if len(endorsements) != 2 || endorsements[0].SwapRequestID == nil {
+		t.Errorf("Expected two endorsements backed by swaps, got %+v", endorsements)
+	}
+}
+
+func TestAdminReviewsVerificationRequest(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testendorser", 2)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	user, admin := ids[0], ids[1]
+
+	skillID, err := database.InsertTestSkill("testrequestedskill", "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	if _, err := RequestVerification(user, skillID, "Certificate"); !errors.Is(err, ErrSkillNotListed) {
+		t.Errorf("Expected ErrSkillNotListed, got %v", err)
+	}
+	if _, err := database.Execute("INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", user, skillID); err != nil {
+		t.Fatalf("Failed to insert user skill: %v", err)
+	}
+
+	requestID, err := RequestVerification(user, skillID, "Certificate")
+	if err != nil {
+		t.Fatalf("RequestVerification failed: %v", err)
+	}
+	if _, err := RequestVerification(user, skillID, "Again"); !errors.Is(err, ErrAlreadyRequested) {
+		t.Errorf("Expected ErrAlreadyRequested, got %v", err)
+	}
+
+	if err := ReviewVerification(requestID, admin, true, "Looks good"); err != nil {
+		t.Fatalf("ReviewVerification failed: %v", err)
+	}
+	if !isVerified(t, user, skillID) {
+		t.Error("Expected the approved skill to be verified")
+	}
+	if err := ReviewVerification(requestID, admin, false, ""); !errors.Is(err, ErrRequestNotPending) {
+		t.Errorf("Expected ErrRequestNotPending, got %v", err)
+	}
+}
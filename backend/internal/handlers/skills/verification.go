@@ -0,0 +1,380 @@
+package skills
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Ways a skill can be verified
+const (
+	MethodAdmin        = "admin"
+	MethodEndorsements = "endorsements"
+	MethodCourse       = "course"
+)
+
+// Verification request states
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestRejected = "rejected"
+)
+
+var (
+	ErrSkillNotListed    = errors.New("user does not list that skill")
+	ErrNoCompletedSwap   = errors.New("endorsements need a completed swap with the user")
+	ErrAlreadyEndorsed   = errors.New("skill already endorsed")
+	ErrSelfEndorsement   = errors.New("users cannot endorse themselves")
+	ErrNoCompletedCourse = errors.New("no completed course in that skill")
+	ErrRequestNotFound   = errors.New("verification request not found")
+	ErrRequestNotPending = errors.New("verification request was already reviewed")
+	ErrInvalidEvidence   = errors.New("evidence must be between 1 and 2000 characters")
+	ErrAlreadyRequested  = errors.New("a verification request for that skill is already pending")
+	ErrInvalidComment    = errors.New("comment must be at most 500 characters")
+)
+
+// Endorsement is a swap partner vouching for a user's skill
+type Endorsement struct {
+	EndorserID    int64     `json:"endorser_id"`
+	EndorserName  string    `json:"endorser_name"`
+	SkillID       int64     `json:"skill_id"`
+	SkillName     string    `json:"skill_name"`
+	SwapRequestID *int64    `json:"swap_request_id"`
+	Comment       string    `json:"comment"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Verification is one piece of evidence behind a verified skill
+type Verification struct {
+	SkillID    int64     `json:"skill_id"`
+	SkillName  string    `json:"skill_name"`
+	Method     string    `json:"method"`
+	Evidence   string    `json:"evidence"`
+	CourseID   *int64    `json:"course_id"`
+	ApprovedBy *int64    `json:"approved_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// VerificationRequest asks an admin to verify a skill based on the evidence given
+type VerificationRequest struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Username   string     `json:"username"`
+	SkillID    int64      `json:"skill_id"`
+	SkillName  string     `json:"skill_name"`
+	Evidence   string     `json:"evidence"`
+	Status     string     `json:"status"`
+	ReviewedBy *int64     `json:"reviewed_by"`
+	ReviewNote string     `json:"review_note"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+// Endorse records endorserID vouching for userID's skill. The two must have completed a swap
+// together. Once enough partners endorsed the skill it becomes verified.
+func Endorse(endorserID, userID, skillID int64, comment string) error {
+	if endorserID == userID {
+		return ErrSelfEndorsement
+	}
+	comment = strings.TrimSpace(comment)
+	if len(comment) > 500 {
+		return ErrInvalidComment
+	}
+	if err := requireListed(userID, skillID); err != nil {
+		return err
+	}
+
+	// Prefer a swap in which the endorsed user taught this very skill
+	var swapID int64
+	err := database.QueryRow(`
+		SELECT id FROM swap_requests
+		WHERE status = 'completed'
+		  AND ((learner_id = ? AND teacher_id = ?) OR (learner_id = ? AND teacher_id = ?))
+		ORDER BY (teacher_id = ? AND skill_id = ?) DESC, completed_at DESC
+		LIMIT 1`,
+		endorserID, userID, userID, endorserID, userID, skillID,
+	).Scan(&swapID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoCompletedSwap
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Execute(
+		"INSERT INTO skill_endorsements (endorser_id, user_id, skill_id, swap_request_id, comment) VALUES (?, ?, ?, ?, ?)",
+		endorserID, userID, skillID, swapID, nullString(comment),
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrAlreadyEndorsed
+	}
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = database.QueryRow("SELECT COUNT(*) FROM skill_endorsements WHERE user_id = ? AND skill_id = ?", userID, skillID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count < config.EndorsementThreshold() {
+		return nil
+	}
+	return grant(userID, skillID, MethodEndorsements, fmt.Sprintf("Endorsed by %d swap partners", count), 0, 0)
+}
+
+// VerifyByCourse verifies a user's skill if they completed a course teaching it
+func VerifyByCourse(userID, skillID int64) error {
+	if err := requireListed(userID, skillID); err != nil {
+		return err
+	}
+	var courseID int64
+	var title string
+	err := database.QueryRow(`
+		SELECT c.id, c.title
+		FROM course_enrollments e
+		JOIN courses c ON c.id = e.course_id
+		WHERE e.student_id = ? AND c.skill_id = ? AND e.completed_at IS NOT NULL AND e.progress >= 100
+		ORDER BY e.completed_at ASC
+		LIMIT 1`, userID, skillID,
+	).Scan(&courseID, &title)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoCompletedCourse
+	}
+	if err != nil {
+		return err
+	}
+	return grant(userID, skillID, MethodCourse, fmt.Sprintf("Completed the course %q", title), courseID, 0)
+}
+
+// RequestVerification asks admins to verify a user's skill based on their evidence
+func RequestVerification(userID, skillID int64, evidence string) (int64, error) {
+	evidence = strings.TrimSpace(evidence)
+	if evidence == "" || len(evidence) > 2000 {
+		return 0, ErrInvalidEvidence
+	}
+	if err := requireListed(userID, skillID); err != nil {
+		return 0, err
+	}
+	var pending int
+	err := database.QueryRow(
+		"SELECT COUNT(*) FROM skill_verification_requests WHERE user_id = ? AND skill_id = ? AND status = ?",
+		userID, skillID, RequestPending,
+	).Scan(&pending)
+	if err != nil {
+		return 0, err
+	}
+	if pending > 0 {
+		return 0, ErrAlreadyRequested
+	}
+
+	result, err := database.Execute(
+		"INSERT INTO skill_verification_requests (user_id, skill_id, evidence) VALUES (?, ?, ?)",
+		userID, skillID, evidence,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ReviewVerification approves or rejects a pending verification request on an admin's behalf
+func ReviewVerification(requestID, adminID int64, approve bool, note string) error {
+	var userID, skillID int64
+	var status, evidence string
+	err := database.QueryRow(
+		"SELECT user_id, skill_id, status, evidence FROM skill_verification_requests WHERE id = ?", requestID,
+	).Scan(&userID, &skillID, &status, &evidence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRequestNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != RequestPending {
+		return ErrRequestNotPending
+	}
+
+	decision := RequestRejected
+	if approve {
+		decision = RequestApproved
+	}
+	result, err := database.Execute(`
+		UPDATE skill_verification_requests
+		SET status = ?, reviewed_by = ?, review_note = ?, reviewed_at = NOW()
+		WHERE id = ? AND status = ?`,
+		decision, adminID, nullString(strings.TrimSpace(note)), requestID, RequestPending,
+	)
+	if err != nil {
+		return err
+	}
+	// Another admin may have reviewed it in the meantime
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRequestNotPending
+	}
+	if !approve {
+		return nil
+	}
+	return grant(userID, skillID, MethodAdmin, evidence, 0, adminID)
+}
+
+// PendingVerifications lists verification requests waiting for an admin, oldest first
+func PendingVerifications() ([]VerificationRequest, error) {
+	rows, err := database.Query(`
+		SELECT r.id, r.user_id, u.username, r.skill_id, s.name, r.evidence, r.status,
+		       r.reviewed_by, COALESCE(r.review_note, ''), r.created_at, r.reviewed_at
+		FROM skill_verification_requests r
+		JOIN users u ON u.id = r.user_id
+		JOIN skills s ON s.id = r.skill_id
+		WHERE r.status = ?
+		ORDER BY r.created_at ASC`, RequestPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []VerificationRequest{}
+	for rows.Next() {
+		var r VerificationRequest
+		var reviewedBy sql.NullInt64
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Username, &r.SkillID, &r.SkillName, &r.Evidence, &r.Status,
+			&reviewedBy, &r.ReviewNote, &r.CreatedAt, &reviewedAt); err != nil {
+			return nil, err
+		}
+		if reviewedBy.Valid {
+			r.ReviewedBy = &reviewedBy.Int64
+		}
+		if reviewedAt.Valid {
+			r.ReviewedAt = &reviewedAt.Time
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+// ListEndorsements returns the endorsements of a user's skills, newest first.
+// A non-zero skillID limits them to that skill.
+func ListEndorsements(userID, skillID int64) ([]Endorsement, error) {
+	query := `
+		SELECT e.endorser_id, u.username, e.skill_id, s.name, e.swap_request_id, COALESCE(e.comment, ''), e.created_at
+		FROM skill_endorsements e
+		JOIN users u ON u.id = e.endorser_id
+		JOIN skills s ON s.id = e.skill_id
+		WHERE e.user_id = ?`
+	args := []interface{}{userID}
+	if skillID != 0 {
+		query += " AND e.skill_id = ?"
+		args = append(args, skillID)
+	}
+	rows, err := database.Query(query+" ORDER BY e.created_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endorsements := []Endorsement{}
+	for rows.Next() {
+		var e Endorsement
+		var swapID sql.NullInt64
+		if err := rows.Scan(&e.EndorserID, &e.EndorserName, &e.SkillID, &e.SkillName, &swapID, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if swapID.Valid {
+			e.SwapRequestID = &swapID.Int64
+		}
+		endorsements = append(endorsements, e)
+	}
+	return endorsements, rows.Err()
+}
+
+// ListVerifications returns the evidence behind each of a user's verified skills
+func ListVerifications(userID int64) ([]Verification, error) {
+	rows, err := database.Query(`
+		SELECT v.skill_id, s.name, v.method, COALESCE(v.evidence, ''), v.course_id, v.approved_by, v.created_at
+		FROM skill_verifications v
+		JOIN skills s ON s.id = v.skill_id
+		WHERE v.user_id = ?
+		ORDER BY s.name, v.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	verifications := []Verification{}
+	for rows.Next() {
+		var v Verification
+		var courseID, approvedBy sql.NullInt64
+		if err := rows.Scan(&v.SkillID, &v.SkillName, &v.Method, &v.Evidence, &courseID, &approvedBy, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		if courseID.Valid {
+			v.CourseID = &courseID.Int64
+		}
+		if approvedBy.Valid {
+			v.ApprovedBy = &approvedBy.Int64
+		}
+		verifications = append(verifications, v)
+	}
+	return verifications, rows.Err()
+}
+
+// grant records a verification and sets the cached verified flag. A method that
+// already verified the skill keeps its original evidence.
+func grant(userID, skillID int64, method, evidence string, courseID, approvedBy int64) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT IGNORE INTO skill_verifications (user_id, skill_id, method, evidence, course_id, approved_by) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, skillID, method, evidence, nullID(courseID), nullID(approvedBy),
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE user_skills SET verified = 1 WHERE user_id = ? AND skill_id = ?", userID, skillID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func requireListed(userID, skillID int64) error {
+	var listed int
+	err := database.QueryRow("SELECT COUNT(*) FROM user_skills WHERE user_id = ? AND skill_id = ?", userID, skillID).Scan(&listed)
+	if err != nil {
+		return err
+	}
+	if listed == 0 {
+		return ErrSkillNotListed
+	}
+	return nil
+}
+
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package skills
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// SendVerificationError maps endorsement and verification errors to HTTP responses
+func SendVerificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrSkillNotListed), errors.Is(err, ErrRequestNotFound):
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrNoCompletedSwap), errors.Is(err, ErrSelfEndorsement):
+		utils.SendJSONResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrAlreadyEndorsed), errors.Is(err, ErrAlreadyRequested), errors.Is(err, ErrRequestNotPending),
+		errors.Is(err, ErrNoCompletedCourse):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrInvalidEvidence), errors.Is(err, ErrInvalidComment):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to process skill verification"})
+	}
+}
+
+// EndorseSkill lets the session user endorse the skill of someone they completed a swap with.
+// Expects {"user_id": ..., "skill_id": ..., "comment": "..."}.
+func EndorseSkill(w http.ResponseWriter, req *http.Request) {
+	endorserID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		UserID  int64  `json:"user_id"`
+		SkillID int64  `json:"skill_id"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.UserID <= 0 || body.SkillID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := Endorse(endorserID, body.UserID, body.SkillID, body.Comment); err != nil {
+		SendVerificationError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, map[string]string{"status": "Skill endorsed"})
+}
+
+// GetEndorsements lists the endorsements of a user's skills by the "user_id" query
+// parameter, optionally limited to one "skill_id"
+func GetEndorsements(w http.ResponseWriter, req *http.Request) {
+	userID, err := strconv.ParseInt(req.URL.Query().Get("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return
+	}
+	skillID, _ := strconv.ParseInt(req.URL.Query().Get("skill_id"), 10, 64)
+
+	endorsements, err := ListEndorsements(userID, skillID)
+	if err != nil {
+		SendVerificationError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"endorsements": endorsements})
+}
+
+// GetVerifications lists the evidence behind a user's verified skills by the "user_id" query parameter
+func GetVerifications(w http.ResponseWriter, req *http.Request) {
+	userID, err := strconv.ParseInt(req.URL.Query().Get("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return
+	}
+
+	verifications, err := ListVerifications(userID)
+	if err != nil {
+		SendVerificationError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"verifications": verifications})
+}
+
+// RequestSkillVerification asks admins to verify one of the session user's skills.
+// Expects {"skill_id": ..., "evidence": "..."}.
+func RequestSkillVerification(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		SkillID  int64  `json:"skill_id"`
+		Evidence string `json:"evidence"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.SkillID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	id, err := RequestVerification(userID, body.SkillID, body.Evidence)
+	if err != nil {
+		SendVerificationError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "status": RequestPending})
+}
+
+// VerifySkillByCourse verifies one of the session user's skills if they completed a course in it.
+// Expects {"skill_id": ...}.
+func VerifySkillByCourse(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		SkillID int64 `json:"skill_id"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.SkillID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	if err := VerifyByCourse(userID, body.SkillID); err != nil {
+		SendVerificationError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]string{"status": "Skill verified"})
+}
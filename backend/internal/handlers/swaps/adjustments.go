@@ -0,0 +1,115 @@
+package swaps
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+)
+
+// ErrReasonRequired is returned when an admin adjustment comes without a reason
+var ErrReasonRequired = errors.New("an adjustment needs a reason")
+
+// maxNoteLength matches swap_transactions.note
+const maxNoteLength = 500
+
+// Adjust changes a user's balance on an admin's behalf, recording who did it and why.
+// A positive amount grants credits and a negative one takes them away; the balance
+// never goes negative nor past the cap.
+func Adjust(userID int64, amount int, adminID int64, reason string) error {
+	reason, err := adjustmentNote(reason)
+	if err != nil {
+		return err
+	}
+	e := entry{amount: amount, reason: models.ReasonAdjustment, actorID: adminID, note: reason}
+	if amount >= 0 {
+		e.to = userID
+	} else {
+		e.from, e.amount = userID, -amount
+	}
+	return inTx(func(tx *sql.Tx) error {
+		return record(tx, e)
+	})
+}
+
+// BulkGrantResult tells how a bulk grant went
+type BulkGrantResult struct {
+	Granted int     `json:"granted"`
+	Skipped []int64 `json:"skipped"` // users the grant would have taken past the cap
+}
+
+// GrantToSkill grants amount credits to every user who teaches the skill, on an admin's
+// behalf. Users the grant would take past the balance cap are skipped rather than failing
+// the whole grant, which otherwise happens in one transaction.
+func GrantToSkill(skillID int64, amount int, adminID int64, reason string) (*BulkGrantResult, error) {
+	reason, err := adjustmentNote(reason)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	rows, err := database.Query("SELECT DISTINCT user_id FROM user_skills WHERE skill_id = ? ORDER BY user_id", skillID)
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var result *BulkGrantResult
+	err = inTx(func(tx *sql.Tx) error {
+		result = &BulkGrantResult{Skipped: []int64{}}
+		reference := fmt.Sprintf("skill:%d", skillID)
+		for _, id := range userIDs {
+			var balance int
+			err := tx.QueryRow("SELECT swaps FROM users WHERE id = ? FOR UPDATE", id).Scan(&balance)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if exceedsCap(balance, amount, config.MaxBalance()) {
+				result.Skipped = append(result.Skipped, id)
+				continue
+			}
+			err = record(tx, entry{to: id, amount: amount, reason: models.ReasonAdjustment, reference: reference, actorID: adminID, note: reason})
+			if err != nil {
+				return err
+			}
+			result.Granted++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func adjustmentNote(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", ErrReasonRequired
+	}
+	if len(reason) > maxNoteLength {
+		return "", fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidRequest, maxNoteLength)
+	}
+	return reason, nil
+}
@@ -0,0 +1,222 @@
+package swaps
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+)
+
+// swapPrice is the number of credits a learner pays for a swap
+const swapPrice = 1
+
+// Escrow states
+const (
+	EscrowHeld     = "held"
+	EscrowReleased = "released"
+	EscrowRefunded = "refunded"
+	EscrowSplit    = "split"
+)
+
+var (
+	ErrEscrowNotHeld = errors.New("swap escrow is not held")
+	ErrInvalidSplit  = errors.New("teacher share must be between zero and the escrowed amount")
+)
+
+// Escrow holds a learner's credits for an accepted swap until it is settled
+type Escrow struct {
+	RequestID     int64      `json:"request_id"`
+	LearnerID     int64      `json:"learner_id"`
+	TeacherID     int64      `json:"teacher_id"`
+	Amount        int        `json:"amount"`
+	Status        string     `json:"status"`
+	DisputedBy    *int64     `json:"disputed_by"`
+	DisputeReason string     `json:"dispute_reason"`
+	DisputedAt    *time.Time `json:"disputed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// holdEscrow moves the swap price from the learner into the request's escrow
+func holdEscrow(tx *sql.Tx, r *lockedRequest) error {
+	err := record(tx, entry{
+		from:      r.LearnerID,
+		amount:    swapPrice,
+		reason:    models.ReasonEscrowHold,
+		reference: RequestReference(r.ID),
+		escrowID:  r.ID,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO swap_escrows (request_id, learner_id, teacher_id, amount) VALUES (?, ?, ?, ?)",
+		r.ID, r.LearnerID, r.TeacherID, swapPrice,
+	)
+	return err
+}
+
+// lockEscrow reads a held escrow and locks it until the transaction ends
+func lockEscrow(tx *sql.Tx, requestID int64) (*Escrow, error) {
+	var e Escrow
+	var learnerID, teacherID sql.NullInt64
+	err := tx.QueryRow(
+		"SELECT request_id, learner_id, teacher_id, amount, status FROM swap_escrows WHERE request_id = ? FOR UPDATE", requestID,
+	).Scan(&e.RequestID, &learnerID, &teacherID, &e.Amount, &e.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEscrowNotHeld
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != EscrowHeld {
+		return nil, ErrEscrowNotHeld
+	}
+	e.LearnerID, e.TeacherID = learnerID.Int64, teacherID.Int64
+	return &e, nil
+}
+
+// releaseEscrow pays the whole escrow to the teacher
+func releaseEscrow(tx *sql.Tx, requestID int64) error {
+	e, err := lockEscrow(tx, requestID)
+	if err != nil {
+		return err
+	}
+	return payOut(tx, e, e.Amount, 0, "")
+}
+
+// refundEscrow returns the whole escrow to the learner
+func refundEscrow(tx *sql.Tx, requestID int64) error {
+	e, err := lockEscrow(tx, requestID)
+	if err != nil {
+		return err
+	}
+	return payOut(tx, e, 0, 0, "")
+}
+
+// payOut settles a locked escrow, paying teacherShare to the teacher and the rest back to the learner.
+// If the teacher's account is gone their share is refunded too, so no credits leave the ledger.
+// settledBy is the admin who decided a dispute, or zero when the escrow settled on its own.
+func payOut(tx *sql.Tx, e *Escrow, teacherShare int, settledBy int64, note string) error {
+	if teacherShare < 0 || teacherShare > e.Amount {
+		return ErrInvalidSplit
+	}
+	if e.TeacherID == 0 {
+		teacherShare = 0
+	}
+	reference := RequestReference(e.RequestID)
+	if teacherShare > 0 {
+		err := record(tx, entry{to: e.TeacherID, amount: teacherShare, reason: models.ReasonEscrowRelease, reference: reference, escrowID: e.RequestID})
+		if err != nil {
+			return err
+		}
+	}
+	if refund := e.Amount - teacherShare; refund > 0 && e.LearnerID != 0 {
+		err := record(tx, entry{to: e.LearnerID, amount: refund, reason: models.ReasonEscrowRefund, reference: reference, escrowID: e.RequestID})
+		if err != nil {
+			return err
+		}
+	}
+
+	status := EscrowSplit
+	switch teacherShare {
+	case e.Amount:
+		status = EscrowReleased
+	case 0:
+		status = EscrowRefunded
+	}
+	_, err := tx.Exec(
+		"UPDATE swap_escrows SET status = ?, settled_by = ?, settlement_note = ?, settled_at = NOW() WHERE request_id = ?",
+		status, nullID(settledBy), nullString(note), e.RequestID,
+	)
+	return err
+}
+
+// DisputeSwap lets either party of an accepted swap ask an admin to settle its escrow.
+// The swap can then no longer be completed, cancelled or expire on its own.
+func DisputeSwap(requestID, userID int64, reason string) (*SwapRequest, error) {
+	if reason == "" || len(reason) > 2000 {
+		return nil, ErrInvalidRequest
+	}
+	return transition(requestID, func(tx *sql.Tx, r *lockedRequest) error {
+		if r.LearnerID != userID && r.TeacherID != userID {
+			return ErrRequestNotFound
+		}
+		if r.Status != StatusAccepted {
+			return ErrInvalidTransition
+		}
+		if _, err := lockEscrow(tx, r.ID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			"UPDATE swap_escrows SET disputed_by = ?, dispute_reason = ?, disputed_at = NOW() WHERE request_id = ?",
+			userID, reason, r.ID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE swap_requests SET status = ? WHERE id = ?", StatusDisputed, r.ID)
+		return err
+	})
+}
+
+// ResolveDispute settles a disputed swap's escrow on an admin's decision. teacherShare credits go
+// to the teacher and the rest is refunded to the learner; zero reverses the swap entirely.
+func ResolveDispute(requestID, adminID int64, teacherShare int, note string) (*SwapRequest, error) {
+	if note == "" {
+		return nil, ErrInvalidRequest
+	}
+	return transition(requestID, func(tx *sql.Tx, r *lockedRequest) error {
+		if r.Status != StatusDisputed {
+			return ErrInvalidTransition
+		}
+		e, err := lockEscrow(tx, r.ID)
+		if err != nil {
+			return err
+		}
+		if err := payOut(tx, e, teacherShare, adminID, note); err != nil {
+			return err
+		}
+		if teacherShare > 0 {
+			_, err = tx.Exec("UPDATE swap_requests SET status = ?, completed_at = NOW() WHERE id = ?", StatusCompleted, r.ID)
+		} else {
+			_, err = tx.Exec("UPDATE swap_requests SET status = ? WHERE id = ?", StatusCancelled, r.ID)
+		}
+		return err
+	})
+}
+
+// ListDisputes returns the escrows of disputed swaps, oldest dispute first
+func ListDisputes() ([]Escrow, error) {
+	rows, err := database.Query(`
+		SELECT e.request_id, COALESCE(e.learner_id, 0), COALESCE(e.teacher_id, 0), e.amount, e.status,
+		       e.disputed_by, COALESCE(e.dispute_reason, ''), e.disputed_at, e.created_at
+		FROM swap_escrows e
+		JOIN swap_requests r ON r.id = e.request_id
+		WHERE r.status = ?
+		ORDER BY e.disputed_at ASC`, StatusDisputed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	escrows := []Escrow{}
+	for rows.Next() {
+		var e Escrow
+		var disputedBy sql.NullInt64
+		var disputedAt sql.NullTime
+		if err := rows.Scan(&e.RequestID, &e.LearnerID, &e.TeacherID, &e.Amount, &e.Status,
+			&disputedBy, &e.DisputeReason, &disputedAt, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if disputedBy.Valid {
+			e.DisputedBy = &disputedBy.Int64
+		}
+		if disputedAt.Valid {
+			e.DisputedAt = &disputedAt.Time
+		}
+		escrows = append(escrows, e)
+	}
+	return escrows, rows.Err()
+}
@@ -0,0 +1,162 @@
+package swaps
+
+import (
+	"database/sql"
+	"time"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+)
+
+// Categories a history entry falls into, as shown to the user
+const (
+	CategoryEarned     = "earned"
+	CategorySpent      = "spent"
+	CategoryAdjustment = "adjustment"
+	CategoryRefund     = "refund"
+)
+
+// HistoryEntry is a ledger entry seen from one user's side. Amount is positive
+// when the user received credits and negative when they gave them up.
+type HistoryEntry struct {
+	ID               int64     `json:"id"`
+	Category         string    `json:"category"`
+	Amount           int       `json:"amount"`
+	Reason           string    `json:"reason"`
+	Reference        string    `json:"reference"`
+	CounterpartyID   *int64    `json:"counterparty_id"`
+	CounterpartyName string    `json:"counterparty_name"`
+	RequestID        *int64    `json:"request_id"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// MonthlySummary totals a user's credit movements in one calendar month
+type MonthlySummary struct {
+	Month          string `json:"month"` // YYYY-MM
+	Earned         int    `json:"earned"`
+	Spent          int    `json:"spent"`
+	Adjustments    int    `json:"adjustments"`
+	Refunds        int    `json:"refunds"`
+	Net            int    `json:"net"`
+	OpeningBalance int    `json:"opening_balance"`
+	ClosingBalance int    `json:"closing_balance"`
+}
+
+// categorize sorts a ledger entry into a history category by its reason and direction
+func categorize(reason string, amount int) string {
+	switch reason {
+	case models.ReasonAdjustment:
+		return CategoryAdjustment
+	case models.ReasonEscrowRefund, models.ReasonSwapRefund:
+		return CategoryRefund
+	}
+	if amount < 0 {
+		return CategorySpent
+	}
+	return CategoryEarned
+}
+
+// The counterparty is the other user of a transfer, or the other party of the swap
+// whose escrow the credits entered or left
+const historyQuery = `
+	SELECT t.id, t.reason, COALESCE(t.reference, ''), t.escrow_request_id, COALESCE(t.note, ''), t.created_at,
+	       CASE WHEN t.to_user_id = ? THEN t.amount ELSE -t.amount END,
+	       c.id, COALESCE(c.username, '')
+	FROM swap_transactions t
+	LEFT JOIN swap_escrows e ON e.request_id = t.escrow_request_id
+	LEFT JOIN users c ON c.id = CASE
+		WHEN t.from_user_id IS NOT NULL AND t.to_user_id IS NOT NULL THEN
+			CASE WHEN t.to_user_id = ? THEN t.from_user_id ELSE t.to_user_id END
+		WHEN e.learner_id = ? THEN e.teacher_id
+		ELSE e.learner_id
+	END
+	WHERE (t.to_user_id = ? OR t.from_user_id = ?)`
+
+func historyArgs(userID int64) []interface{} {
+	return []interface{}{userID, userID, userID, userID, userID}
+}
+
+func scanHistory(rows *sql.Rows) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	for rows.Next() {
+		var h HistoryEntry
+		var requestID, counterpartyID sql.NullInt64
+		err := rows.Scan(&h.ID, &h.Reason, &h.Reference, &requestID, &h.Note, &h.CreatedAt, &h.Amount, &counterpartyID, &h.CounterpartyName)
+		if err != nil {
+			return nil, err
+		}
+		if requestID.Valid {
+			h.RequestID = &requestID.Int64
+		}
+		if counterpartyID.Valid {
+			h.CounterpartyID = &counterpartyID.Int64
+		}
+		h.Category = categorize(h.Reason, h.Amount)
+		entries = append(entries, h)
+	}
+	return entries, rows.Err()
+}
+
+// History returns a page of the user's credit history, newest first, and the total number of entries
+func History(userID int64, limit, offset int) ([]HistoryEntry, int, error) {
+	var total int
+	err := database.QueryRow(
+		"SELECT COUNT(*) FROM swap_transactions WHERE to_user_id = ? OR from_user_id = ?", userID, userID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := database.Query(historyQuery+" ORDER BY t.created_at DESC, t.id DESC LIMIT ? OFFSET ?",
+		append(historyArgs(userID), limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries, err := scanHistory(rows)
+	return entries, total, err
+}
+
+// FullHistory returns the user's whole credit history, oldest first
+func FullHistory(userID int64) ([]HistoryEntry, error) {
+	rows, err := database.Query(historyQuery+" ORDER BY t.created_at ASC, t.id ASC", historyArgs(userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanHistory(rows)
+}
+
+// Summarize totals an oldest-first history per calendar month, newest month first.
+// Opening and closing balances follow from the running total of the whole history.
+func Summarize(entries []HistoryEntry) []MonthlySummary {
+	summaries := []MonthlySummary{}
+	balance := 0
+	for _, h := range entries {
+		month := h.CreatedAt.Format("2006-01")
+		if len(summaries) == 0 || summaries[len(summaries)-1].Month != month {
+			summaries = append(summaries, MonthlySummary{Month: month, OpeningBalance: balance})
+		}
+		s := &summaries[len(summaries)-1]
+		switch h.Category {
+		case CategoryEarned:
+			s.Earned += h.Amount
+		case CategorySpent:
+			s.Spent -= h.Amount
+		case CategoryAdjustment:
+			s.Adjustments += h.Amount
+		case CategoryRefund:
+			s.Refunds += h.Amount
+		}
+		s.Net += h.Amount
+		balance += h.Amount
+		s.ClosingBalance = balance
+	}
+
+	for i, j := 0, len(summaries)-1; i < j; i, j = i+1, j-1 {
+		summaries[i], summaries[j] = summaries[j], summaries[i]
+	}
+	return summaries
+}
@@ -0,0 +1,139 @@
+package swaps
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// GetSwapHistory returns a page of the session user's credit history, newest first.
+// Optional query parameters: "page" (from 1) and "limit" (default 50, max 200).
+func GetSwapHistory(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	page, limit := 1, 50
+	if p, err := strconv.Atoi(req.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	entries, total, err := History(userID, limit, (page-1)*limit)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve swap history"})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{
+		"transactions": entries,
+		"page":         page,
+		"limit":        limit,
+		"total":        total,
+		"has_more":     page*limit < total,
+	})
+}
+
+// ExportSwapHistory sends the session user's whole credit history as a CSV file, oldest first
+func ExportSwapHistory(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	entries, err := FullHistory(userID)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve swap history"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="swap-history.csv"`)
+	if err := writeHistoryCSV(w, entries); err != nil {
+		// The response has started, so the download is just cut short
+		utils.HandleError(err)
+	}
+}
+
+// writeHistoryCSV writes history entries as CSV rows with a running balance
+func writeHistoryCSV(w io.Writer, entries []HistoryEntry) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"id", "date", "category", "amount", "balance", "reason", "counterparty", "request_id", "reference", "note"}); err != nil {
+		return err
+	}
+	balance := 0
+	for _, h := range entries {
+		balance += h.Amount
+		requestID := ""
+		if h.RequestID != nil {
+			requestID = strconv.FormatInt(*h.RequestID, 10)
+		}
+		err := out.Write([]string{
+			strconv.FormatInt(h.ID, 10),
+			h.CreatedAt.Format(time.RFC3339),
+			h.Category,
+			strconv.Itoa(h.Amount),
+			strconv.Itoa(balance),
+			h.Reason,
+			csvText(h.CounterpartyName),
+			requestID,
+			csvText(h.Reference),
+			csvText(h.Note),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvText keeps user-written text from being run as a formula when the export is opened
+// in a spreadsheet, by starting cells that look like one with a quote
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// GetSwapSummary returns the session user's credit totals per calendar month, newest month first.
+// An optional "months" query parameter limits how many months are returned (default 12, max 120).
+func GetSwapSummary(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	months := 12
+	if m, err := strconv.Atoi(req.URL.Query().Get("months")); err == nil && m > 0 && m <= 120 {
+		months = m
+	}
+
+	entries, err := FullHistory(userID)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve swap history"})
+		return
+	}
+	summaries := Summarize(entries)
+	if len(summaries) > months {
+		summaries = summaries[:months]
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"months": summaries})
+}
@@ -0,0 +1,188 @@
+package swaps
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrInsufficientCredits = errors.New("insufficient swap credits")
+	ErrInvalidAmount       = errors.New("swap credit amount must be positive")
+	ErrUserNotFound        = errors.New("user not found")
+)
+
+// Transaction is a single ledger entry. A nil FromUserID means the credits were issued,
+// a nil ToUserID means they were spent. Entries with an EscrowRequestID moved credits
+// into or out of the escrow of that swap request. ActorID and Note record the admin
+// behind a manual adjustment and the reason they gave.
+type Transaction struct {
+	ID              int64     `json:"id"`
+	FromUserID      *int64    `json:"from_user_id"`
+	ToUserID        *int64    `json:"to_user_id"`
+	Amount          int       `json:"amount"`
+	Reason          string    `json:"reason"`
+	Reference       string    `json:"reference"`
+	EscrowRequestID *int64    `json:"escrow_request_id"`
+	ActorID         *int64    `json:"actor_id"`
+	Note            string    `json:"note"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// entry is a ledger entry about to be recorded
+type entry struct {
+	from, to  int64 // zero for credits issued or spent
+	amount    int
+	reason    string
+	reference string
+	escrowID  int64 // swap request whose escrow the credits enter or leave, or zero
+	actorID   int64 // admin who made a manual adjustment, or zero
+	note      string
+}
+
+// Transfer moves credits from one user to another
+func Transfer(fromID, toID int64, amount int, reason, reference string) error {
+	return inTx(func(tx *sql.Tx) error {
+		return move(tx, fromID, toID, amount, reason, reference)
+	})
+}
+
+// Grant issues new credits to a user
+func Grant(userID int64, amount int, reason, reference string) error {
+	return inTx(func(tx *sql.Tx) error {
+		return move(tx, 0, userID, amount, reason, reference)
+	})
+}
+
+// Spend takes credits out of circulation from a user's balance
+func Spend(userID int64, amount int, reason, reference string) error {
+	return inTx(func(tx *sql.Tx) error {
+		return move(tx, userID, 0, amount, reason, reference)
+	})
+}
+
+// LedgerBalance sums a user's ledger entries. It equals the cached users.swaps balance.
+func LedgerBalance(userID int64) (int, error) {
+	var balance int
+	err := database.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN to_user_id = ? THEN amount ELSE 0 END), 0) -
+		       COALESCE(SUM(CASE WHEN from_user_id = ? THEN amount ELSE 0 END), 0)
+		FROM swap_transactions
+		WHERE to_user_id = ? OR from_user_id = ?`,
+		userID, userID, userID, userID,
+	).Scan(&balance)
+	return balance, err
+}
+
+// move records a ledger entry and updates the cached balances of the users involved.
+// A zero fromID issues credits and a zero toID spends them. Balances never go negative
+// and never grow past the configured cap.
+func move(tx *sql.Tx, fromID, toID int64, amount int, reason, reference string) error {
+	return record(tx, entry{from: fromID, to: toID, amount: amount, reason: reason, reference: reference})
+}
+
+// record is move for entries that may touch an escrow
+func record(tx *sql.Tx, e entry) error {
+	fromID, toID, amount := e.from, e.to, e.amount
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if fromID == toID {
+		return fmt.Errorf("cannot move credits from a user to themselves")
+	}
+
+	// Lock both rows in ID order so concurrent opposite transfers cannot deadlock
+	ids := []int64{}
+	for _, id := range []int64{fromID, toID} {
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	balances := make(map[int64]int, len(ids))
+	for _, id := range ids {
+		var balance int
+		err := tx.QueryRow("SELECT swaps FROM users WHERE id = ? FOR UPDATE", id).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		balances[id] = balance
+	}
+	if fromID != 0 && balances[fromID] < amount {
+		return ErrInsufficientCredits
+	}
+	// Escrow payouts were checked against the cap when the swap was accepted
+	if toID != 0 && e.escrowID == 0 && exceedsCap(balances[toID], amount, config.MaxBalance()) {
+		return ErrBalanceCapReached
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO swap_transactions (from_user_id, to_user_id, amount, reason, reference, escrow_request_id, actor_id, note) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		nullID(fromID), nullID(toID), amount, e.reason, nullString(e.reference), nullID(e.escrowID), nullID(e.actorID), nullString(e.note),
+	)
+	if err != nil {
+		return err
+	}
+	if fromID != 0 {
+		if _, err := tx.Exec("UPDATE users SET swaps = swaps - ? WHERE id = ?", amount, fromID); err != nil {
+			return err
+		}
+	}
+	if toID != 0 {
+		if _, err := tx.Exec("UPDATE users SET swaps = swaps + ? WHERE id = ?", amount, toID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, retrying a few times if MySQL picks it as a deadlock victim
+func inTx(fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		err = runTx(fn)
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) || mysqlErr.Number != 1213 {
+			return err
+		}
+	}
+	return err
+}
+
+func runTx(fn func(tx *sql.Tx) error) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package swaps
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// sendSwapError maps swap service errors to HTTP responses
+func sendSwapError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRequestNotFound):
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Swap request not found"})
+	case errors.Is(err, ErrInvalidTransition):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": "Swap request cannot make that transition"})
+	case errors.Is(err, ErrInsufficientCredits):
+		utils.SendJSONResponse(w, http.StatusPaymentRequired, map[string]string{"error": "Not enough swap credits"})
+	case errors.Is(err, ErrBalanceCapReached):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": "Swap credit balance cap reached"})
+	case errors.Is(err, ErrSwapCooldown):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": "You swapped with this partner too recently"})
+	case errors.Is(err, ErrSkillNotOffered):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Teacher does not offer that skill"})
+	case errors.Is(err, ErrEscrowNotHeld):
+		utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": "Swap escrow has already been settled"})
+	case errors.Is(err, ErrInvalidSplit):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRequest):
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to process swap request"})
+	}
+}
+
+// CreateSwapRequest proposes a swap from the session user to a teacher
+func CreateSwapRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		TeacherID    int64      `json:"teacher_id"`
+		SkillID      int64      `json:"skill_id"`
+		ProposedTime *time.Time `json:"proposed_time"`
+		Message      string     `json:"message"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.TeacherID <= 0 || body.SkillID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+
+	request, err := ProposeSwap(userID, body.TeacherID, body.SkillID, body.ProposedTime, body.Message)
+	if err != nil {
+		sendSwapError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusCreated, request)
+}
+
+// GetSwapRequests lists the session user's swap requests.
+// Optional query parameters: "role" (learner or teacher) and "status".
+func GetSwapRequests(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	requests, err := ListSwapRequests(userID, req.URL.Query().Get("role"), req.URL.Query().Get("status"))
+	if err != nil {
+		sendSwapError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"requests": requests})
+}
+
+// GetSwapRequestByID returns one of the session user's swap requests by the "id" query parameter
+func GetSwapRequestByID(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+	id, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid swap request ID"})
+		return
+	}
+
+	request, err := GetSwapRequest(id)
+	if err == nil && request.LearnerID != userID && request.TeacherID != userID {
+		err = ErrRequestNotFound
+	}
+	if err != nil {
+		sendSwapError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, request)
+}
+
+// AcceptSwapRequest lets the teacher accept a swap request, holding the learner's credit in escrow
+var AcceptSwapRequest = transitionHandler(AcceptSwap)
+
+// DeclineSwapRequest lets the teacher decline a swap request
+var DeclineSwapRequest = transitionHandler(DeclineSwap)
+
+// CancelSwapRequest lets either party call off a swap request
+var CancelSwapRequest = transitionHandler(CancelSwap)
+
+// CompleteSwapRequest lets the learner confirm a swap took place, releasing the escrow to the teacher
+var CompleteSwapRequest = transitionHandler(CompleteSwap)
+
+// DisputeSwapRequest lets either party of an accepted swap hand its escrow to an admin.
+// Expects {"id": ..., "reason": "..."}.
+func DisputeSwapRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		ID     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid swap request ID"})
+		return
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "A dispute needs a reason"})
+		return
+	}
+
+	request, err := DisputeSwap(body.ID, userID, body.Reason)
+	if err != nil {
+		sendSwapError(w, err)
+		return
+	}
+	utils.SendJSONResponse(w, http.StatusOK, request)
+}
+
+// transitionHandler builds a handler that applies a transition to the request
+// given as {"id": ...} on behalf of the session user
+func transitionHandler(apply func(requestID, userID int64) (*SwapRequest, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, err := auth.GetSessionUserID(req)
+		if err != nil {
+			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+			return
+		}
+
+		var body struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.ID <= 0 {
+			utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid swap request ID"})
+			return
+		}
+
+		request, err := apply(body.ID, userID)
+		if err != nil {
+			sendSwapError(w, err)
+			return
+		}
+		utils.SendJSONResponse(w, http.StatusOK, request)
+	}
+}
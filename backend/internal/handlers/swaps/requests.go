@@ -0,0 +1,435 @@
+package swaps
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+)
+
+// Swap request states
+const (
+	StatusProposed  = "proposed"
+	StatusAccepted  = "accepted"
+	StatusDisputed  = "disputed"
+	StatusDeclined  = "declined"
+	StatusCancelled = "cancelled"
+	StatusCompleted = "completed"
+	StatusExpired   = "expired"
+)
+
+var (
+	ErrRequestNotFound   = errors.New("swap request not found")
+	ErrInvalidTransition = errors.New("swap request cannot make that transition")
+	ErrSkillNotOffered   = errors.New("teacher does not offer that skill")
+	ErrInvalidRequest    = errors.New("invalid swap request")
+)
+
+// SwapRequest is a learner's request for a teacher to teach them a skill
+type SwapRequest struct {
+	ID           int64      `json:"id"`
+	LearnerID    int64      `json:"learner_id"`
+	TeacherID    int64      `json:"teacher_id"`
+	SkillID      int64      `json:"skill_id"`
+	SkillName    string     `json:"skill_name"`
+	Status       string     `json:"status"`
+	ProposedTime *time.Time `json:"proposed_time"`
+	Message      string     `json:"message"`
+	ChatID       *int64     `json:"chat_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RespondedAt  *time.Time `json:"responded_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+}
+
+// lockedRequest is the part of a swap request needed to decide a transition
+type lockedRequest struct {
+	ID        int64
+	LearnerID int64
+	TeacherID int64
+	Status    string
+	ExpiresAt time.Time
+}
+
+// RequestReference is the ledger reference for credits moved because of a swap request
+func RequestReference(requestID int64) string {
+	return fmt.Sprintf("swap_request:%d", requestID)
+}
+
+const requestQuery = `
+	SELECT r.id, r.learner_id, r.teacher_id, r.skill_id, s.name, r.status, r.proposed_time,
+	       COALESCE(r.message, ''), r.chat_id, r.created_at, r.responded_at, r.completed_at, r.expires_at
+	FROM swap_requests r
+	JOIN skills s ON s.id = r.skill_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRequest(row rowScanner) (*SwapRequest, error) {
+	var r SwapRequest
+	var proposed, responded, completed sql.NullTime
+	var chatID sql.NullInt64
+	err := row.Scan(&r.ID, &r.LearnerID, &r.TeacherID, &r.SkillID, &r.SkillName, &r.Status, &proposed,
+		&r.Message, &chatID, &r.CreatedAt, &responded, &completed, &r.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if proposed.Valid {
+		r.ProposedTime = &proposed.Time
+	}
+	if chatID.Valid {
+		r.ChatID = &chatID.Int64
+	}
+	if responded.Valid {
+		r.RespondedAt = &responded.Time
+	}
+	if completed.Valid {
+		r.CompletedAt = &completed.Time
+	}
+	return &r, nil
+}
+
+// ProposeSwap creates a request from a learner for a teacher to teach one of the teacher's skills.
+// The learner must be able to pay for the swap, after claiming this month's allowance, and must
+// not have swapped with the teacher within the partner cooldown.
+func ProposeSwap(learnerID, teacherID, skillID int64, proposedTime *time.Time, message string) (*SwapRequest, error) {
+	if learnerID == teacherID || len(message) > 1000 {
+		return nil, ErrInvalidRequest
+	}
+	if proposedTime != nil && !proposedTime.After(time.Now()) {
+		return nil, fmt.Errorf("%w: proposed time must be in the future", ErrInvalidRequest)
+	}
+
+	var id int64
+	err := inTx(func(tx *sql.Tx) error {
+		var offered int
+		err := tx.QueryRow("SELECT COUNT(*) FROM user_skills WHERE user_id = ? AND skill_id = ?", teacherID, skillID).Scan(&offered)
+		if err != nil {
+			return err
+		}
+		if offered == 0 {
+			return ErrSkillNotOffered
+		}
+
+		if err := claimAllowance(tx, learnerID, time.Now()); err != nil {
+			return err
+		}
+		var balance int
+		if err := tx.QueryRow("SELECT swaps FROM users WHERE id = ? FOR UPDATE", learnerID).Scan(&balance); err != nil {
+			return err
+		}
+		if balance < swapPrice {
+			return ErrInsufficientCredits
+		}
+		if err := checkCooldown(tx, learnerID, teacherID); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO swap_requests (learner_id, teacher_id, skill_id, proposed_time, message, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+			learnerID, teacherID, skillID, proposedTime, nullString(message), time.Now().Add(config.SwapRequestTTL()),
+		)
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetSwapRequest(id)
+}
+
+// GetSwapRequest returns a swap request by ID
+func GetSwapRequest(id int64) (*SwapRequest, error) {
+	r, err := scanRequest(database.QueryRow(requestQuery+" WHERE r.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRequestNotFound
+	}
+	return r, err
+}
+
+// ListSwapRequests returns the user's swap requests, newest first. Role "learner" or "teacher"
+// limits the list to requests the user made or received, and status filters by state.
+func ListSwapRequests(userID int64, role, status string) ([]SwapRequest, error) {
+	query := requestQuery
+	var args []interface{}
+	switch role {
+	case "learner":
+		query += " WHERE r.learner_id = ?"
+		args = append(args, userID)
+	case "teacher":
+		query += " WHERE r.teacher_id = ?"
+		args = append(args, userID)
+	default:
+		query += " WHERE (r.learner_id = ? OR r.teacher_id = ?)"
+		args = append(args, userID, userID)
+	}
+	if status != "" {
+		query += " AND r.status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY r.created_at DESC"
+
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []SwapRequest{}
+	for rows.Next() {
+		r, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *r)
+	}
+	return requests, rows.Err()
+}
+
+// AcceptSwap lets the teacher accept a proposed request. The learner's credit is held in escrow
+// and the request is linked to the chat between the two users, which is created if needed.
+// The spending rules are checked again since they may have changed since the proposal.
+func AcceptSwap(requestID, teacherID int64) (*SwapRequest, error) {
+	return transition(requestID, func(tx *sql.Tx, r *lockedRequest) error {
+		if r.TeacherID != teacherID {
+			return ErrRequestNotFound
+		}
+		if r.Status != StatusProposed || !time.Now().Before(r.ExpiresAt) {
+			return ErrInvalidTransition
+		}
+		if err := checkCooldown(tx, r.LearnerID, r.TeacherID); err != nil {
+			return err
+		}
+		if err := checkTeacherCap(tx, r.TeacherID); err != nil {
+			return err
+		}
+		if err := claimAllowance(tx, r.LearnerID, time.Now()); err != nil {
+			return err
+		}
+		if err := holdEscrow(tx, r); err != nil {
+			return err
+		}
+		chatID, err := findOrCreateChat(tx, r.LearnerID, r.TeacherID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE swap_requests SET status = ?, chat_id = ?, responded_at = NOW() WHERE id = ?", StatusAccepted, chatID, r.ID)
+		return err
+	})
+}
+
+// DeclineSwap lets the teacher decline a proposed request
+func DeclineSwap(requestID, teacherID int64) (*SwapRequest, error) {
+	return transition(requestID, func(tx *sql.Tx, r *lockedRequest) error {
+		if r.TeacherID != teacherID {
+			return ErrRequestNotFound
+		}
+		if r.Status != StatusProposed {
+			return ErrInvalidTransition
+		}
+		_, err := tx.Exec("UPDATE swap_requests SET status = ?, responded_at = NOW() WHERE id = ?", StatusDeclined, r.ID)
+		return err
+	})
+}
+
+// CancelSwap lets the learner withdraw a proposed request, or either party call off an
+// accepted one. Cancelling an accepted request refunds the escrow to the learner.
+func CancelSwap(requestID, userID int64) (*SwapRequest, error) {
+	return transition(requestID, func(tx *sql.Tx, r *lockedRequest) error {
+		if r.LearnerID != userID && r.TeacherID != userID {
+			return ErrRequestNotFound
+		}
+		switch {
+		case r.Status == StatusProposed && r.LearnerID == userID:
+		case r.Status == StatusAccepted:
+			if err := refundEscrow(tx, r.ID); err != nil {
+				return err
+			}
+		default:
+			return ErrInvalidTransition
+		}
+		_, err := tx.Exec("UPDATE swap_requests SET status = ?, cancelled_by = ? WHERE id = ?", StatusCancelled, userID, r.ID)
+		return err
+	})
+}
+
+// CompleteSwap lets the learner confirm an accepted swap took place, releasing the escrow to the teacher
+func CompleteSwap(requestID, learnerID int64) (*SwapRequest, error) {
+	return transition(requestID, func(tx *sql.Tx, r *lockedRequest) error {
+		if r.LearnerID != learnerID {
+			return ErrRequestNotFound
+		}
+		return complete(tx, r)
+	})
+}
+
+// CompleteChatSwap completes the oldest accepted request linked to a chat once a call in that
+// chat lasted long enough. It returns false if the chat had no accepted request.
+func CompleteChatSwap(chatID int64) (bool, error) {
+	var completed bool
+	err := inTx(func(tx *sql.Tx) error {
+		completed = false
+		var id int64
+		err := tx.QueryRow(`
+			SELECT id FROM swap_requests
+			WHERE chat_id = ? AND status = ?
+			ORDER BY COALESCE(proposed_time, created_at) ASC
+			LIMIT 1`, chatID, StatusAccepted).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r, err := lockRequest(tx, id)
+		if err != nil {
+			return err
+		}
+		// The request may have been cancelled or disputed since it was selected
+		if r.Status != StatusAccepted {
+			return nil
+		}
+		if err := complete(tx, r); err != nil {
+			return err
+		}
+		completed = true
+		return nil
+	})
+	return completed && err == nil, err
+}
+
+// complete marks an accepted request completed and releases its escrow to the teacher
+func complete(tx *sql.Tx, r *lockedRequest) error {
+	if r.Status != StatusAccepted {
+		return ErrInvalidTransition
+	}
+	if err := releaseEscrow(tx, r.ID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE swap_requests SET status = ?, completed_at = NOW() WHERE id = ?", StatusCompleted, r.ID)
+	return err
+}
+
+// ExpireStaleRequests expires proposed requests the teacher did not answer in time, and
+// accepted swaps that were not completed within the completion window, refunding their escrow.
+func ExpireStaleRequests() (int64, error) {
+	result, err := database.Execute(
+		"UPDATE swap_requests SET status = ? WHERE status = ? AND expires_at <= NOW()",
+		StatusExpired, StatusProposed,
+	)
+	if err != nil {
+		return 0, err
+	}
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(-config.SwapCompletionWindow())
+	rows, err := database.Query(
+		"SELECT id FROM swap_requests WHERE status = ? AND COALESCE(proposed_time, responded_at) <= ?",
+		StatusAccepted, deadline,
+	)
+	if err != nil {
+		return expired, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return expired, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		_, err := transition(id, func(tx *sql.Tx, r *lockedRequest) error {
+			if r.Status != StatusAccepted {
+				return ErrInvalidTransition
+			}
+			if err := refundEscrow(tx, r.ID); err != nil {
+				return err
+			}
+			_, err := tx.Exec("UPDATE swap_requests SET status = ? WHERE id = ?", StatusExpired, r.ID)
+			return err
+		})
+		if errors.Is(err, ErrInvalidTransition) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// RunExpiry periodically expires stale swap requests. It never returns.
+func RunExpiry() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		if n, err := ExpireStaleRequests(); err != nil {
+			log.Println("Swaps: Failed to expire requests:", err)
+		} else if n > 0 {
+			log.Printf("Swaps: Expired %d requests", n)
+		}
+	}
+}
+
+// transition locks a request, applies fn and returns the updated request
+func transition(requestID int64, fn func(tx *sql.Tx, r *lockedRequest) error) (*SwapRequest, error) {
+	err := inTx(func(tx *sql.Tx) error {
+		r, err := lockRequest(tx, requestID)
+		if err != nil {
+			return err
+		}
+		return fn(tx, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetSwapRequest(requestID)
+}
+
+// lockRequest reads a request and locks it until the transaction ends
+func lockRequest(tx *sql.Tx, id int64) (*lockedRequest, error) {
+	var r lockedRequest
+	err := tx.QueryRow(
+		"SELECT id, learner_id, teacher_id, status, expires_at FROM swap_requests WHERE id = ? FOR UPDATE", id,
+	).Scan(&r.ID, &r.LearnerID, &r.TeacherID, &r.Status, &r.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRequestNotFound
+	}
+	return &r, err
+}
+
+// findOrCreateChat returns the chat between two users, creating it with the learner first
+func findOrCreateChat(tx *sql.Tx, learnerID, teacherID int64) (int64, error) {
+	var chatID int64
+	err := tx.QueryRow(
+		"SELECT id FROM chats WHERE (user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?) LIMIT 1",
+		learnerID, teacherID, teacherID, learnerID,
+	).Scan(&chatID)
+	if err == nil {
+		return chatID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	result, err := tx.Exec("INSERT INTO chats (user1_id, user2_id) VALUES (?, ?)", learnerID, teacherID)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
@@ -0,0 +1,121 @@
+package swaps
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/models"
+)
+
+var (
+	ErrBalanceCapReached = errors.New("swap credit balance cap reached")
+	ErrSwapCooldown      = errors.New("swapped with this partner too recently")
+)
+
+// AllowanceReference is the ledger reference of a month's allowance, e.g. "allowance:2026-10"
+func AllowanceReference(month time.Time) string {
+	return "allowance:" + month.UTC().Format("2006-01")
+}
+
+// exceedsCap reports whether receiving amount more credits would take a balance past the cap
+func exceedsCap(balance, amount, maxBalance int) bool {
+	return maxBalance > 0 && balance+amount > maxBalance
+}
+
+// ClaimMonthlyAllowance grants the user this month's free credits unless they already
+// received them. The grant is cut down so it never takes the balance past the cap.
+func ClaimMonthlyAllowance(userID int64) error {
+	return inTx(func(tx *sql.Tx) error {
+		return claimAllowance(tx, userID, time.Now())
+	})
+}
+
+func claimAllowance(tx *sql.Tx, userID int64, now time.Time) error {
+	allowance := config.MonthlyAllowance()
+	if allowance == 0 {
+		return nil
+	}
+
+	var balance int
+	err := tx.QueryRow("SELECT swaps FROM users WHERE id = ? FOR UPDATE", userID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	reference := AllowanceReference(now)
+	var claimed int
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM swap_transactions WHERE to_user_id = ? AND reason = ? AND reference = ?",
+		userID, models.ReasonMonthlyAllowance, reference,
+	).Scan(&claimed)
+	if err != nil || claimed > 0 {
+		return err
+	}
+
+	amount := allowance
+	if maxBalance := config.MaxBalance(); maxBalance > 0 && balance+amount > maxBalance {
+		amount = maxBalance - balance
+	}
+	if amount <= 0 {
+		return nil
+	}
+	return record(tx, entry{to: userID, amount: amount, reason: models.ReasonMonthlyAllowance, reference: reference})
+}
+
+// checkTeacherCap refuses a swap whose payment would take the teacher past the balance cap,
+// counting credits already held in escrow for them
+func checkTeacherCap(tx *sql.Tx, teacherID int64) error {
+	maxBalance := config.MaxBalance()
+	if maxBalance == 0 {
+		return nil
+	}
+	var balance, pending int
+	err := tx.QueryRow("SELECT swaps FROM users WHERE id = ? FOR UPDATE", teacherID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(
+		"SELECT COALESCE(SUM(amount), 0) FROM swap_escrows WHERE teacher_id = ? AND status = ?",
+		teacherID, EscrowHeld,
+	).Scan(&pending)
+	if err != nil {
+		return err
+	}
+	if exceedsCap(balance+pending, swapPrice, maxBalance) {
+		return ErrBalanceCapReached
+	}
+	return nil
+}
+
+// checkCooldown refuses a swap between two users who had one accepted within the cooldown
+func checkCooldown(tx *sql.Tx, learnerID, teacherID int64) error {
+	cooldown := config.PartnerCooldown()
+	if cooldown == 0 {
+		return nil
+	}
+	var recent int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM swap_requests
+		WHERE ((learner_id = ? AND teacher_id = ?) OR (learner_id = ? AND teacher_id = ?))
+		  AND status IN (?, ?, ?)
+		  AND responded_at > ?`,
+		learnerID, teacherID, teacherID, learnerID,
+		StatusAccepted, StatusDisputed, StatusCompleted,
+		time.Now().Add(-cooldown),
+	).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return ErrSwapCooldown
+	}
+	return nil
+}
@@ -0,0 +1,547 @@
+package swaps
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/models"
+)
+
+// TestMain sets up the test environment for swap tests
+func TestMain(m *testing.M) {
+	// Setup test database
+	if err := database.SetupTestDB(); err != nil {
+		fmt.Printf("Failed to setup test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	// Cleanup
+	database.ClearTestData()
+	database.TeardownTestDB()
+	os.Exit(code)
+}
+
+// totalSupply sums the cached balances of the given users
+func totalSupply(t *testing.T, ids []int64) int {
+	t.Helper()
+	total := 0
+	for _, id := range ids {
+		total += balance(t, id)
+	}
+	return total
+}
+
+func TestConcurrentEscrowPaymentsKeepSupply(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 4)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	before := totalSupply(t, ids)
+
+	// Every user pays every other user through escrow repeatedly, in both directions at once,
+	// the way accepting and completing a swap request moves credits
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := map[int64]int{}
+	var requestID int64
+	for round := 0; round < 5; round++ {
+		for _, from := range ids {
+			for _, to := range ids {
+				if from == to {
+					continue
+				}
+				wg.Add(1)
+				requestID++
+				go func(from, to, requestID int64) {
+					defer wg.Done()
+					reference := RequestReference(requestID)
+					err := inTx(func(tx *sql.Tx) error {
+						hold := entry{from: from, amount: 1, reason: models.ReasonEscrowHold, reference: reference, escrowID: requestID}
+						if err := record(tx, hold); err != nil {
+							return err
+						}
+						release := entry{to: to, amount: 1, reason: models.ReasonEscrowRelease, reference: reference, escrowID: requestID}
+						return record(tx, release)
+					})
+					if err != nil && !errors.Is(err, ErrInsufficientCredits) {
+						t.Errorf("Escrow payment from %d to %d failed: %v", from, to, err)
+						return
+					}
+					if err == nil {
+						mu.Lock()
+						succeeded[from]--
+						succeeded[to]++
+						mu.Unlock()
+					}
+				}(from, to, requestID)
+			}
+		}
+	}
+	wg.Wait()
+
+	if after := totalSupply(t, ids); after != before {
+		t.Errorf("Total supply changed from %d to %d", before, after)
+	}
+	for _, id := range ids {
+		cached := balance(t, id)
+		if cached < 0 {
+			t.Errorf("User %d has a negative balance %d", id, cached)
+		}
+		if cached != 2+succeeded[id] {
+			t.Errorf("User %d has %d credits, expected %d", id, cached, 2+succeeded[id])
+		}
+		ledger, err := LedgerBalance(id)
+		if err != nil {
+			t.Fatalf("LedgerBalance failed: %v", err)
+		}
+		if ledger != cached {
+			t.Errorf("User %d ledger balance %d does not match cached balance %d", id, ledger, cached)
+		}
+	}
+}
+
+func TestTransferRejectsOverdraft(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 2)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+
+	if err := Transfer(ids[0], ids[1], 3, models.ReasonSwap, ""); !errors.Is(err, ErrInsufficientCredits) {
+		t.Errorf("Expected ErrInsufficientCredits, got %v", err)
+	}
+	if err := Transfer(ids[0], ids[1], 0, models.ReasonSwap, ""); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount, got %v", err)
+	}
+	if err := Spend(ids[0], 2, models.ReasonSwapPayment, RequestReference(1)); err != nil {
+		t.Fatalf("Spend failed: %v", err)
+	}
+	if got := balance(t, ids[0]); got != 0 {
+		t.Errorf("Expected 0 credits after spending, got %d", got)
+	}
+	if err := Grant(-1, 1, models.ReasonAdjustment, ""); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+// insertTeachingSkill creates a test skill offered by the teacher
+func insertTeachingSkill(t *testing.T, teacherID int64) int64 {
+	t.Helper()
+	skillID, err := database.InsertTestSkill(fmt.Sprintf("testskill%d", teacherID), "test skill")
+	if err != nil {
+		t.Fatalf("Failed to insert test skill: %v", err)
+	}
+	if _, err := database.Execute("INSERT INTO user_skills (user_id, skill_id) VALUES (?, ?)", teacherID, skillID); err != nil {
+		t.Fatalf("Failed to insert user skill: %v", err)
+	}
+	return skillID
+}
+
+// balance returns the user's cached balance
+func balance(t *testing.T, id int64) int {
+	t.Helper()
+	swaps, err := GetSwaps(models.UserInfo{ID: int(id)})
+	if err != nil {
+		t.Fatalf("GetSwaps failed: %v", err)
+	}
+	return swaps
+}
+
+func TestSwapRequestWorkflow(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 2)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher := ids[0], ids[1]
+	skillID := insertTeachingSkill(t, teacher)
+
+	if _, err := ProposeSwap(learner, learner, skillID, nil, ""); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest for a request to oneself, got %v", err)
+	}
+	if _, err := ProposeSwap(teacher, learner, skillID, nil, ""); !errors.Is(err, ErrSkillNotOffered) {
+		t.Errorf("Expected ErrSkillNotOffered, got %v", err)
+	}
+
+	request, err := ProposeSwap(learner, teacher, skillID, nil, "Teach me")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if request.Status != StatusProposed || balance(t, learner) != 2 {
+		t.Errorf("Expected a free proposed request, got status %s and balance %d", request.Status, balance(t, learner))
+	}
+	if _, err := AcceptSwap(request.ID, learner); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("Expected the learner not to be able to accept, got %v", err)
+	}
+
+	request, err = AcceptSwap(request.ID, teacher)
+	if err != nil {
+		t.Fatalf("AcceptSwap failed: %v", err)
+	}
+	if request.Status != StatusAccepted || request.ChatID == nil {
+		t.Errorf("Expected an accepted request linked to a chat, got %+v", request)
+	}
+	if balance(t, learner) != 1 {
+		t.Errorf("Expected the learner to be charged on accept, balance %d", balance(t, learner))
+	}
+	if _, err := DeclineSwap(request.ID, teacher); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected an accepted request not to be declinable, got %v", err)
+	}
+
+	completed, err := CompleteChatSwap(*request.ChatID)
+	if err != nil || !completed {
+		t.Fatalf("CompleteChatSwap = %v, %v", completed, err)
+	}
+	if balance(t, teacher) != 3 {
+		t.Errorf("Expected the teacher to be paid on completion, balance %d", balance(t, teacher))
+	}
+	if completed, _ := CompleteChatSwap(*request.ChatID); completed {
+		t.Error("Expected a completed request not to be completed twice")
+	}
+}
+
+func TestCancelAcceptedSwapRefundsLearner(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 2)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher := ids[0], ids[1]
+	skillID := insertTeachingSkill(t, teacher)
+
+	request, err := ProposeSwap(learner, teacher, skillID, nil, "")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if _, err := AcceptSwap(request.ID, teacher); err != nil {
+		t.Fatalf("AcceptSwap failed: %v", err)
+	}
+	request, err = CancelSwap(request.ID, teacher)
+	if err != nil {
+		t.Fatalf("CancelSwap failed: %v", err)
+	}
+	if request.Status != StatusCancelled || balance(t, learner) != 2 || balance(t, teacher) != 2 {
+		t.Errorf("Expected a refunded cancellation, got status %s and balances %d/%d", request.Status, balance(t, learner), balance(t, teacher))
+	}
+
+	declined, err := ProposeSwap(learner, teacher, skillID, nil, "")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if _, err := DeclineSwap(declined.ID, teacher); err != nil {
+		t.Fatalf("DeclineSwap failed: %v", err)
+	}
+	if _, err := CancelSwap(declined.ID, learner); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected a declined request not to be cancellable, got %v", err)
+	}
+}
+
+func TestReleaseWithoutTeacherRefundsLearner(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 2)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher := ids[0], ids[1]
+	skillID := insertTeachingSkill(t, teacher)
+
+	request, err := ProposeSwap(learner, teacher, skillID, nil, "")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if _, err := AcceptSwap(request.ID, teacher); err != nil {
+		t.Fatalf("AcceptSwap failed: %v", err)
+	}
+	// The teacher's account is gone by the time the escrow is paid out
+	if _, err := database.Execute("UPDATE swap_escrows SET teacher_id = NULL WHERE request_id = ?", request.ID); err != nil {
+		t.Fatalf("Failed to clear the escrow's teacher: %v", err)
+	}
+	if err := inTx(func(tx *sql.Tx) error { return releaseEscrow(tx, request.ID) }); err != nil {
+		t.Fatalf("releaseEscrow failed: %v", err)
+	}
+
+	if balance(t, learner) != 2 || balance(t, teacher) != 2 {
+		t.Errorf("Expected the learner refunded, got balances %d/%d", balance(t, learner), balance(t, teacher))
+	}
+	if ledger, err := LedgerBalance(learner); err != nil || ledger != balance(t, learner) {
+		t.Errorf("Expected the ledger to match the learner's balance, got %d (%v)", ledger, err)
+	}
+	var status string
+	if err := database.QueryRow("SELECT status FROM swap_escrows WHERE request_id = ?", request.ID).Scan(&status); err != nil {
+		t.Fatalf("Failed to read escrow: %v", err)
+	}
+	if status != EscrowRefunded {
+		t.Errorf("Expected the escrow to be refunded, got %s", status)
+	}
+}
+
+func TestDisputedSwapIsSettledByAdmin(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 3)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher, admin := ids[0], ids[1], ids[2]
+	skillID := insertTeachingSkill(t, teacher)
+
+	request, err := ProposeSwap(learner, teacher, skillID, nil, "")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if _, err := AcceptSwap(request.ID, teacher); err != nil {
+		t.Fatalf("AcceptSwap failed: %v", err)
+	}
+	if balance(t, learner) != 1 || balance(t, teacher) != 2 {
+		t.Fatalf("Expected the learner's credit in escrow, got balances %d/%d", balance(t, learner), balance(t, teacher))
+	}
+
+	request, err = DisputeSwap(request.ID, learner, "Teacher never showed up")
+	if err != nil {
+		t.Fatalf("DisputeSwap failed: %v", err)
+	}
+	if request.Status != StatusDisputed {
+		t.Errorf("Expected status %s, got %s", StatusDisputed, request.Status)
+	}
+	if _, err := CompleteSwap(request.ID, learner); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected a disputed swap not to be completable, got %v", err)
+	}
+	if _, err := ResolveDispute(request.ID, admin, 2, "Too much"); !errors.Is(err, ErrInvalidSplit) {
+		t.Errorf("Expected ErrInvalidSplit, got %v", err)
+	}
+
+	request, err = ResolveDispute(request.ID, admin, 0, "No lesson took place")
+	if err != nil {
+		t.Fatalf("ResolveDispute failed: %v", err)
+	}
+	if request.Status != StatusCancelled || balance(t, learner) != 2 || balance(t, teacher) != 2 {
+		t.Errorf("Expected the swap reversed, got status %s and balances %d/%d", request.Status, balance(t, learner), balance(t, teacher))
+	}
+	if _, err := ResolveDispute(request.ID, admin, 1, "Again"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected a settled dispute not to be resolvable twice, got %v", err)
+	}
+
+	for _, id := range []int64{learner, teacher} {
+		ledger, err := LedgerBalance(id)
+		if err != nil {
+			t.Fatalf("LedgerBalance failed: %v", err)
+		}
+		if ledger != balance(t, id) {
+			t.Errorf("User %d: ledger balance %d does not match cached balance %d", id, ledger, balance(t, id))
+		}
+	}
+}
+
+func TestSpendingRules(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 3)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher, broke := ids[0], ids[1], ids[2]
+	skillID := insertTeachingSkill(t, teacher)
+
+	// Nobody can spend past zero
+	if err := Spend(broke, 3, models.ReasonAdjustment, ""); !errors.Is(err, ErrInsufficientCredits) {
+		t.Errorf("Expected ErrInsufficientCredits, got %v", err)
+	}
+	if err := Spend(broke, 2, models.ReasonAdjustment, ""); err != nil {
+		t.Fatalf("Spend failed: %v", err)
+	}
+	if _, err := ProposeSwap(broke, teacher, skillID, nil, ""); !errors.Is(err, ErrInsufficientCredits) {
+		t.Errorf("Expected a learner without credits to be refused, got %v", err)
+	}
+
+	// The cap applies to grants and transfers
+	t.Setenv("SWAP_MAX_BALANCE", "3")
+	if err := Grant(learner, 2, models.ReasonAdjustment, ""); !errors.Is(err, ErrBalanceCapReached) {
+		t.Errorf("Expected ErrBalanceCapReached, got %v", err)
+	}
+	if err := Grant(learner, 1, models.ReasonAdjustment, ""); err != nil {
+		t.Fatalf("Grant up to the cap failed: %v", err)
+	}
+
+	// The allowance is granted once a month and cut down to the cap
+	t.Setenv("SWAP_MAX_BALANCE", "4")
+	t.Setenv("SWAP_MONTHLY_ALLOWANCE", "3")
+	for i := 0; i < 2; i++ {
+		if err := ClaimMonthlyAllowance(learner); err != nil {
+			t.Fatalf("ClaimMonthlyAllowance failed: %v", err)
+		}
+	}
+	if balance(t, learner) != 4 {
+		t.Errorf("Expected the allowance to fill the balance up to the cap, got %d", balance(t, learner))
+	}
+	t.Setenv("SWAP_MONTHLY_ALLOWANCE", "0")
+
+	// A teacher at the cap cannot accept more paid swaps
+	if err := Grant(teacher, 2, models.ReasonAdjustment, ""); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	request, err := ProposeSwap(learner, teacher, skillID, nil, "")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if _, err := AcceptSwap(request.ID, teacher); !errors.Is(err, ErrBalanceCapReached) {
+		t.Errorf("Expected ErrBalanceCapReached, got %v", err)
+	}
+	t.Setenv("SWAP_MAX_BALANCE", "0")
+
+	// Partners must wait out the cooldown between swaps, in either direction
+	t.Setenv("SWAP_PARTNER_COOLDOWN_HOURS", "24")
+	if _, err := AcceptSwap(request.ID, teacher); err != nil {
+		t.Fatalf("AcceptSwap failed: %v", err)
+	}
+	if _, err := ProposeSwap(learner, teacher, skillID, nil, ""); !errors.Is(err, ErrSwapCooldown) {
+		t.Errorf("Expected ErrSwapCooldown, got %v", err)
+	}
+	learnerSkill := insertTeachingSkill(t, learner)
+	if _, err := ProposeSwap(teacher, learner, learnerSkill, nil, ""); !errors.Is(err, ErrSwapCooldown) {
+		t.Errorf("Expected ErrSwapCooldown for the reverse direction, got %v", err)
+	}
+}
+
+func TestHistoryShowsCategoriesAndCounterparties(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 2)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	learner, teacher := ids[0], ids[1]
+	skillID := insertTeachingSkill(t, teacher)
+
+	if err := Grant(learner, 1, models.ReasonAdjustment, ""); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	request, err := ProposeSwap(learner, teacher, skillID, nil, "")
+	if err != nil {
+		t.Fatalf("ProposeSwap failed: %v", err)
+	}
+	if _, err := AcceptSwap(request.ID, teacher); err != nil {
+		t.Fatalf("AcceptSwap failed: %v", err)
+	}
+	if _, err := CancelSwap(request.ID, learner); err != nil {
+		t.Fatalf("CancelSwap failed: %v", err)
+	}
+
+	entries, total, err := History(learner, 2, 0)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	// signup bonus, adjustment, escrow hold, escrow refund
+	if total != 4 || len(entries) != 2 {
+		t.Fatalf("Expected a page of 2 out of 4 entries, got %d of %d", len(entries), total)
+	}
+	refund := entries[0]
+	if refund.Category != CategoryRefund || refund.Amount != 1 {
+		t.Errorf("Expected the newest entry to be a refund of 1, got %s %d", refund.Category, refund.Amount)
+	}
+	if refund.CounterpartyID == nil || *refund.CounterpartyID != teacher || refund.RequestID == nil || *refund.RequestID != request.ID {
+		t.Errorf("Expected the refund to name the teacher and the request, got %+v", refund)
+	}
+	if entries[1].Category != CategorySpent || entries[1].Amount != -1 {
+		t.Errorf("Expected the escrow hold to be spent, got %s %d", entries[1].Category, entries[1].Amount)
+	}
+
+	full, err := FullHistory(learner)
+	if err != nil {
+		t.Fatalf("FullHistory failed: %v", err)
+	}
+	summaries := Summarize(full)
+	if len(summaries) == 0 {
+		t.Fatal("Expected a monthly summary")
+	}
+	latest := summaries[0]
+	if latest.ClosingBalance != balance(t, learner) {
+		t.Errorf("Expected the closing balance %d, got %d", balance(t, learner), latest.ClosingBalance)
+	}
+}
+
+// failingWriter fails every write, like a client that went away mid-download
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestHistoryCSVEscapesFormulas(t *testing.T) {
+	entries := []HistoryEntry{
+		{ID: 1, Category: CategoryAdjustment, Amount: -1, Reason: models.ReasonAdjustment, CounterpartyName: "=HYPERLINK(\"http://evil\")", Note: "+1 bonus", Reference: "@sum"},
+		{ID: 2, Category: CategoryEarned, Amount: 2, Reason: models.ReasonEscrowRelease, CounterpartyName: "alice", Note: "-", Reference: "swap_request:7"},
+	}
+	var buf bytes.Buffer
+	if err := writeHistoryCSV(&buf, entries); err != nil {
+		t.Fatalf("writeHistoryCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read the CSV back: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d rows", len(rows))
+	}
+	first, second := rows[1], rows[2]
+	if first[6] != "'=HYPERLINK(\"http://evil\")" || first[8] != "'@sum" || first[9] != "'+1 bonus" {
+		t.Errorf("Expected formula-like text to be quoted, got %q", first)
+	}
+	if first[3] != "-1" || first[4] != "-1" {
+		t.Errorf("Expected numbers to be left alone, got amount %s and balance %s", first[3], first[4])
+	}
+	if second[6] != "alice" || second[8] != "swap_request:7" || second[9] != "'-" || second[4] != "1" {
+		t.Errorf("Unexpected second row %q", second)
+	}
+
+	if err := writeHistoryCSV(failingWriter{}, entries); err == nil {
+		t.Error("Expected a write error to be returned")
+	}
+}
+
+func TestAdminAdjustmentsAreAudited(t *testing.T) {
+	database.ClearTestData()
+	ids, err := database.InsertTestUsers("testswapper", 3)
+	if err != nil {
+		t.Fatalf("Failed to insert test users: %v", err)
+	}
+	admin, teacher, other := ids[0], ids[1], ids[2]
+	skillID := insertTeachingSkill(t, teacher)
+
+	if err := Adjust(other, 1, admin, "  "); !errors.Is(err, ErrReasonRequired) {
+		t.Errorf("Expected ErrReasonRequired, got %v", err)
+	}
+	if err := Adjust(other, -3, admin, "Chargeback"); !errors.Is(err, ErrInsufficientCredits) {
+		t.Errorf("Expected an adjustment below zero to be refused, got %v", err)
+	}
+	if err := Adjust(other, -2, admin, "Chargeback"); err != nil {
+		t.Fatalf("Adjust failed: %v", err)
+	}
+
+	var actorID int64
+	var note string
+	err = database.QueryRow(
+		"SELECT actor_id, note FROM swap_transactions WHERE from_user_id = ? AND reason = ?", other, models.ReasonAdjustment,
+	).Scan(&actorID, &note)
+	if err != nil {
+		t.Fatalf("Failed to read the adjustment: %v", err)
+	}
+	if actorID != admin || note != "Chargeback" {
+		t.Errorf("Expected the adjustment to record admin %d and its reason, got %d %q", admin, actorID, note)
+	}
+
+	result, err := GrantToSkill(skillID, 3, admin, "Promotion")
+	if err != nil {
+		t.Fatalf("GrantToSkill failed: %v", err)
+	}
+	if result.Granted != 1 || balance(t, teacher) != 5 || balance(t, other) != 0 {
+		t.Errorf("Expected only the skill's teacher to be granted, got %d grants and balances %d/%d",
+			result.Granted, balance(t, teacher), balance(t, other))
+	}
+}
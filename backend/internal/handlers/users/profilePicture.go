@@ -26,21 +26,11 @@ func UploadProfilePicture(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Get user ID from session, not from form (prevents impersonation)
-	session, err := auth.Store.Get(req, "authentication")
+	userID, err := auth.GetSessionUserID(req)
 	if err != nil {
 		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
 		return
 	}
-	email, ok := session.Values["email"].(string)
-	if !ok || email == "" {
-		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
-		return
-	}
-	userID, err := database.GetUserIDFromEmail(email)
-	if err != nil {
-		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "User not found"})
-		return
-	}
 	userIDStr := fmt.Sprintf("%d", userID)
 	if err := os.MkdirAll(filepath.Join("uploads", "users"), 0o755); err != nil {
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create upload directory"})
@@ -11,7 +11,7 @@ import (
 
 // RetrieveUserInfo handles an HTTP request to fetch a user's complete profile by id (query parameter "q")
 // and writes the user object as JSON to the response.
-// It queries the database for user fields and JSON-encoded arrays for skills, projects, and contacts,
+// It queries the database for user fields, the user's reputation and JSON-encoded arrays for skills, projects, and contacts,
 // unmarshals those arrays into the corresponding struct fields, and returns the assembled user.
 // Responds with HTTP 200 and the user on success, HTTP 404 if the user is not found, and HTTP 500 on database
 // or JSON unmarshalling errors.
@@ -27,11 +27,17 @@ SELECT
   COALESCE(u.location, "") as location,
   COALESCE(u.profession, "") as profession,
   (
-    SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT("name", s.name, "verified", us.verified)), JSON_ARRAY())
+    SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT("name", s.name, "verified", us.verified, "teaching_style", us.teaching_skill, "proficiency", us.proficiency, "years_experience", us.years_experience)), JSON_ARRAY())
     FROM user_skills us
     JOIN skills s ON us.skill_id = s.id
     WHERE us.user_id = u.id
   ) AS skills,
+  (
+    SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT("name", s.name)), JSON_ARRAY())
+    FROM user_wanted_skills uw
+    JOIN skills s ON uw.skill_id = s.id
+    WHERE uw.user_id = u.id
+  ) AS wanted_skills,
   (
     SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT("name", up.name, "description", COALESCE(up.description, ""), "link", COALESCE(up.link, ""))), JSON_ARRAY())
     FROM user_projects up
@@ -41,7 +47,7 @@ SELECT
     SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT("name", uc.name, "link", COALESCE(uc.link, ""), "icon", uc.icon)), JSON_ARRAY())
     FROM user_contacts uc
     WHERE uc.user_id = u.id
-  ) AS contacts
+  ) AS contacts,`+database.ReputationColumns+`
 FROM users AS u
 WHERE u.id = ?;
 `, req.URL.Query().Get("q"))
@@ -53,6 +59,7 @@ WHERE u.id = ?;
 
 	var user models.UserInfo
 	var skillsJSON []byte
+	var wantedSkillsJSON []byte
 	var projectsJSON []byte
 	var contactsJSON []byte
 	if !rows.Next() {
@@ -60,7 +67,7 @@ WHERE u.id = ?;
 		return
 	}
 
-	err = rows.Scan(&user.ID, &user.Username, &user.Email, &user.ProfilePicture, &user.AboutMe, &user.Location, &user.Professions, &skillsJSON, &projectsJSON, &contactsJSON)
+	err = rows.Scan(&user.ID, &user.Username, &user.Email, &user.ProfilePicture, &user.AboutMe, &user.Location, &user.Professions, &skillsJSON, &wantedSkillsJSON, &projectsJSON, &contactsJSON, &user.Reputation.Rating, &user.Reputation.Reviews)
 	if err != nil {
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to parse user data"})
@@ -74,6 +81,12 @@ WHERE u.id = ?;
 		return
 	}
 
+	if err := json.Unmarshal(wantedSkillsJSON, &user.WantedSkills); err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to parse wanted skills data"})
+		return
+	}
+
 	if err := json.Unmarshal(projectsJSON, &user.Projects); err != nil {
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to parse projects data"})
@@ -1,15 +1,18 @@
 package users
 
 import (
+	"errors"
 	"net/http"
 
+	"skillswap/backend/internal/credentials"
+	"skillswap/backend/internal/handlers/auth"
 	"skillswap/backend/internal/utils"
 )
 
 // UpdateUser handles updating user profile information
 func UpdateUser(w http.ResponseWriter, req *http.Request) {
 	// Get and validate user session
-	sessionUserID, err := getUserFromSession(req)
+	sessionUserID, err := auth.GetSessionUserID(req)
 	if err != nil {
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
@@ -30,8 +33,47 @@ func UpdateUser(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Perform the update
-	if err := performUserUpdate(payload); err != nil {
+	// Reject invalid skill details before the old profile data is cleared
+	if err := validateUserSkills(payload); err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Refuse an email another account uses before the old profile data is cleared
+	if payload.Email != "" {
+		if err := auth.CheckEmailAvailable(sessionUserID, payload.Email); err != nil {
+			if errors.Is(err, auth.ErrEmailTaken) {
+				utils.SendJSONResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			utils.HandleError(err)
+			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	// Check a password change before the old profile data is cleared
+	if payload.NewPassword != "" {
+		err := auth.VerifyUserPassword(sessionUserID, payload.Password)
+		if err == nil {
+			err = auth.CheckNewPassword(sessionUserID, payload.NewPassword)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrWrongPassword):
+				utils.SendJSONResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			case credentials.IsPolicyError(err):
+				utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			default:
+				utils.HandleError(err)
+				utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+	}
+
+	// Perform the update; a new password logs out every other session
+	if err := performUserUpdate(payload, auth.SessionToken(req)); err != nil {
 		utils.HandleError(err)
 		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -1,11 +1,10 @@
 package users
 
 import (
-	"crypto/md5"
-	"errors"
 	"fmt"
 
 	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/handlers/auth"
 	"skillswap/backend/internal/models"
 	"skillswap/backend/internal/utils"
 )
@@ -15,6 +14,7 @@ func clearExistingUserData(userID int) error {
 	queries := []string{
 		"DELETE FROM user_projects WHERE user_id = ?",
 		"DELETE FROM user_skills WHERE user_id = ?",
+		"DELETE FROM user_wanted_skills WHERE user_id = ?",
 		"DELETE FROM user_contacts WHERE user_id = ?",
 	}
 
@@ -81,12 +81,18 @@ func addSkill(user models.UserInfo, skill models.UserSkill) error {
 		return fmt.Errorf("failed to get skill ID: %w", err)
 	}
 
-	database.Debug("INSERT INTO user_skills (user_id, skill_id, verified) VALUES (%v, %v, %v)",
-		user.ID, skillID, skill.Verified)
+	if err := skill.Normalize(); err != nil {
+		return err
+	}
 
-	_, err = database.Execute(
-		"INSERT INTO user_skills (user_id, skill_id, verified) VALUES (?, ?, ?)",
-		user.ID, skillID, bool(skill.Verified),
+	database.Debug("INSERT INTO user_skills (user_id, skill_id, teaching_skill, proficiency, years_experience) VALUES (%v, %v, %v, %v, %v)",
+		user.ID, skillID, skill.TeachingStyle, skill.Proficiency, skill.YearsExperience)
+
+	// The verified flag is never taken from the client; it follows the skill's verifications
+	_, err = database.Execute(`
+		INSERT INTO user_skills (user_id, skill_id, verified, teaching_skill, proficiency, years_experience)
+		VALUES (?, ?, EXISTS(SELECT 1 FROM skill_verifications WHERE user_id = ? AND skill_id = ?), ?, ?, ?)`,
+		user.ID, skillID, user.ID, skillID, skill.TeachingStyle, skill.Proficiency, skill.YearsExperience,
 	)
 
 	if err != nil {
@@ -97,6 +103,31 @@ func addSkill(user models.UserInfo, skill models.UserSkill) error {
 	return nil
 }
 
+// addWantedSkill adds a skill the user wants to learn to their profile
+func addWantedSkill(user models.UserInfo, skill models.WantedSkill) error {
+	if skill.Name == "" {
+		return nil // Skip invalid skills
+	}
+
+	skillID, err := database.GetSkillIDFromName(skill.Name)
+	if err != nil {
+		utils.HandleError(err)
+		return fmt.Errorf("failed to get skill ID: %w", err)
+	}
+
+	_, err = database.Execute(
+		"INSERT INTO user_wanted_skills (user_id, skill_id) VALUES (?, ?)",
+		user.ID, skillID,
+	)
+
+	if err != nil {
+		utils.HandleError(err)
+		return fmt.Errorf("failed to add wanted skill: %w", err)
+	}
+
+	return nil
+}
+
 // addContacts adds a contact to the user's profile
 func addContacts(user models.UserInfo, contact models.UserContact) error {
 	// Validate contact data
@@ -146,6 +177,20 @@ func updateUserSkills(user models.UserInfo) {
 	}
 }
 
+// updateUserWantedSkills adds all skills the user wants to learn
+func updateUserWantedSkills(user models.UserInfo) {
+	seen := make(map[string]bool)
+	for _, skill := range user.WantedSkills {
+		if seen[skill.Name] {
+			continue
+		}
+		seen[skill.Name] = true
+		if err := addWantedSkill(user, skill); err != nil {
+			// utils.DebugPrint(fmt.Sprintf("Failed to add wanted skill: %v", err))
+		}
+	}
+}
+
 // updateUserContacts adds all contacts for a user
 func updateUserContacts(user models.UserInfo) {
 	seen := make(map[string]bool)
@@ -161,40 +206,10 @@ func updateUserContacts(user models.UserInfo) {
 	}
 }
 
-func updatePassword(user *models.UserInfo) error {
-	row := database.QueryRow("SELECT password_hash FROM users WHERE id = ?", user.ID)
-	pass := ""
-	row.Scan(&pass)
-	if(pass != user.Password){
-		return errors.New("Incorrect old password")
-	}
-	user.OldPassword = fmt.Sprintf("%x", md5.Sum([]byte(user.OldPassword)))
-	if _,err := database.Execute("UPDATE users SET password_hash = ? WHERE id = ?", user.OldPassword, user.ID);  err != nil {
-		return err
-	}
-	return nil
-}
-
-func getUserPassword(user *models.UserInfo) (error, string) {
-	row := database.QueryRow("SELECT password_hash FROM users WHERE id = ?", user.ID)
-	pass := ""
-	err := row.Scan(&pass)
-	return err, pass
-}
-
-
-func updatePersonalUserInfo(user *models.UserInfo) error{
-	err, userPassword := getUserPassword(user)
-	if err != nil {
-		return err
-	}
-	user.Password = fmt.Sprintf("%x", md5.Sum([]byte(user.Password)))
-
-	if(user.OldPassword != ""){
-		if(user.Password != userPassword){
-			return errors.New("Incorrect old password")
-		}
-		if err := updatePassword(user); err != nil {
+// updatePersonalUserInfo changes the login details. A password change keeps only the session with keepSession logged in.
+func updatePersonalUserInfo(user *models.UserInfo, keepSession string) error{
+	if user.NewPassword != "" {
+		if err := auth.ChangePassword(int64(user.ID), user.Password, user.NewPassword, keepSession); err != nil {
 			return err
 		}
 	}
@@ -204,7 +219,8 @@ func updatePersonalUserInfo(user *models.UserInfo) error{
 		}
 	}
 	if(user.Email != ""){
-		if _,err := database.Execute("UPDATE users SET email = ? WHERE id = ?", user.Email, user.ID); err != nil {
+		// The new address replaces the login email once it is confirmed
+		if err := auth.ChangeEmail(int64(user.ID), user.Email); err != nil {
 			return err
 		}
 	}
@@ -212,7 +228,7 @@ func updatePersonalUserInfo(user *models.UserInfo) error{
 }
 
 // performUserUpdate orchestrates the complete user update process
-func performUserUpdate(user *models.UserInfo) error {
+func performUserUpdate(user *models.UserInfo, keepSession string) error {
 	// Clear existing data
 	if err := clearExistingUserData(user.ID); err != nil {
 		return err
@@ -223,14 +239,15 @@ func performUserUpdate(user *models.UserInfo) error {
 		return err
 	}
 	
-	// Update 2FA status
-	if err := updatePersonalUserInfo(user); err != nil {
+	// Update personal info
+	if err := updatePersonalUserInfo(user, keepSession); err != nil {
 		return err
 	}
 	
 	// Add projects, skills, and contacts
 	updateUserProjects(*user)
 	updateUserSkills(*user)
+	updateUserWantedSkills(*user)
 	updateUserContacts(*user)
 
 	return nil
@@ -4,31 +4,9 @@ import (
 	"fmt"
 	"net/http"
 
-	"skillswap/backend/internal/database"
-	"skillswap/backend/internal/handlers/auth"
 	"skillswap/backend/internal/models"
 )
 
-// getUserFromSession retrieves the user ID from the session
-func getUserFromSession(req *http.Request) (int64, error) {
-	session, err := auth.Store.Get(req, "authentication")
-	if err != nil {
-		return 0, fmt.Errorf("invalid session: %w", err)
-	}
-
-	email, ok := session.Values["email"].(string)
-	if !ok || email == "" {
-		return 0, fmt.Errorf("invalid session: no email found")
-	}
-
-	userID, err := database.GetUserIDFromEmail(email)
-	if err != nil {
-		return 0, fmt.Errorf("failed to verify user: %w", err)
-	}
-
-	return userID, nil
-}
-
 // parseUserUpdatePayload decodes and validates the user update request
 func parseUserUpdatePayload(req *http.Request) (*models.UserInfo, error) {
 	var payload models.UserInfo
@@ -46,6 +24,16 @@ func parseUserUpdatePayload(req *http.Request) (*models.UserInfo, error) {
 	return &payload, nil
 }
 
+// validateUserSkills checks the details of every skill in the payload before anything is changed
+func validateUserSkills(payload *models.UserInfo) error {
+	for i := range payload.Skills {
+		if err := payload.Skills[i].Normalize(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validateUserOwnership verifies that the user is updating their own profile
 func validateUserOwnership(sessionUserID int64, payloadUserID int) error {
 	if int64(payloadUserID) != sessionUserID {
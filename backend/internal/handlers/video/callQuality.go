@@ -0,0 +1,106 @@
+package video
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// minQualityInterval is how often a client may submit a quality report over the signaling socket
+const minQualityInterval = 5 * time.Second
+
+// qualityQueueSize is how many socket reports may wait for the database before new ones are dropped
+const qualityQueueSize = 256
+
+// ICE candidate types as reported by getStats
+var candidateTypes = map[string]bool{"host": true, "srflx": true, "prflx": true, "relay": true}
+
+// QualityReport is a summary of a client's getStats() output for its active connection.
+// Metrics a browser does not report are left out and stored as NULL.
+type QualityReport struct {
+	RTTMs               *float64 `json:"rttMs"`
+	PacketLoss          *float64 `json:"packetLoss"` // fraction of packets lost since the last report, 0-1
+	JitterMs            *float64 `json:"jitterMs"`
+	InboundKbps         *float64 `json:"inboundKbps"`
+	OutboundKbps        *float64 `json:"outboundKbps"`
+	LocalCandidateType  string   `json:"localCandidateType"`
+	RemoteCandidateType string   `json:"remoteCandidateType"`
+}
+
+var errInvalidQualityReport = errors.New("invalid quality report")
+
+// queuedQualityReport is a socket report waiting to be stored
+type queuedQualityReport struct {
+	sessionID int64
+	userID    int64
+	roomID    string
+	report    QualityReport
+}
+
+// qualityQueue holds socket reports for RunQualityWriter, so rooms never wait on the database
+var qualityQueue = make(chan queuedQualityReport, qualityQueueSize)
+
+// Validate checks that a report carries at least one plausible metric
+func (r *QualityReport) Validate() error {
+	metrics := []*float64{r.RTTMs, r.PacketLoss, r.JitterMs, r.InboundKbps, r.OutboundKbps}
+	present := false
+	for _, m := range metrics {
+		if m == nil {
+			continue
+		}
+		if math.IsNaN(*m) || math.IsInf(*m, 0) || *m < 0 {
+			return errInvalidQualityReport
+		}
+		present = true
+	}
+	if !present && r.LocalCandidateType == "" && r.RemoteCandidateType == "" {
+		return errInvalidQualityReport
+	}
+	if r.PacketLoss != nil && *r.PacketLoss > 1 {
+		return errInvalidQualityReport
+	}
+	if r.LocalCandidateType != "" && !candidateTypes[r.LocalCandidateType] {
+		return errInvalidQualityReport
+	}
+	if r.RemoteCandidateType != "" && !candidateTypes[r.RemoteCandidateType] {
+		return errInvalidQualityReport
+	}
+	return nil
+}
+
+// recordQuality queues a quality report sent over the signaling socket to be stored with the
+// room's call session. Reports are dropped while the queue is full.
+func (room *Room) recordQuality(message Message) {
+	client, ok := room.Clients[message.From]
+	if !ok || room.session == nil || room.session.ID == 0 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(client.lastQualityReport) < minQualityInterval {
+		return
+	}
+
+	var report QualityReport
+	if err := decodeMessageData(message.Data, &report); err != nil || report.Validate() != nil {
+		log.Printf("Signaling: Ignoring invalid quality report from %s", client.ID)
+		return
+	}
+	client.lastQualityReport = now
+	queued := queuedQualityReport{sessionID: room.session.ID, userID: int64(client.UserID), roomID: room.ID, report: report}
+	select {
+	case qualityQueue <- queued:
+	default:
+		log.Printf("Signaling: Quality report queue is full, dropping a report for room %s", room.ID)
+	}
+}
+
+// RunQualityWriter stores the quality reports clients send over the signaling socket.
+// Reports sent to the REST endpoint are stored by its handler instead.
+func RunQualityWriter() {
+	for q := range qualityQueue {
+		if err := insertQualityReport(q.sessionID, q.userID, q.report); err != nil {
+			log.Printf("Signaling: Failed to store quality report for room %s: %v", q.roomID, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package video
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// GetCallSessions returns the session user's past call sessions, newest first.
+// An optional "limit" query parameter caps the number of sessions (default 20, max 100).
+func GetCallSessions(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	limit := 20
+	if l, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	sessions, err := fetchUserCallSessions(userID, limit)
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve call sessions"})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, map[string]interface{}{"sessions": sessions})
+}
+
+// ExportWhiteboard returns the whiteboard of a call session the session user took part in as an SVG image.
+// The session is given by the "session" query parameter.
+func ExportWhiteboard(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(req.URL.Query().Get("session"), 10, 64)
+	if err != nil || sessionID <= 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid session ID"})
+		return
+	}
+	if !isCallParticipant(sessionID, userID) {
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Whiteboard not found"})
+		return
+	}
+
+	board, err := fetchWhiteboard(sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Whiteboard not found"})
+		return
+	}
+	if err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve whiteboard"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="whiteboard-%d.svg"`, sessionID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(board.RenderSVG()))
+}
+
+// SubmitCallQuality stores a quality report for a call session the session user took part in.
+// Clients that cannot use the signaling socket's "stats" message post the same report here
+// together with the "sessionId" they received when joining.
+func SubmitCallQuality(w http.ResponseWriter, req *http.Request) {
+	userID, err := auth.GetSessionUserID(req)
+	if err != nil {
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": "Not authenticated"})
+		return
+	}
+
+	var body struct {
+		SessionID int64 `json:"sessionId"`
+		QualityReport
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if err := body.QualityReport.Validate(); err != nil {
+		utils.SendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid quality report"})
+		return
+	}
+	if body.SessionID <= 0 || !isCallParticipant(body.SessionID, userID) {
+		utils.SendJSONResponse(w, http.StatusNotFound, map[string]string{"error": "Call session not found"})
+		return
+	}
+
+	if err := insertQualityReport(body.SessionID, userID, body.QualityReport); err != nil {
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{"error": "Failed to store quality report"})
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusCreated, map[string]string{"status": "ok"})
+}
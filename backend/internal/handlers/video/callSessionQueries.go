@@ -0,0 +1,185 @@
+package video
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/database"
+)
+
+// CallParticipant is a single join/leave record of a user in a call session
+type CallParticipant struct {
+	UserID   int64      `json:"user_id"`
+	Username string     `json:"username"`
+	JoinedAt time.Time  `json:"joined_at"`
+	LeftAt   *time.Time `json:"left_at"`
+}
+
+// CallSessionSummary is a past call session as returned to users
+type CallSessionSummary struct {
+	ID              int64             `json:"id"`
+	RoomID          string            `json:"room_id"`
+	ChatID          *int64            `json:"chat_id"`
+	StartedAt       time.Time         `json:"started_at"`
+	EndedAt         *time.Time        `json:"ended_at"`
+	DurationSeconds int               `json:"duration_seconds"`
+	Fulfilled       bool              `json:"fulfilled"`
+	Participants    []CallParticipant `json:"participants"`
+}
+
+// insertCallSession stores a new call session and sets its ID
+func insertCallSession(s *CallSession) error {
+	var chatID interface{}
+	if s.ChatID > 0 {
+		chatID = s.ChatID
+	}
+	result, err := database.Execute(
+		"INSERT INTO call_sessions (room_id, chat_id, started_at) VALUES (?, ?, ?)",
+		s.RoomID, chatID, s.StartedAt,
+	)
+	if err != nil {
+		return err
+	}
+	s.ID, err = result.LastInsertId()
+	return err
+}
+
+// insertCallParticipant records a user joining a call session and returns the record ID
+func insertCallParticipant(sessionID int64, userID int, joinedAt time.Time) (int64, error) {
+	result, err := database.Execute(
+		"INSERT INTO call_session_participants (session_id, user_id, joined_at) VALUES (?, ?, ?)",
+		sessionID, userID, joinedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// markCallParticipantLeft records when a user left a call session
+func markCallParticipantLeft(participantID int64, leftAt time.Time) error {
+	_, err := database.Execute("UPDATE call_session_participants SET left_at = ? WHERE id = ?", leftAt, participantID)
+	return err
+}
+
+// saveCallSessionOverlap stores the overlap accumulated so far
+func saveCallSessionOverlap(s *CallSession, overlap time.Duration) error {
+	_, err := database.Execute("UPDATE call_sessions SET overlap_seconds = ? WHERE id = ?", int(overlap.Seconds()), s.ID)
+	return err
+}
+
+// endCallSession stores the final overlap and end time of a call session
+func endCallSession(s *CallSession, endedAt time.Time, overlap time.Duration) error {
+	_, err := database.Execute(
+		"UPDATE call_sessions SET ended_at = ?, overlap_seconds = ? WHERE id = ?",
+		endedAt, int(overlap.Seconds()), s.ID,
+	)
+	return err
+}
+
+// markCallSessionFulfilled records that this session settled its swap
+func markCallSessionFulfilled(sessionID int64) error {
+	_, err := database.Execute("UPDATE call_sessions SET fulfilled_at = NOW() WHERE id = ?", sessionID)
+	return err
+}
+
+// fetchUserCallSessions returns the most recent call sessions the user took part in
+func fetchUserCallSessions(userID int64, limit int) ([]CallSessionSummary, error) {
+	rows, err := database.Query(`
+		SELECT cs.id, cs.room_id, cs.chat_id, cs.started_at, cs.ended_at, cs.overlap_seconds, cs.fulfilled_at IS NOT NULL
+		FROM call_sessions cs
+		WHERE cs.id IN (SELECT session_id FROM call_session_participants WHERE user_id = ?)
+		ORDER BY cs.started_at DESC
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []CallSessionSummary{}
+	for rows.Next() {
+		var s CallSessionSummary
+		var chatID sql.NullInt64
+		var endedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.RoomID, &chatID, &s.StartedAt, &endedAt, &s.DurationSeconds, &s.Fulfilled); err != nil {
+			return nil, err
+		}
+		if chatID.Valid {
+			s.ChatID = &chatID.Int64
+		}
+		if endedAt.Valid {
+			s.EndedAt = &endedAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(sessions))
+	for i := range sessions {
+		ids[i] = sessions[i].ID
+	}
+	participants, err := fetchCallParticipants(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Participants = participants[sessions[i].ID]
+		if sessions[i].Participants == nil {
+			sessions[i].Participants = []CallParticipant{}
+		}
+	}
+	return sessions, nil
+}
+
+// fetchCallParticipants returns the join/leave records of the call sessions, by session ID
+func fetchCallParticipants(sessionIDs []int64) (map[int64][]CallParticipant, error) {
+	participants := map[int64][]CallParticipant{}
+	if len(sessionIDs) == 0 {
+		return participants, nil
+	}
+	args := make([]interface{}, len(sessionIDs))
+	for i, id := range sessionIDs {
+		args[i] = id
+	}
+	rows, err := database.Query(`
+		SELECT p.session_id, p.user_id, u.username, p.joined_at, p.left_at
+		FROM call_session_participants p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.session_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+`)
+		ORDER BY p.session_id, p.joined_at ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID int64
+		var p CallParticipant
+		var leftAt sql.NullTime
+		if err := rows.Scan(&sessionID, &p.UserID, &p.Username, &p.JoinedAt, &leftAt); err != nil {
+			return nil, err
+		}
+		if leftAt.Valid {
+			p.LeftAt = &leftAt.Time
+		}
+		participants[sessionID] = append(participants[sessionID], p)
+	}
+	return participants, rows.Err()
+}
+
+// insertQualityReport stores a quality report against a call session
+func insertQualityReport(sessionID int64, userID int64, r QualityReport) error {
+	_, err := database.Execute(`
+		INSERT INTO call_quality_reports
+			(session_id, user_id, room_id, rtt_ms, packet_loss, jitter_ms, inbound_kbps, outbound_kbps,
+			 local_candidate_type, remote_candidate_type)
+		SELECT id, ?, room_id, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, '')
+		FROM call_sessions WHERE id = ?`,
+		userID, r.RTTMs, r.PacketLoss, r.JitterMs, r.InboundKbps, r.OutboundKbps,
+		r.LocalCandidateType, r.RemoteCandidateType, sessionID,
+	)
+	return err
+}
@@ -0,0 +1,143 @@
+package video
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// presence is a single connected interval of a user in a call. A zero Left means still connected.
+type presence struct {
+	Joined time.Time
+	Left   time.Time
+}
+
+// CallSession tracks who was connected to a signaling room and when.
+// It is owned by the room's Run loop and is not safe for concurrent use, apart from
+// fulfilled, which the room's record writer sets.
+type CallSession struct {
+	ID        int64
+	RoomID    string
+	ChatID    int64
+	StartedAt time.Time
+	fulfilled atomic.Bool
+	presences map[int][]presence // user ID -> connected intervals
+}
+
+// Fulfilled reports whether the session settled its chat's swap
+func (s *CallSession) Fulfilled() bool {
+	return s.fulfilled.Load()
+}
+
+// newCallSession starts tracking a call in the given room
+func newCallSession(roomID string, startedAt time.Time) *CallSession {
+	return &CallSession{
+		RoomID:    roomID,
+		ChatID:    chatIDFromRoom(roomID),
+		StartedAt: startedAt,
+		presences: make(map[int][]presence),
+	}
+}
+
+// chatIDFromRoom extracts the chat ID from room IDs of the form "chat_<id>", or 0 if there is none
+func chatIDFromRoom(roomID string) int64 {
+	idStr, ok := strings.CutPrefix(roomID, "chat_")
+	if !ok {
+		return 0
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
+// Join records that a user connected at the given time
+func (s *CallSession) Join(userID int, at time.Time) {
+	s.presences[userID] = append(s.presences[userID], presence{Joined: at})
+}
+
+// Leave closes the user's most recent open interval
+func (s *CallSession) Leave(userID int, at time.Time) {
+	intervals := s.presences[userID]
+	for i := len(intervals) - 1; i >= 0; i-- {
+		if intervals[i].Left.IsZero() {
+			intervals[i].Left = at
+			return
+		}
+	}
+}
+
+// Participants returns the IDs of every user who joined the call
+func (s *CallSession) Participants() []int {
+	ids := make([]int, 0, len(s.presences))
+	for id := range s.presences {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Overlap returns how long at least two distinct users were connected at the same time,
+// treating intervals that are still open as ending at now.
+func (s *CallSession) Overlap(now time.Time) time.Duration {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	var events []event
+	for _, intervals := range s.presences {
+		// A user connected from several tabs still counts once
+		for _, p := range mergePresences(intervals, now) {
+			events = append(events, event{p.Joined, 1}, event{p.Left, -1})
+		}
+	}
+	// Process leaves before joins at the same instant so touching intervals don't overlap
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+
+	var total time.Duration
+	connected := 0
+	var since time.Time
+	for _, e := range events {
+		if connected >= 2 {
+			total += e.at.Sub(since)
+		}
+		connected += e.delta
+		since = e.at
+	}
+	return total
+}
+
+// mergePresences closes open intervals at now and merges overlapping ones
+func mergePresences(intervals []presence, now time.Time) []presence {
+	closed := make([]presence, 0, len(intervals))
+	for _, p := range intervals {
+		if p.Left.IsZero() {
+			p.Left = now
+		}
+		if p.Left.After(p.Joined) {
+			closed = append(closed, p)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i].Joined.Before(closed[j].Joined) })
+
+	var merged []presence
+	for _, p := range closed {
+		last := len(merged) - 1
+		if last >= 0 && !p.Joined.After(merged[last].Left) {
+			if p.Left.After(merged[last].Left) {
+				merged[last].Left = p.Left
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
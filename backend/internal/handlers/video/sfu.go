@@ -0,0 +1,468 @@
+package video
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"skillswap/backend/internal/config"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+)
+
+// sfuPeerID is the "from" value of signaling messages sent by the SFU itself.
+const sfuPeerID = "sfu"
+
+// SFU is a Selective Forwarding Unit: each participant publishes its media once to the
+// server, which forwards every track to the other members of the room. Signaling uses
+// the same Message envelope as mesh mode, with the SFU acting as the remote peer.
+type SFU struct {
+	api    *webrtc.API
+	config webrtc.Configuration
+
+	mu    sync.Mutex
+	rooms map[string]*sfuRoom
+}
+
+// sfuRoom holds the server-side peer connections and published tracks of one room
+type sfuRoom struct {
+	mu     sync.Mutex
+	peers  map[string]*sfuPeer
+	tracks map[string]*sfuTrack
+}
+
+// sfuPeer is a participant's server-side PeerConnection
+type sfuPeer struct {
+	id     string
+	roomID string
+	pc     *webrtc.PeerConnection
+	send   func(Message) error
+
+	mu                sync.Mutex // guards negotiation state below
+	senders           map[string]*webrtc.RTPSender
+	pendingCandidates []webrtc.ICECandidateInit
+	renegotiate       bool // an offer is needed once the current negotiation completes
+}
+
+// sfuTrack is a published track being forwarded to the other peers of a room
+type sfuTrack struct {
+	key     string
+	owner   *sfuPeer
+	ssrc    webrtc.SSRC
+	local   *webrtc.TrackLocalStaticRTP
+	isVideo bool
+}
+
+var (
+	defaultSFU     *SFU
+	defaultSFUOnce sync.Once
+)
+
+// getSFU returns the process-wide SFU, configured from the environment on first use.
+func getSFU() *SFU {
+	defaultSFUOnce.Do(func() {
+		settings := webrtc.SettingEngine{}
+		if ips := config.SFUPublicIPs(); len(ips) > 0 {
+			settings.SetNAT1To1IPs(ips, webrtc.ICECandidateTypeHost)
+		}
+		if portMin, portMax := config.SFUPortRange(); portMax > 0 {
+			if err := settings.SetEphemeralUDPPortRange(portMin, portMax); err != nil {
+				log.Println("SFU: invalid UDP port range:", err)
+			}
+		}
+		sfu, err := NewSFU(settings, webrtc.Configuration{})
+		if err != nil {
+			log.Println("SFU: failed to initialize:", err)
+			return
+		}
+		defaultSFU = sfu
+	})
+	return defaultSFU
+}
+
+// NewSFU creates an SFU whose peer connections use the given settings and configuration.
+func NewSFU(settings webrtc.SettingEngine, configuration webrtc.Configuration) (*SFU, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	registry := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(mediaEngine, registry); err != nil {
+		return nil, err
+	}
+	return &SFU{
+		api: webrtc.NewAPI(
+			webrtc.WithMediaEngine(mediaEngine),
+			webrtc.WithInterceptorRegistry(registry),
+			webrtc.WithSettingEngine(settings),
+		),
+		config: configuration,
+		rooms:  make(map[string]*sfuRoom),
+	}, nil
+}
+
+// Join creates the server-side connection for a peer. Messages for the peer are passed to send.
+// Tracks already published in the room are offered to the peer straight away.
+func (s *SFU) Join(roomID, peerID string, send func(Message) error) error {
+	pc, err := s.api.NewPeerConnection(s.config)
+	if err != nil {
+		return err
+	}
+	peer := &sfuPeer{
+		id:      peerID,
+		roomID:  roomID,
+		pc:      pc,
+		send:    send,
+		senders: make(map[string]*webrtc.RTPSender),
+	}
+
+	s.mu.Lock()
+	room, ok := s.rooms[roomID]
+	if !ok {
+		room = &sfuRoom{peers: make(map[string]*sfuPeer), tracks: make(map[string]*sfuTrack)}
+		s.rooms[roomID] = room
+	}
+	room.mu.Lock()
+	if _, exists := room.peers[peerID]; exists {
+		room.mu.Unlock()
+		s.mu.Unlock()
+		pc.Close()
+		return fmt.Errorf("peer %s already joined room %s", peerID, roomID)
+	}
+	room.peers[peerID] = peer
+	existing := make([]*sfuTrack, 0, len(room.tracks))
+	for _, track := range room.tracks {
+		existing = append(existing, track)
+	}
+	room.mu.Unlock()
+	s.mu.Unlock()
+
+	pc.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+		peer.sendMessage("candidate", candidate.ToJSON())
+	})
+	pc.OnTrack(func(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		s.forward(room, peer, remote)
+	})
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed {
+			go s.Leave(roomID, peerID)
+		}
+	})
+
+	for _, track := range existing {
+		peer.subscribe(track)
+	}
+	return nil
+}
+
+// HandleMessage applies an offer, answer or ICE candidate sent by a peer to its server-side connection.
+func (s *SFU) HandleMessage(roomID, peerID string, msg Message) error {
+	peer := s.peer(roomID, peerID)
+	if peer == nil {
+		return fmt.Errorf("peer %s is not in room %s", peerID, roomID)
+	}
+
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	switch msg.Type {
+	case "offer":
+		var offer webrtc.SessionDescription
+		if err := decodeMessageData(msg.Data, &offer); err != nil {
+			return err
+		}
+		// The SFU is the polite side: drop its own pending offer and retry after answering
+		if peer.pc.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+			if err := peer.pc.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+				return err
+			}
+			peer.renegotiate = true
+		}
+		if err := peer.pc.SetRemoteDescription(offer); err != nil {
+			return err
+		}
+		peer.flushCandidates()
+		answer, err := peer.pc.CreateAnswer(nil)
+		if err != nil {
+			return err
+		}
+		if err := peer.pc.SetLocalDescription(answer); err != nil {
+			return err
+		}
+		peer.sendMessage("answer", peer.pc.LocalDescription())
+		peer.negotiatePending()
+
+	case "answer":
+		var answer webrtc.SessionDescription
+		if err := decodeMessageData(msg.Data, &answer); err != nil {
+			return err
+		}
+		if err := peer.pc.SetRemoteDescription(answer); err != nil {
+			return err
+		}
+		peer.flushCandidates()
+		peer.negotiatePending()
+
+	case "candidate":
+		var candidate webrtc.ICECandidateInit
+		if err := decodeMessageData(msg.Data, &candidate); err != nil {
+			return err
+		}
+		if peer.pc.RemoteDescription() == nil {
+			peer.pendingCandidates = append(peer.pendingCandidates, candidate)
+			return nil
+		}
+		return peer.pc.AddICECandidate(candidate)
+
+	default:
+		return fmt.Errorf("unsupported SFU message type %q", msg.Type)
+	}
+	return nil
+}
+
+// Leave closes a peer's connection and stops forwarding its tracks to the rest of the room.
+func (s *SFU) Leave(roomID, peerID string) {
+	s.mu.Lock()
+	room, ok := s.rooms[roomID]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	room.mu.Lock()
+	peer, ok := room.peers[peerID]
+	if !ok {
+		room.mu.Unlock()
+		s.mu.Unlock()
+		return
+	}
+	delete(room.peers, peerID)
+	var removed []string
+	for key, track := range room.tracks {
+		if track.owner == peer {
+			delete(room.tracks, key)
+			removed = append(removed, key)
+		}
+	}
+	others := room.peerList()
+	if len(room.peers) == 0 {
+		delete(s.rooms, roomID)
+	}
+	room.mu.Unlock()
+	s.mu.Unlock()
+
+	if err := peer.pc.Close(); err != nil {
+		log.Printf("SFU: failed to close connection for %s: %v", peerID, err)
+	}
+	for _, other := range others {
+		other.unsubscribe(removed...)
+	}
+}
+
+// SupportsMessage reports whether a signaling message type is handled by the SFU
+func (s *SFU) SupportsMessage(msgType string) bool {
+	return msgType == "offer" || msgType == "answer" || msgType == "candidate"
+}
+
+// peer looks up a joined peer
+func (s *SFU) peer(roomID, peerID string) *sfuPeer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[roomID]
+	if !ok {
+		return nil
+	}
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return room.peers[peerID]
+}
+
+// forward publishes a peer's incoming track to the room and copies its packets until it ends
+func (s *SFU) forward(room *sfuRoom, owner *sfuPeer, remote *webrtc.TrackRemote) {
+	local, err := webrtc.NewTrackLocalStaticRTP(remote.Codec().RTPCodecCapability, remote.ID(), owner.id)
+	if err != nil {
+		log.Printf("SFU: failed to create forwarding track for %s: %v", owner.id, err)
+		return
+	}
+	track := &sfuTrack{
+		key:     owner.id + "/" + remote.ID(),
+		owner:   owner,
+		ssrc:    remote.SSRC(),
+		local:   local,
+		isVideo: remote.Kind() == webrtc.RTPCodecTypeVideo,
+	}
+
+	room.mu.Lock()
+	if room.peers[owner.id] != owner {
+		room.mu.Unlock()
+		return
+	}
+	room.tracks[track.key] = track
+	subscribers := room.peerList()
+	room.mu.Unlock()
+
+	for _, peer := range subscribers {
+		if peer != owner {
+			peer.subscribe(track)
+		}
+	}
+
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			break
+		}
+		if err := local.WriteRTP(packet); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			break
+		}
+	}
+
+	room.mu.Lock()
+	_, stillPublished := room.tracks[track.key]
+	delete(room.tracks, track.key)
+	subscribers = room.peerList()
+	room.mu.Unlock()
+	if stillPublished {
+		for _, peer := range subscribers {
+			peer.unsubscribe(track.key)
+		}
+	}
+}
+
+// peerList returns the room's peers. The caller must hold room.mu.
+func (room *sfuRoom) peerList() []*sfuPeer {
+	peers := make([]*sfuPeer, 0, len(room.peers))
+	for _, peer := range room.peers {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// subscribe starts forwarding a track to this peer and renegotiates
+func (p *sfuPeer) subscribe(track *sfuTrack) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.senders[track.key]; ok || p.pc.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		return
+	}
+	sender, err := p.pc.AddTrack(track.local)
+	if err != nil {
+		log.Printf("SFU: failed to forward %s to %s: %v", track.key, p.id, err)
+		return
+	}
+	p.senders[track.key] = sender
+
+	// Relay keyframe requests from the subscriber to the publisher
+	go func() {
+		for {
+			packets, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, packet := range packets {
+				switch packet.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					track.requestKeyframe()
+				}
+			}
+		}
+	}()
+	track.requestKeyframe()
+	p.negotiate()
+}
+
+// unsubscribe stops forwarding the given tracks to this peer and renegotiates
+func (p *sfuPeer) unsubscribe(keys ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := false
+	for _, key := range keys {
+		sender, ok := p.senders[key]
+		if !ok {
+			continue
+		}
+		delete(p.senders, key)
+		if err := p.pc.RemoveTrack(sender); err != nil {
+			log.Printf("SFU: failed to stop forwarding %s to %s: %v", key, p.id, err)
+			continue
+		}
+		changed = true
+	}
+	if changed {
+		p.negotiate()
+	}
+}
+
+// negotiate sends a new offer to the peer, or defers it until the current exchange completes.
+// The caller must hold p.mu.
+func (p *sfuPeer) negotiate() {
+	if p.pc.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		return
+	}
+	if p.pc.SignalingState() != webrtc.SignalingStateStable {
+		p.renegotiate = true
+		return
+	}
+	p.renegotiate = false
+	offer, err := p.pc.CreateOffer(nil)
+	if err != nil {
+		log.Printf("SFU: failed to create offer for %s: %v", p.id, err)
+		return
+	}
+	if err := p.pc.SetLocalDescription(offer); err != nil {
+		log.Printf("SFU: failed to set offer for %s: %v", p.id, err)
+		return
+	}
+	p.sendMessage("offer", p.pc.LocalDescription())
+}
+
+// negotiatePending sends a deferred offer once signaling is stable again. The caller must hold p.mu.
+func (p *sfuPeer) negotiatePending() {
+	if p.renegotiate && p.pc.SignalingState() == webrtc.SignalingStateStable {
+		p.negotiate()
+	}
+}
+
+// flushCandidates applies ICE candidates received before the remote description. The caller must hold p.mu.
+func (p *sfuPeer) flushCandidates() {
+	for _, candidate := range p.pendingCandidates {
+		if err := p.pc.AddICECandidate(candidate); err != nil {
+			log.Printf("SFU: failed to add ICE candidate for %s: %v", p.id, err)
+		}
+	}
+	p.pendingCandidates = nil
+}
+
+// sendMessage delivers a signaling message from the SFU to the peer
+func (p *sfuPeer) sendMessage(msgType string, data interface{}) {
+	msg := Message{Type: msgType, RoomID: p.roomID, From: sfuPeerID, To: p.id, Data: data}
+	if err := p.send(msg); err != nil {
+		log.Printf("SFU: failed to send %s to %s: %v", msgType, p.id, err)
+	}
+}
+
+// requestKeyframe asks the publisher for a fresh keyframe so new subscribers can start decoding
+func (t *sfuTrack) requestKeyframe() {
+	if !t.isVideo {
+		return
+	}
+	if err := t.owner.pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(t.ssrc)}}); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		log.Printf("SFU: failed to request keyframe from %s: %v", t.owner.id, err)
+	}
+}
+
+// decodeMessageData converts a message payload (decoded from JSON or set directly) into v
+func decodeMessageData(data interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
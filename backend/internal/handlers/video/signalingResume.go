@@ -0,0 +1,115 @@
+package video
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"skillswap/backend/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// graceExpiry reports that a client's grace window ran out. since identifies the disconnect
+// it was started for, so an expiry from an earlier disconnect is ignored after a resume.
+type graceExpiry struct {
+	client *Client
+	since  time.Time
+}
+
+// newResumeToken returns a random token a client presents to resume its identity
+func newResumeToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Println("Signaling: Failed to generate resume token:", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// resumable returns the client a reconnecting user may take over with the given token, or nil.
+// A client that still looks connected can be taken over too, since the server often notices a
+// dropped connection only after the peer has already reconnected.
+func (room *Room) resumable(token string, userID int) *Client {
+	if token == "" {
+		return nil
+	}
+	for _, client := range room.Clients {
+		if client.UserID == userID && client.resumeToken != "" &&
+			subtle.ConstantTimeCompare([]byte(client.resumeToken), []byte(token)) == 1 {
+			return client
+		}
+	}
+	return nil
+}
+
+// suspend closes a client's connection and keeps its identity for the resume grace window.
+// Other peers hear peer_disconnected now and peer_left if the client does not come back.
+func (room *Room) suspend(client *Client) {
+	grace := config.VideoResumeGrace()
+	if grace <= 0 || client.resumeToken == "" {
+		room.removeClient(client)
+		room.notifyPeers(client.ID, "peer_left")
+		return
+	}
+	if client.Conn == nil {
+		return
+	}
+
+	now := time.Now()
+	client.detach(now)
+	if room.session != nil {
+		room.session.Leave(client.UserID, now)
+	}
+	room.notifyPeers(client.ID, "peer_disconnected")
+
+	expiry := graceExpiry{client: client, since: now}
+	client.graceTimer = time.AfterFunc(grace, func() {
+		select {
+		case room.expired <- expiry:
+		case <-room.done:
+		}
+	})
+}
+
+// resume attaches a new connection to an existing client, issues it a fresh resume token and
+// tells the other peers so they can ICE-restart instead of tearing down the call.
+func (room *Room) resume(client *Client, conn *websocket.Conn) {
+	if client.graceTimer != nil {
+		client.graceTimer.Stop()
+		client.graceTimer = nil
+	}
+	wasSuspended := client.Conn == nil
+	client.attach(conn)
+	if wasSuspended && room.session != nil {
+		room.session.Join(client.UserID, time.Now())
+	}
+	client.resumeToken = newResumeToken()
+	room.welcome(client, true)
+	room.notifyPeers(client.ID, "peer_reconnected")
+}
+
+// attach makes conn the client's connection, closing the one it replaces.
+func (c *Client) attach(conn *websocket.Conn) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.Conn != nil && c.Conn != conn {
+		c.Conn.Close()
+	}
+	c.Conn = conn
+	c.disconnectedAt = time.Time{}
+}
+
+// detach closes the client's connection, if any, and marks it disconnected at the given time.
+func (c *Client) detach(at time.Time) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.Conn == nil {
+		return
+	}
+	c.Conn.Close()
+	c.Conn = nil
+	c.disconnectedAt = at
+}
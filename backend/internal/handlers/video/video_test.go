@@ -2,9 +2,19 @@ package video
 
 import (
 	"encoding/json"
+	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"skillswap/backend/internal/config"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
 )
 
 // TestMain sets up the test environment for video tests
@@ -130,3 +140,572 @@ func TestCreatePeerConnection(t *testing.T) {
 		}
 	})
 }
+
+// TestChatIDFromRoom tests extracting chat IDs from signaling room IDs
+func TestChatIDFromRoom(t *testing.T) {
+	tests := map[string]int64{
+		"chat_42":   42,
+		"chat_0":    0,
+		"chat_abc":  0,
+		"lobby":     0,
+		"":          0,
+		"chat_-3":   0,
+		"xchat_12":  0,
+		"chat_1001": 1001,
+	}
+	for roomID, expected := range tests {
+		if got := chatIDFromRoom(roomID); got != expected {
+			t.Errorf("chatIDFromRoom(%q) = %d, expected %d", roomID, got, expected)
+		}
+	}
+}
+
+// TestCallSessionOverlap tests how long two distinct users share a call
+func TestCallSessionOverlap(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	t.Run("Single participant has no overlap", func(t *testing.T) {
+		s := newCallSession("chat_1", start)
+		s.Join(1, at(0))
+		if got := s.Overlap(at(30)); got != 0 {
+			t.Errorf("Expected no overlap, got %v", got)
+		}
+	})
+
+	t.Run("Overlapping participants", func(t *testing.T) {
+		s := newCallSession("chat_1", start)
+		s.Join(1, at(0))
+		s.Join(2, at(5))
+		s.Leave(1, at(20))
+		s.Leave(2, at(25))
+		if got := s.Overlap(at(60)); got != 15*time.Minute {
+			t.Errorf("Expected 15m overlap, got %v", got)
+		}
+	})
+
+	t.Run("Open intervals end at now", func(t *testing.T) {
+		s := newCallSession("chat_1", start)
+		s.Join(1, at(0))
+		s.Join(2, at(10))
+		if got := s.Overlap(at(12)); got != 2*time.Minute {
+			t.Errorf("Expected 2m overlap, got %v", got)
+		}
+	})
+
+	t.Run("Reconnects accumulate", func(t *testing.T) {
+		s := newCallSession("chat_1", start)
+		s.Join(1, at(0))
+		s.Join(2, at(0))
+		s.Leave(2, at(5))
+		s.Join(2, at(10))
+		s.Leave(2, at(15))
+		s.Leave(1, at(15))
+		if got := s.Overlap(at(15)); got != 10*time.Minute {
+			t.Errorf("Expected 10m overlap, got %v", got)
+		}
+	})
+
+	t.Run("Same user in two tabs counts once", func(t *testing.T) {
+		s := newCallSession("chat_1", start)
+		s.Join(1, at(0))
+		s.Join(1, at(1))
+		s.Leave(1, at(10))
+		s.Leave(1, at(10))
+		if got := s.Overlap(at(10)); got != 0 {
+			t.Errorf("Expected no overlap for one user, got %v", got)
+		}
+		if participants := s.Participants(); len(participants) != 1 || participants[0] != 1 {
+			t.Errorf("Expected participants [1], got %v", participants)
+		}
+	})
+
+	t.Run("Back-to-back participants do not overlap", func(t *testing.T) {
+		s := newCallSession("chat_1", start)
+		s.Join(1, at(0))
+		s.Leave(1, at(10))
+		s.Join(2, at(10))
+		s.Leave(2, at(20))
+		if got := s.Overlap(at(20)); got != 0 {
+			t.Errorf("Expected no overlap, got %v", got)
+		}
+	})
+}
+
+func TestFulfillmentWaitsForAcceptedRequest(t *testing.T) {
+	accepted := false
+	completions, recorded := 0, 0
+	previousComplete, previousRecord := completeChatSwap, recordFulfillment
+	completeChatSwap = func(chatID int64) (bool, error) {
+		completions++
+		return accepted, nil
+	}
+	recordFulfillment = func(*CallSession, time.Duration) error {
+		recorded++
+		return nil
+	}
+	defer func() { completeChatSwap, recordFulfillment = previousComplete, previousRecord }()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	room := &Room{ID: "chat_7", session: newCallSession("chat_7", start), records: make(chan func(), roomRecordQueueSize)}
+	room.session.ID = 1
+	room.session.Join(1, start)
+	room.session.Join(2, start)
+	after := start.Add(config.MinCallDuration())
+	// check runs a fulfillment check and the writes it queued, as storeRecords would
+	check := func(now time.Time) {
+		room.checkFulfillment(now)
+		for len(room.records) > 0 {
+			(<-room.records)()
+		}
+	}
+
+	// Past the minimum duration with nothing accepted yet, the call is not settled
+	check(after)
+	if room.session.Fulfilled() || recorded != 0 {
+		t.Fatal("Expected the session to wait for an accepted request")
+	}
+
+	// The request is accepted while the call goes on; the next heartbeat completes it
+	accepted = true
+	check(after.Add(30 * time.Second))
+	if !room.session.Fulfilled() || recorded != 1 {
+		t.Fatalf("Expected the request accepted mid-call to be completed, fulfilled=%v recorded=%d", room.session.Fulfilled(), recorded)
+	}
+	check(after.Add(time.Minute))
+	if completions != 2 {
+		t.Errorf("Expected no checks once settled, got %d", completions)
+	}
+
+	// Checks queued before the swap settled do not complete it twice
+	room.session.fulfilled.Store(false)
+	room.checkFulfillment(after.Add(2 * time.Minute))
+	room.checkFulfillment(after.Add(3 * time.Minute))
+	for len(room.records) > 0 {
+		(<-room.records)()
+	}
+	if completions != 3 || recorded != 2 {
+		t.Errorf("Expected one completion for two queued checks, got %d completions and %d records", completions, recorded)
+	}
+}
+
+// sfuTestPeer is an in-process pion client that signals with the SFU directly
+type sfuTestPeer struct {
+	id      string
+	roomID  string
+	pc      *webrtc.PeerConnection
+	sfu     *SFU
+	inbox   chan Message
+	done    chan struct{}
+	pending []webrtc.ICECandidateInit
+}
+
+// loopbackSettings keeps ICE on the loopback interface so the test needs no network
+func loopbackSettings() webrtc.SettingEngine {
+	settings := webrtc.SettingEngine{}
+	settings.SetIncludeLoopbackCandidate(true)
+	settings.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	settings.SetIPFilter(func(ip net.IP) bool { return ip.IsLoopback() })
+	return settings
+}
+
+func newSFUTestPeer(t *testing.T, sfu *SFU, roomID, id string) *sfuTestPeer {
+	t.Helper()
+	mediaEngine := &webrtc.MediaEngine{}
+	if err := mediaEngine.RegisterDefaultCodecs(); err != nil {
+		t.Fatalf("Failed to register codecs: %v", err)
+	}
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine), webrtc.WithSettingEngine(loopbackSettings()))
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("Failed to create peer connection: %v", err)
+	}
+	peer := &sfuTestPeer{id: id, roomID: roomID, pc: pc, sfu: sfu, inbox: make(chan Message, 128), done: make(chan struct{})}
+	t.Cleanup(func() {
+		close(peer.done)
+		pc.Close()
+	})
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			peer.signal("candidate", c.ToJSON())
+		}
+	})
+	if err := sfu.Join(roomID, id, func(msg Message) error {
+		select {
+		case peer.inbox <- msg:
+		case <-peer.done:
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to join SFU: %v", err)
+	}
+	go peer.run()
+	return peer
+}
+
+// signal sends a message from the test peer to the SFU
+func (p *sfuTestPeer) signal(msgType string, data interface{}) {
+	p.sfu.HandleMessage(p.roomID, p.id, Message{Type: msgType, RoomID: p.roomID, From: p.id, To: sfuPeerID, Data: data})
+}
+
+// run answers offers and applies candidates coming from the SFU until the test ends.
+// Failures surface as the forwarded track never arriving.
+func (p *sfuTestPeer) run() {
+	for {
+		var msg Message
+		select {
+		case <-p.done:
+			return
+		case msg = <-p.inbox:
+		}
+		switch msg.Type {
+		case "offer", "answer":
+			var sd webrtc.SessionDescription
+			if decodeMessageData(msg.Data, &sd) != nil || p.pc.SetRemoteDescription(sd) != nil {
+				continue
+			}
+			for _, c := range p.pending {
+				p.pc.AddICECandidate(c)
+			}
+			p.pending = nil
+			if msg.Type == "offer" {
+				answer, err := p.pc.CreateAnswer(nil)
+				if err != nil || p.pc.SetLocalDescription(answer) != nil {
+					continue
+				}
+				p.signal("answer", p.pc.LocalDescription())
+			}
+		case "candidate":
+			var c webrtc.ICECandidateInit
+			if decodeMessageData(msg.Data, &c) != nil {
+				continue
+			}
+			if p.pc.RemoteDescription() == nil {
+				p.pending = append(p.pending, c)
+				continue
+			}
+			p.pc.AddICECandidate(c)
+		}
+	}
+}
+
+// TestSFUForwardsTrack publishes a synthetic video track from one in-process peer
+// and checks that the SFU forwards it to another peer over loopback.
+func TestSFUForwardsTrack(t *testing.T) {
+	sfu, err := NewSFU(loopbackSettings(), webrtc.Configuration{})
+	if err != nil {
+		t.Fatalf("Failed to create SFU: %v", err)
+	}
+	const roomID = "chat_sfu_test"
+
+	subscriber := newSFUTestPeer(t, sfu, roomID, "subscriber")
+	received := make(chan *webrtc.TrackRemote, 1)
+	subscriber.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if _, _, err := track.ReadRTP(); err == nil {
+			select {
+			case received <- track:
+			default:
+			}
+		}
+	})
+
+	publisher := newSFUTestPeer(t, sfu, roomID, "publisher")
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "camera", "publisher-stream")
+	if err != nil {
+		t.Fatalf("Failed to create track: %v", err)
+	}
+	if _, err := publisher.pc.AddTrack(track); err != nil {
+		t.Fatalf("Failed to add track: %v", err)
+	}
+	offer, err := publisher.pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatalf("Failed to create offer: %v", err)
+	}
+	if err := publisher.pc.SetLocalDescription(offer); err != nil {
+		t.Fatalf("Failed to set offer: %v", err)
+	}
+	publisher.signal("offer", publisher.pc.LocalDescription())
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		frame := []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0xde, 0xad, 0xbe, 0xef}
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				track.WriteSample(media.Sample{Data: frame, Duration: 20 * time.Millisecond})
+			}
+		}
+	}()
+
+	select {
+	case remote := <-received:
+		if remote.Kind() != webrtc.RTPCodecTypeVideo {
+			t.Errorf("Expected video track, got %s", remote.Kind())
+		}
+		if remote.StreamID() != "publisher" {
+			t.Errorf("Expected forwarded stream to be labelled with publisher ID, got %q", remote.StreamID())
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("Timed out waiting for forwarded track")
+	}
+
+	sfu.Leave(roomID, "publisher")
+	if peer := sfu.peer(roomID, "publisher"); peer != nil {
+		t.Error("Expected publisher to be removed from the room")
+	}
+	sfu.mu.Lock()
+	room := sfu.rooms[roomID]
+	sfu.mu.Unlock()
+	if room == nil {
+		t.Fatal("Expected room to remain while the subscriber is connected")
+	}
+	room.mu.Lock()
+	remaining := len(room.tracks)
+	room.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("Expected publisher tracks to be removed, %d remain", remaining)
+	}
+
+	sfu.Leave(roomID, "subscriber")
+	sfu.mu.Lock()
+	_, exists := sfu.rooms[roomID]
+	sfu.mu.Unlock()
+	if exists {
+		t.Error("Expected empty room to be removed")
+	}
+}
+
+// wsPair returns the server and client ends of a websocket connection
+func wsPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := VideoUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		serverConns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return <-serverConns, client
+}
+
+// readMessage reads the next signaling message from a client connection
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+// TestRoomResume tests that a reconnecting client takes over its identity within the grace window
+func TestRoomResume(t *testing.T) {
+	t.Setenv("VIDEO_RESUME_GRACE_SECONDS", "60")
+
+	room := &Room{
+		ID:      "lobby",
+		Mode:    RoomModeMesh,
+		Clients: make(map[string]*Client),
+		expired: make(chan graceExpiry),
+		done:    make(chan struct{}),
+	}
+	defer close(room.done)
+
+	aliceServer, _ := wsPair(t)
+	bobServer, bobClient := wsPair(t)
+	alice := &Client{ID: "alice", UserID: 1, Conn: aliceServer, resumeToken: newResumeToken()}
+	bob := &Client{ID: "bob", UserID: 2, Conn: bobServer, resumeToken: newResumeToken()}
+	room.Clients[alice.ID] = alice
+	room.Clients[bob.ID] = bob
+
+	room.suspend(alice)
+	defer alice.graceTimer.Stop()
+	if alice.Conn != nil || alice.disconnectedAt.IsZero() {
+		t.Fatal("expected alice to be disconnected")
+	}
+	if _, ok := room.Clients[alice.ID]; !ok {
+		t.Fatal("expected alice to stay in the room during the grace window")
+	}
+	if msg := readMessage(t, bobClient); msg.Type != "peer_disconnected" || msg.From != "alice" {
+		t.Errorf("expected peer_disconnected from alice, got %s from %s", msg.Type, msg.From)
+	}
+
+	if room.resumable("", alice.UserID) != nil {
+		t.Error("expected an empty token not to resume")
+	}
+	if room.resumable(alice.resumeToken, bob.UserID) != nil {
+		t.Error("expected another user's token not to resume")
+	}
+	if room.resumable(alice.resumeToken, alice.UserID) != alice {
+		t.Fatal("expected alice's token to resume alice")
+	}
+
+	oldToken := alice.resumeToken
+	newServer, newClient := wsPair(t)
+	room.resume(alice, newServer)
+
+	joined := readMessage(t, newClient)
+	data, _ := joined.Data.(map[string]interface{})
+	if joined.Type != "joined" || data["clientId"] != "alice" || data["resumed"] != true {
+		t.Errorf("expected resumed joined message for alice, got %+v", joined)
+	}
+	if token, _ := data["resumeToken"].(string); token == "" || token == oldToken {
+		t.Error("expected a fresh resume token")
+	}
+	if msg := readMessage(t, bobClient); msg.Type != "peer_reconnected" || msg.From != "alice" {
+		t.Errorf("expected peer_reconnected from alice, got %s from %s", msg.Type, msg.From)
+	}
+	if room.resumable(oldToken, alice.UserID) != nil {
+		t.Error("expected the old token to be spent")
+	}
+}
+
+// TestWhiteboardApply tests sequencing, validation and clearing of whiteboard operations
+func TestWhiteboardApply(t *testing.T) {
+	board := &Whiteboard{}
+
+	stroke, err := board.Apply(WhiteboardOp{Op: WhiteboardStroke, ClientSeq: 7, Points: [][2]float64{{10, 10}, {2000, -5}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stroke.Seq != 1 || stroke.ClientSeq != 7 || stroke.Color != "#000000" || stroke.Width != 2 {
+		t.Errorf("unexpected normalized stroke: %+v", stroke)
+	}
+	if stroke.Points[1] != [2]float64{WhiteboardWidth, 0} {
+		t.Errorf("expected points clamped to the canvas, got %v", stroke.Points[1])
+	}
+
+	invalid := []WhiteboardOp{
+		{Op: "erase"},
+		{Op: WhiteboardStroke},
+		{Op: WhiteboardShape, Shape: "star"},
+		{Op: WhiteboardText, Text: "   "},
+		{Op: WhiteboardText, Text: "hi", Color: `red" onload="alert(1)`},
+	}
+	for _, op := range invalid {
+		if _, err := board.Apply(op); err == nil {
+			t.Errorf("expected %+v to be rejected", op)
+		}
+	}
+	if board.Seq != 1 {
+		t.Errorf("expected rejected operations not to consume sequence numbers, seq = %d", board.Seq)
+	}
+
+	board.Apply(WhiteboardOp{Op: WhiteboardText, Text: "SELECT 1", X: 100, Y: 100})
+	if ops, reset := board.Since(1); reset || len(ops) != 1 || ops[0].Seq != 2 {
+		t.Errorf("expected one operation after seq 1, got %d (reset %v)", len(ops), reset)
+	}
+
+	board.Apply(WhiteboardOp{Op: WhiteboardClear})
+	board.Apply(WhiteboardOp{Op: WhiteboardShape, Shape: ShapeRect, X: 10, Y: 10, W: 50, H: 50})
+	if len(board.Ops) != 1 || board.ClearedAt != 3 {
+		t.Errorf("expected clear to drop earlier operations, got %d ops", len(board.Ops))
+	}
+	if ops, reset := board.Since(2); !reset || len(ops) != 1 {
+		t.Errorf("expected a client from before the clear to get a reset, got %d ops (reset %v)", len(ops), reset)
+	}
+	if ops, reset := board.Since(4); reset || len(ops) != 0 {
+		t.Errorf("expected an up-to-date client to get nothing, got %d ops (reset %v)", len(ops), reset)
+	}
+}
+
+// TestWhiteboardRenderSVG tests exporting a board as SVG
+func TestWhiteboardRenderSVG(t *testing.T) {
+	board := &Whiteboard{}
+	board.Apply(WhiteboardOp{Op: WhiteboardStroke, Color: "#ff0000", Points: [][2]float64{{1, 2}, {3.5, 4}}})
+	board.Apply(WhiteboardOp{Op: WhiteboardShape, Shape: ShapeRect, X: 100, Y: 100, W: -50, H: 20, Fill: "#00ff00"})
+	board.Apply(WhiteboardOp{Op: WhiteboardShape, Shape: ShapeEllipse, X: 0, Y: 0, W: 20, H: 10})
+	board.Apply(WhiteboardOp{Op: WhiteboardText, Text: "<script>a & b</script>", X: 5, Y: 30})
+
+	svg := board.RenderSVG()
+	expected := []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="1600" height="900"`,
+		`<polyline points="1,2 3.5,4" fill="none" stroke="#ff0000"`,
+		`<rect x="50" y="100" width="50" height="20" stroke="#000000" stroke-width="2" fill="#00ff00"/>`,
+		`<ellipse cx="10" cy="5" rx="10" ry="5"`,
+		`&lt;script&gt;a &amp; b&lt;/script&gt;</text>`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(svg, e) {
+			t.Errorf("expected SVG to contain %q, got:\n%s", e, svg)
+		}
+	}
+	if strings.Contains(svg, "<script>") {
+		t.Error("expected text to be escaped")
+	}
+}
+
+// TestQualityReportValidate tests which call quality reports are accepted
+func TestQualityReportValidate(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name   string
+		report QualityReport
+		valid  bool
+	}{
+		{"full report", QualityReport{RTTMs: f(42), PacketLoss: f(0.01), JitterMs: f(3), InboundKbps: f(900), OutboundKbps: f(1200), LocalCandidateType: "srflx", RemoteCandidateType: "relay"}, true},
+		{"rtt only", QualityReport{RTTMs: f(120)}, true},
+		{"candidate types only", QualityReport{LocalCandidateType: "host", RemoteCandidateType: "host"}, true},
+		{"empty", QualityReport{}, false},
+		{"negative jitter", QualityReport{JitterMs: f(-1)}, false},
+		{"loss as percent", QualityReport{PacketLoss: f(5)}, false},
+		{"unknown candidate", QualityReport{RTTMs: f(10), LocalCandidateType: "turn"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.report.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, expected valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+// TestRecordQualityQueuesReports tests that socket reports are queued rather than stored by the room
+func TestRecordQualityQueuesReports(t *testing.T) {
+	client := &Client{ID: "peer-1", UserID: 3}
+	room := &Room{ID: "chat_9", Clients: map[string]*Client{client.ID: client}, session: &CallSession{ID: 12}}
+	stats := Message{Type: "stats", From: client.ID, Data: map[string]interface{}{"rttMs": 80, "remoteCandidateType": "relay"}}
+
+	room.recordQuality(stats)
+	select {
+	case q := <-qualityQueue:
+		if q.sessionID != 12 || q.userID != 3 || q.report.RTTMs == nil || *q.report.RTTMs != 80 {
+			t.Errorf("Unexpected queued report %+v", q)
+		}
+	default:
+		t.Fatal("Expected the report to be queued")
+	}
+
+	// A full queue drops the report instead of blocking the room
+	for i := 0; i < qualityQueueSize; i++ {
+		qualityQueue <- queuedQualityReport{}
+	}
+	client.lastQualityReport = time.Time{}
+	done := make(chan struct{})
+	go func() {
+		room.recordQuality(stats)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("recordQuality blocked on a full queue")
+	}
+	for len(qualityQueue) > 0 {
+		<-qualityQueue
+	}
+}
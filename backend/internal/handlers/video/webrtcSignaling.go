@@ -1,12 +1,18 @@
 package video
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"skillswap/backend/internal/config"
+	"skillswap/backend/internal/database"
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/handlers/swaps"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -37,19 +43,72 @@ type Message struct {
 	Data   interface{} `json:"data"`   // Message payload (SDP, ICE candidates, etc.)
 }
 
+// Room modes: mesh relays signaling between peers, sfu routes media through the server.
+const (
+	RoomModeMesh = "mesh"
+	RoomModeSFU  = "sfu"
+)
+
 // Room represents a signaling room for peer-to-peer WebRTC connections
 type Room struct {
 	ID         string
-	Clients    map[string]*Client // map of client ID to Client
+	Mode       string             // RoomModeMesh or RoomModeSFU, fixed when the room is created
+	Clients    map[string]*Client // map of client ID to Client, including clients inside their grace window
 	Register   chan *Client
-	Unregister chan *Client
+	Unregister chan Departure
 	Broadcast  chan Message
+	session    *CallSession     // call tracking, started when Run starts
+	board      *Whiteboard      // shared whiteboard, created on first use
+	expired    chan graceExpiry // grace windows that ran out
+	records    chan func()      // call record writes waiting for storeRecords
+	done       chan struct{}    // closed when Run returns
+}
+
+// roomRecordQueueSize is how many call record writes a room may have waiting before new ones are dropped
+const roomRecordQueueSize = 64
+
+// Departure reports that one websocket connection of a client closed.
+type Departure struct {
+	Client *Client
+	Conn   *websocket.Conn
 }
 
-// Client represents a connected peer in a signaling room.
+// Client represents a peer in a signaling room. Its connection is replaced when it resumes.
 type Client struct {
-	ID   string
-	Conn *websocket.Conn
+	ID             string
+	UserID         int
+	Conn           *websocket.Conn // nil while disconnected and waiting to be resumed
+	participantID  int64           // call_session_participants row for this client
+	writeMu        sync.Mutex      // websocket connections support one concurrent writer
+	resumeToken    string          // secret a reconnecting client presents to take over this identity
+	resumeRequest  string          // token presented when connecting, matched by the room
+	assigned       chan *Client    // receives the client the connection was attached to
+	disconnectedAt time.Time       // zero while connected
+	graceTimer     *time.Timer
+	// last quality report accepted over the socket
+	lastQualityReport time.Time
+}
+
+var errClientDisconnected = errors.New("client is disconnected")
+
+// WriteJSON sends a message to the client, serializing writes from the room and the SFU.
+func (c *Client) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.Conn == nil {
+		return errClientDisconnected
+	}
+	return c.Conn.WriteJSON(v)
+}
+
+// writePing sends a websocket ping to the client.
+func (c *Client) writePing() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.Conn == nil {
+		return errClientDisconnected
+	}
+	return c.Conn.WriteMessage(websocket.PingMessage, nil)
 }
 
 var Rooms = make(map[string]*Room)
@@ -71,34 +130,44 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userID, err := auth.GetSessionUserID(r)
+	if err != nil {
+		log.Println("Signaling: unauthenticated connection:", err)
+		conn.Close()
+		return
+	}
+
+	// Rooms tied to a chat are only open to that chat's members
+	if chatID := chatIDFromRoom(roomID); chatID > 0 && !isChatMember(chatID, userID) {
+		log.Printf("Signaling: user %d is not a member of room %s", userID, roomID)
+		conn.Close()
+		return
+	}
+
 	// Create a unique client ID for this connection
 	clientID := conn.RemoteAddr().String() + "_" + r.Header.Get("Sec-WebSocket-Key")
 
-	// Get or create room
-	RoomsMutex.Lock()
-	room, exists := Rooms[roomID]
-	if !exists {
-		room = &Room{
-			ID:         roomID,
-			Clients:    make(map[string]*Client),
-			Register:   make(chan *Client),
-			Unregister: make(chan *Client),
-			Broadcast:  make(chan Message),
-		}
-		Rooms[roomID] = room
-		go room.Run()
+	client := &Client{
+		ID:            clientID,
+		UserID:        int(userID),
+		Conn:          conn,
+		resumeRequest: r.URL.Query().Get("resume"),
+		assigned:      make(chan *Client, 1),
 	}
-	RoomsMutex.Unlock()
-
-	client := &Client{ID: clientID, Conn: conn}
 
-	// Register client in room
-	room.Register <- client
+	// Register client in room. A valid resume token attaches the connection to the
+	// client it replaces, so from here on the connection speaks as that client.
+	room := joinRoom(roomID, r, client)
+	client = <-client.assigned
+	clientID = client.ID
 
 	// Handle incoming messages
 	go func() {
 		defer func() {
-			room.Unregister <- client
+			select {
+			case room.Unregister <- Departure{Client: client, Conn: conn}:
+			case <-room.done:
+			}
 		}()
 
 		for {
@@ -115,67 +184,382 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 			msg.RoomID = roomID
 			msg.From = clientID
 
-			// Broadcast message to other clients in the room
-			room.Broadcast <- msg
+			// In SFU mode the server is the other end of every peer connection
+			if room.Mode == RoomModeSFU && getSFU().SupportsMessage(msg.Type) {
+				if err := getSFU().HandleMessage(roomID, clientID, msg); err != nil {
+					log.Printf("SFU: %s from %s failed: %v", msg.Type, clientID, err)
+				}
+				continue
+			}
+
+			// Broadcast message to other clients in the room, unless it has shut down
+			select {
+			case room.Broadcast <- msg:
+			case <-room.done:
+				return
+			}
 		}
 	}()
 }
 
+// joinRoom registers the client with the signaling room, creating the room if needed.
+// It retries if the room it found shut down before accepting the client.
+func joinRoom(roomID string, r *http.Request, client *Client) *Room {
+	for {
+		RoomsMutex.Lock()
+		room, exists := Rooms[roomID]
+		if !exists {
+			room = &Room{
+				ID:         roomID,
+				Mode:       roomMode(r),
+				Clients:    make(map[string]*Client),
+				Register:   make(chan *Client),
+				Unregister: make(chan Departure),
+				Broadcast:  make(chan Message),
+				expired:    make(chan graceExpiry),
+				records:    make(chan func(), roomRecordQueueSize),
+				done:       make(chan struct{}),
+			}
+			Rooms[roomID] = room
+			go room.Run()
+		}
+		RoomsMutex.Unlock()
+
+		select {
+		case room.Register <- client:
+			return room
+		case <-room.done:
+		}
+	}
+}
+
 // Run handles the room's message broadcasting and client management.
 func (room *Room) Run() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	// The session is stored before the first client is served, because the joined message
+	// carries its ID. Everything else the room stores is written by storeRecords.
+	room.startSession(time.Now())
+	go room.storeRecords()
+
 	for {
 		select {
 		case client := <-room.Register:
+			if previous := room.resumable(client.resumeRequest, client.UserID); previous != nil {
+				room.resume(previous, client.Conn)
+				client.assigned <- previous
+				log.Printf("Client %s resumed in room %s", previous.ID, room.ID)
+				continue
+			}
+			client.resumeToken = newResumeToken()
 			room.Clients[client.ID] = client
+			room.trackJoin(client)
+			client.assigned <- client
+			room.welcome(client, false)
 			log.Printf("Client %s connected to room %s. Total clients: %d", client.ID, room.ID, len(room.Clients))
 
-		case client := <-room.Unregister:
-			if _, ok := room.Clients[client.ID]; ok {
-				delete(room.Clients, client.ID)
-				client.Conn.Close()
-				log.Printf("Client %s disconnected from room %s. Total clients: %d", client.ID, room.ID, len(room.Clients))
+		case departure := <-room.Unregister:
+			client, ok := room.Clients[departure.Client.ID]
+			// Ignore connections that were already replaced by a resume or dropped by the room
+			if !ok || client != departure.Client || client.Conn != departure.Conn {
+				continue
 			}
+			room.suspend(client)
+			log.Printf("Client %s disconnected from room %s. Total clients: %d", client.ID, room.ID, len(room.Clients))
 
 			// Clean up empty rooms
 			if len(room.Clients) == 0 {
-				RoomsMutex.Lock()
-				delete(Rooms, room.ID)
-				RoomsMutex.Unlock()
+				room.close()
+				return
+			}
+
+		case expiry := <-room.expired:
+			client, ok := room.Clients[expiry.client.ID]
+			if !ok || client != expiry.client || !client.disconnectedAt.Equal(expiry.since) {
+				continue
+			}
+			room.removeClient(client)
+			room.notifyPeers(client.ID, "peer_left")
+			log.Printf("Client %s did not resume in room %s. Total clients: %d", client.ID, room.ID, len(room.Clients))
+
+			if len(room.Clients) == 0 {
+				room.close()
 				return
 			}
 
 		case message := <-room.Broadcast:
-			senderID := message.From
-			log.Printf("Signaling: Broadcasting %s from %s to others in room %s", message.Type, senderID, room.ID)
-			for clientID, client := range room.Clients {
-				if clientID != senderID {
-					if err := client.Conn.WriteJSON(message); err != nil {
-						log.Printf("Signaling: Write error to %s: %v", clientID, err)
-						client.Conn.Close()
-						delete(room.Clients, clientID)
+			if isWhiteboardMessage(message.Type) {
+				room.handleWhiteboard(message)
+			} else if message.Type == "stats" {
+				room.recordQuality(message)
+			} else {
+				senderID := message.From
+				log.Printf("Signaling: Broadcasting %s from %s to others in room %s", message.Type, senderID, room.ID)
+				for clientID, client := range room.Clients {
+					if clientID != senderID {
+						room.send(client, message)
 					}
 				}
 			}
+			if len(room.Clients) == 0 {
+				room.close()
+				return
+			}
 
 		case <-ticker.C:
 			// Heartbeat: ping all clients to detect dead connections
 			for clientID, client := range room.Clients {
-				if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				if client.Conn == nil {
+					continue
+				}
+				if err := client.writePing(); err != nil {
 					log.Printf("Signaling: Ping failed for %s: %v", clientID, err)
-					client.Conn.Close()
-					delete(room.Clients, clientID)
+					room.suspend(client)
 				}
 			}
 			// Clean up if room went empty during ping
 			if len(room.Clients) == 0 {
-				RoomsMutex.Lock()
-				delete(Rooms, room.ID)
-				RoomsMutex.Unlock()
+				room.close()
 				return
 			}
+			room.checkFulfillment(time.Now())
+			room.persistWhiteboard()
+		}
+	}
+}
+
+// send writes a message to a connected client, suspending it if the write fails.
+// Messages to clients inside their grace window are dropped; they renegotiate on resume.
+func (room *Room) send(client *Client, message Message) {
+	if client.Conn == nil {
+		return
+	}
+	if err := client.WriteJSON(message); err != nil {
+		log.Printf("Signaling: Write error to %s: %v", client.ID, err)
+		room.suspend(client)
+	}
+}
+
+// notifyPeers tells every other client in the room about a change in a client's connection.
+func (room *Room) notifyPeers(clientID, event string) {
+	message := Message{
+		Type:   event,
+		RoomID: room.ID,
+		From:   clientID,
+		Data:   map[string]interface{}{"clientId": clientID},
+	}
+	for id, client := range room.Clients {
+		if id != clientID {
+			room.send(client, message)
 		}
 	}
 }
+
+// removeClient drops a client from the room, closes its connection and records it leaving the call.
+func (room *Room) removeClient(client *Client) {
+	delete(room.Clients, client.ID)
+	if client.graceTimer != nil {
+		client.graceTimer.Stop()
+		client.graceTimer = nil
+	}
+	if room.Mode == RoomModeSFU {
+		getSFU().Leave(room.ID, client.ID)
+	}
+	room.trackLeave(client)
+	client.detach(time.Now())
+}
+
+// welcome tells a client its ID, the room mode and its resume token, and attaches it to the SFU
+// in SFU mode. A resumed client keeps its SFU peer connection and is expected to ICE-restart it.
+func (room *Room) welcome(client *Client, resumed bool) {
+	if room.Mode == RoomModeSFU && !resumed {
+		send := func(msg Message) error { return client.WriteJSON(msg) }
+		if err := getSFU().Join(room.ID, client.ID, send); err != nil {
+			log.Printf("SFU: %s failed to join room %s: %v", client.ID, room.ID, err)
+		}
+	}
+	joined := Message{
+		Type:   "joined",
+		RoomID: room.ID,
+		To:     client.ID,
+		Data: map[string]interface{}{
+			"clientId":           client.ID,
+			"mode":               room.Mode,
+			"resumeToken":        client.resumeToken,
+			"resumed":            resumed,
+			"resumeGraceSeconds": int(config.VideoResumeGrace().Seconds()),
+			"sessionId":          room.sessionID(),
+		},
+	}
+	room.send(client, joined)
+	// Late joiners and resumed clients catch up on the whiteboard
+	room.sendWhiteboard(client, -1)
+}
+
+// sessionID returns the ID of the room's stored call session, or 0 if it was not stored.
+func (room *Room) sessionID() int64 {
+	if room.session == nil {
+		return 0
+	}
+	return room.session.ID
+}
+
+// roomMode picks the mode for a new room from the "mode" query parameter or the VIDEO_MODE setting.
+// SFU mode falls back to mesh if the SFU could not be initialized.
+func roomMode(r *http.Request) string {
+	mode := r.URL.Query().Get("mode")
+	if mode != RoomModeSFU && mode != RoomModeMesh {
+		mode = RoomModeMesh
+		if config.VideoSFUEnabled() {
+			mode = RoomModeSFU
+		}
+	}
+	if mode == RoomModeSFU && getSFU() == nil {
+		return RoomModeMesh
+	}
+	return mode
+}
+
+// close ends the room's call session and removes the room from the registry.
+func (room *Room) close() {
+	room.persistWhiteboard()
+	if room.session != nil && room.session.ID > 0 {
+		session, endedAt := room.session, time.Now()
+		overlap := session.Overlap(endedAt)
+		room.record(func() {
+			if err := endCallSession(session, endedAt, overlap); err != nil {
+				log.Printf("Signaling: Failed to end call session for room %s: %v", room.ID, err)
+			}
+		})
+	}
+	RoomsMutex.Lock()
+	delete(Rooms, room.ID)
+	RoomsMutex.Unlock()
+	close(room.records)
+	close(room.done)
+}
+
+// record queues a call record write for storeRecords, so the room never waits on the database.
+// Writes are dropped while the queue is full.
+func (room *Room) record(write func()) {
+	select {
+	case room.records <- write:
+	default:
+		log.Printf("Signaling: Record queue for room %s is full, dropping a write", room.ID)
+	}
+}
+
+// storeRecords runs the room's queued call record writes in order until the room closes
+func (room *Room) storeRecords() {
+	for write := range room.records {
+		write()
+	}
+}
+
+// startSession starts tracking the room's call and stores the session
+func (room *Room) startSession(now time.Time) {
+	room.session = newCallSession(room.ID, now)
+	if err := insertCallSession(room.session); err != nil {
+		log.Printf("Signaling: Failed to record call session for room %s: %v", room.ID, err)
+	}
+}
+
+// trackJoin records a client joining the room's call session.
+func (room *Room) trackJoin(client *Client) {
+	if room.session == nil {
+		return
+	}
+	now := time.Now()
+	room.session.Join(client.UserID, now)
+	if room.session.ID == 0 {
+		return
+	}
+	// participantID is only used by record writes, which run in order
+	sessionID := room.session.ID
+	room.record(func() {
+		participantID, err := insertCallParticipant(sessionID, client.UserID, now)
+		if err != nil {
+			log.Printf("Signaling: Failed to record participant %d in room %s: %v", client.UserID, room.ID, err)
+			return
+		}
+		client.participantID = participantID
+	})
+}
+
+// trackLeave records a client leaving the room's call session. A client that never resumed
+// left when it disconnected, and its interval was already closed by suspend.
+func (room *Room) trackLeave(client *Client) {
+	if room.session == nil {
+		return
+	}
+	now := time.Now()
+	leftAt := now
+	if client.Conn == nil && !client.disconnectedAt.IsZero() {
+		leftAt = client.disconnectedAt
+	} else {
+		room.session.Leave(client.UserID, now)
+	}
+	room.record(func() {
+		if client.participantID == 0 {
+			return
+		}
+		if err := markCallParticipantLeft(client.participantID, leftAt); err != nil {
+			log.Printf("Signaling: Failed to record participant %d leaving room %s: %v", client.UserID, room.ID, err)
+		}
+	})
+	room.checkFulfillment(now)
+}
+
+// completeChatSwap settles the chat's accepted swap request and reports whether there was one
+var completeChatSwap = swaps.CompleteChatSwap
+
+// recordFulfillment stores that the session settled its swap after the given overlap
+var recordFulfillment = func(session *CallSession, overlap time.Duration) error {
+	if err := saveCallSessionOverlap(session, overlap); err != nil {
+		return err
+	}
+	return markCallSessionFulfilled(session.ID)
+}
+
+// checkFulfillment completes the chat's accepted swap request once both parties have shared
+// the call for at least the configured minimum duration. Until a request is settled it is
+// checked again on every heartbeat, so a request accepted during the call is still completed.
+// The swap is completed by the room's record writer.
+func (room *Room) checkFulfillment(now time.Time) {
+	session := room.session
+	if session == nil || session.ID == 0 || session.ChatID == 0 || session.Fulfilled() {
+		return
+	}
+	overlap := session.Overlap(now)
+	if overlap < config.MinCallDuration() {
+		return
+	}
+
+	room.record(func() {
+		// An earlier check may have settled it while this one waited
+		if session.Fulfilled() {
+			return
+		}
+		settled, err := completeChatSwap(session.ChatID)
+		if err != nil {
+			log.Printf("Signaling: Failed to complete swap for chat %d: %v", session.ChatID, err)
+			return
+		}
+		if !settled {
+			return
+		}
+		session.fulfilled.Store(true)
+		if err := recordFulfillment(session, overlap); err != nil {
+			log.Printf("Signaling: Failed to mark session %d fulfilled: %v", session.ID, err)
+		}
+		log.Printf("Signaling: Swap request in chat %d completed after %s", session.ChatID, overlap.Round(time.Second))
+	})
+}
+
+// isChatMember reports whether the user is one of the two participants of a chat
+func isChatMember(chatID int64, userID int64) bool {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM chats WHERE id = ? AND (user1_id = ? OR user2_id = ?)", chatID, userID, userID).Scan(&count)
+	return err == nil && count > 0
+}
@@ -0,0 +1,310 @@
+package video
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The whiteboard uses a fixed virtual canvas; clients scale it to their viewport.
+const (
+	WhiteboardWidth  = 1600
+	WhiteboardHeight = 900
+
+	maxWhiteboardOps    = 5000
+	maxWhiteboardPoints = 2000
+	maxWhiteboardText   = 500
+)
+
+// Whiteboard operations
+const (
+	WhiteboardStroke = "stroke"
+	WhiteboardShape  = "shape"
+	WhiteboardText   = "text"
+	WhiteboardClear  = "clear"
+)
+
+// Whiteboard shapes
+const (
+	ShapeRect    = "rect"
+	ShapeEllipse = "ellipse"
+	ShapeLine    = "line"
+)
+
+var whiteboardColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// WhiteboardOp is a single drawing operation. Seq is assigned by the server and orders every
+// operation on the board; ClientSeq is echoed back so the author can match its own operations.
+type WhiteboardOp struct {
+	Seq       int64        `json:"seq"`
+	ClientSeq int64        `json:"clientSeq,omitempty"`
+	Op        string       `json:"op"`
+	Author    string       `json:"author"`
+	UserID    int          `json:"userId"`
+	Color     string       `json:"color,omitempty"`
+	Fill      string       `json:"fill,omitempty"`
+	Width     float64      `json:"width,omitempty"`
+	Points    [][2]float64 `json:"points,omitempty"` // stroke
+	Shape     string       `json:"shape,omitempty"`  // rect, ellipse or line
+	X         float64      `json:"x,omitempty"`
+	Y         float64      `json:"y,omitempty"`
+	W         float64      `json:"w,omitempty"` // shape size; a line ends at (X+W, Y+H)
+	H         float64      `json:"h,omitempty"`
+	Text      string       `json:"text,omitempty"`
+	FontSize  float64      `json:"fontSize,omitempty"`
+}
+
+// Whiteboard is the authoritative board state of a room.
+// It is owned by the room's Run loop and is not safe for concurrent use.
+type Whiteboard struct {
+	Seq       int64          `json:"seq"`
+	ClearedAt int64          `json:"clearedAt"` // seq of the last clear
+	Ops       []WhiteboardOp `json:"ops"`       // operations since the last clear
+	dirty     bool           // changed since it was last persisted
+}
+
+var (
+	ErrWhiteboardFull = errors.New("whiteboard is full, clear it to keep drawing")
+	errInvalidOp      = errors.New("invalid whiteboard operation")
+)
+
+// Apply validates an operation, assigns it the next sequence number and adds it to the board.
+func (b *Whiteboard) Apply(op WhiteboardOp) (WhiteboardOp, error) {
+	if err := normalizeWhiteboardOp(&op); err != nil {
+		return op, err
+	}
+	if op.Op != WhiteboardClear && len(b.Ops) >= maxWhiteboardOps {
+		return op, ErrWhiteboardFull
+	}
+
+	b.Seq++
+	op.Seq = b.Seq
+	if op.Op == WhiteboardClear {
+		b.Ops = nil
+		b.ClearedAt = op.Seq
+	} else {
+		b.Ops = append(b.Ops, op)
+	}
+	b.dirty = true
+	return op, nil
+}
+
+// Since returns the operations after seq. If a clear happened after seq the client's
+// board is stale, so the whole board is returned and reset is true.
+func (b *Whiteboard) Since(seq int64) (ops []WhiteboardOp, reset bool) {
+	if seq < b.ClearedAt || seq > b.Seq {
+		return b.Ops, true
+	}
+	for i, op := range b.Ops {
+		if op.Seq > seq {
+			return b.Ops[i:], false
+		}
+	}
+	return nil, false
+}
+
+// normalizeWhiteboardOp checks an operation and fills in defaults
+func normalizeWhiteboardOp(op *WhiteboardOp) error {
+	switch op.Op {
+	case WhiteboardClear:
+		*op = WhiteboardOp{Op: WhiteboardClear, ClientSeq: op.ClientSeq, Author: op.Author, UserID: op.UserID}
+		return nil
+	case WhiteboardStroke:
+		if len(op.Points) == 0 || len(op.Points) > maxWhiteboardPoints {
+			return fmt.Errorf("%w: a stroke needs 1 to %d points", errInvalidOp, maxWhiteboardPoints)
+		}
+		for i := range op.Points {
+			op.Points[i][0] = clampCoord(op.Points[i][0], WhiteboardWidth)
+			op.Points[i][1] = clampCoord(op.Points[i][1], WhiteboardHeight)
+		}
+	case WhiteboardShape:
+		if op.Shape != ShapeRect && op.Shape != ShapeEllipse && op.Shape != ShapeLine {
+			return fmt.Errorf("%w: unknown shape %q", errInvalidOp, op.Shape)
+		}
+		op.X = clampCoord(op.X, WhiteboardWidth)
+		op.Y = clampCoord(op.Y, WhiteboardHeight)
+		op.W = clampCoord(op.X+op.W, WhiteboardWidth) - op.X
+		op.H = clampCoord(op.Y+op.H, WhiteboardHeight) - op.Y
+	case WhiteboardText:
+		op.Text = strings.TrimSpace(op.Text)
+		if op.Text == "" || len(op.Text) > maxWhiteboardText {
+			return fmt.Errorf("%w: text must be 1 to %d characters", errInvalidOp, maxWhiteboardText)
+		}
+		op.X = clampCoord(op.X, WhiteboardWidth)
+		op.Y = clampCoord(op.Y, WhiteboardHeight)
+		if op.FontSize <= 0 || op.FontSize > 200 {
+			op.FontSize = 24
+		}
+	default:
+		return fmt.Errorf("%w: unknown op %q", errInvalidOp, op.Op)
+	}
+
+	if op.Color == "" {
+		op.Color = "#000000"
+	}
+	if !whiteboardColor.MatchString(op.Color) || (op.Fill != "" && !whiteboardColor.MatchString(op.Fill)) {
+		return fmt.Errorf("%w: colors must be hex values like #1a2b3c", errInvalidOp)
+	}
+	if op.Width <= 0 || op.Width > 100 {
+		op.Width = 2
+	}
+	return nil
+}
+
+// clampCoord keeps a coordinate on the canvas
+func clampCoord(v, max float64) float64 {
+	if math.IsNaN(v) || v < 0 {
+		return 0
+	}
+	return math.Min(v, max)
+}
+
+// RenderSVG draws the board as a standalone SVG document
+func (b *Whiteboard) RenderSVG() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		WhiteboardWidth, WhiteboardHeight, WhiteboardWidth, WhiteboardHeight)
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="#ffffff"/>`)
+	sb.WriteString("\n")
+
+	for _, op := range b.Ops {
+		fill := op.Fill
+		if fill == "" {
+			fill = "none"
+		}
+		switch op.Op {
+		case WhiteboardStroke:
+			points := make([]string, len(op.Points))
+			for i, p := range op.Points {
+				points[i] = svgNum(p[0]) + "," + svgNum(p[1])
+			}
+			fmt.Fprintf(&sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>`,
+				strings.Join(points, " "), op.Color, svgNum(op.Width))
+		case WhiteboardShape:
+			x, y, w, h := op.X, op.Y, op.W, op.H
+			switch op.Shape {
+			case ShapeLine:
+				fmt.Fprintf(&sb, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s" stroke-linecap="round"/>`,
+					svgNum(x), svgNum(y), svgNum(x+w), svgNum(y+h), op.Color, svgNum(op.Width))
+			case ShapeEllipse:
+				fmt.Fprintf(&sb, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" stroke="%s" stroke-width="%s" fill="%s"/>`,
+					svgNum(x+w/2), svgNum(y+h/2), svgNum(math.Abs(w)/2), svgNum(math.Abs(h)/2), op.Color, svgNum(op.Width), fill)
+			default:
+				if w < 0 {
+					x, w = x+w, -w
+				}
+				if h < 0 {
+					y, h = y+h, -h
+				}
+				fmt.Fprintf(&sb, `<rect x="%s" y="%s" width="%s" height="%s" stroke="%s" stroke-width="%s" fill="%s"/>`,
+					svgNum(x), svgNum(y), svgNum(w), svgNum(h), op.Color, svgNum(op.Width), fill)
+			}
+		case WhiteboardText:
+			var text strings.Builder
+			xml.EscapeText(&text, []byte(op.Text))
+			fmt.Fprintf(&sb, `<text x="%s" y="%s" font-family="sans-serif" font-size="%s" fill="%s">%s</text>`,
+				svgNum(op.X), svgNum(op.Y), svgNum(op.FontSize), op.Color, text.String())
+		default:
+			continue
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+// svgNum formats a coordinate without trailing zeros
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// isWhiteboardMessage reports whether a signaling message belongs to the whiteboard sub-protocol
+func isWhiteboardMessage(msgType string) bool {
+	return msgType == "whiteboard" || msgType == "whiteboard_sync"
+}
+
+// handleWhiteboard applies a whiteboard operation and broadcasts it to everyone in the room,
+// including its author, or answers a sync request with the operations the client missed.
+func (room *Room) handleWhiteboard(message Message) {
+	client, ok := room.Clients[message.From]
+	if !ok {
+		return
+	}
+	if room.board == nil {
+		room.board = &Whiteboard{}
+	}
+
+	if message.Type == "whiteboard_sync" {
+		var req struct {
+			Since int64 `json:"since"`
+		}
+		if err := decodeMessageData(message.Data, &req); err != nil {
+			req.Since = -1
+		}
+		room.sendWhiteboard(client, req.Since)
+		return
+	}
+
+	var op WhiteboardOp
+	if err := decodeMessageData(message.Data, &op); err != nil {
+		op.Op = ""
+	}
+	op.Author = client.ID
+	op.UserID = client.UserID
+
+	applied, err := room.board.Apply(op)
+	if err != nil {
+		room.send(client, Message{
+			Type:   "whiteboard_error",
+			RoomID: room.ID,
+			To:     client.ID,
+			Data:   map[string]interface{}{"error": err.Error(), "clientSeq": op.ClientSeq},
+		})
+		return
+	}
+
+	update := Message{Type: "whiteboard", RoomID: room.ID, From: client.ID, Data: applied}
+	for _, c := range room.Clients {
+		room.send(c, update)
+	}
+}
+
+// sendWhiteboard sends a client the board operations after seq, or the whole board if it is stale.
+func (room *Room) sendWhiteboard(client *Client, since int64) {
+	if room.board == nil {
+		return
+	}
+	ops, reset := room.board.Since(since)
+	if ops == nil {
+		ops = []WhiteboardOp{}
+	}
+	room.send(client, Message{
+		Type:   "whiteboard_snapshot",
+		RoomID: room.ID,
+		To:     client.ID,
+		Data:   map[string]interface{}{"seq": room.board.Seq, "reset": reset, "ops": ops},
+	})
+}
+
+// persistWhiteboard queues a copy of the board to be stored with the room's call session if it changed.
+func (room *Room) persistWhiteboard() {
+	board := room.board
+	if board == nil || !board.dirty || room.session == nil || room.session.ID == 0 {
+		return
+	}
+	sessionID := room.session.ID
+	snapshot := &Whiteboard{Seq: board.Seq, ClearedAt: board.ClearedAt, Ops: append([]WhiteboardOp(nil), board.Ops...)}
+	room.record(func() {
+		if err := saveWhiteboard(sessionID, snapshot); err != nil {
+			log.Printf("Signaling: Failed to save whiteboard for room %s: %v", room.ID, err)
+		}
+	})
+	board.dirty = false
+}
@@ -0,0 +1,42 @@
+package video
+
+import (
+	"encoding/json"
+
+	"skillswap/backend/internal/database"
+)
+
+// saveWhiteboard stores the current state of a call session's whiteboard
+func saveWhiteboard(sessionID int64, board *Whiteboard) error {
+	ops, err := json.Marshal(board.Ops)
+	if err != nil {
+		return err
+	}
+	_, err = database.Execute(`
+		INSERT INTO call_whiteboards (session_id, ops, last_seq) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE ops = VALUES(ops), last_seq = VALUES(last_seq)`,
+		sessionID, string(ops), board.Seq,
+	)
+	return err
+}
+
+// fetchWhiteboard returns the stored whiteboard of a call session
+func fetchWhiteboard(sessionID int64) (*Whiteboard, error) {
+	var ops string
+	board := &Whiteboard{}
+	err := database.QueryRow("SELECT ops, last_seq FROM call_whiteboards WHERE session_id = ?", sessionID).Scan(&ops, &board.Seq)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(ops), &board.Ops); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// isCallParticipant reports whether the user took part in a call session
+func isCallParticipant(sessionID int64, userID int64) bool {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM call_session_participants WHERE session_id = ? AND user_id = ?", sessionID, userID).Scan(&count)
+	return err == nil && count > 0
+}
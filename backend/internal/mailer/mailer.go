@@ -0,0 +1,96 @@
+// Package mailer sends the application's outgoing mail through a pluggable transport.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+	"time"
+
+	"skillswap/backend/internal/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Default is the mailer the application sends through. Tests may replace it.
+var Default Mailer = FromConfig()
+
+// Send delivers a message through the default mailer
+func Send(msg Message) error {
+	return Default.Send(msg)
+}
+
+// FromConfig returns the mailer selected by MAIL_DRIVER. The server does not start with a
+// driver it does not know or, in production, with the log driver.
+func FromConfig() Mailer {
+	driver, err := config.MailDriver()
+	if err != nil {
+		log.Fatalf("Mail: %v", err)
+	}
+	switch driver {
+	case config.MailDriverSMTP:
+		smtp := config.SMTP()
+		return &SMTPMailer{
+			Host:     smtp.Host,
+			Port:     smtp.Port,
+			Username: smtp.Username,
+			Password: smtp.Password,
+			From:     config.MailFrom(),
+		}
+	case config.MailDriverFile:
+		return &FileMailer{Dir: config.MailDir(), From: config.MailFrom()}
+	default:
+		return &LogMailer{From: config.MailFrom()}
+	}
+}
+
+// LogMailer prints messages to the server log instead of sending them
+type LogMailer struct {
+	From string
+}
+
+// Send logs the message
+func (m *LogMailer) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	log.Printf("Mail: to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// format renders the message as an RFC 5322 email from the sender
+func format(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// validate rejects messages whose headers could inject further headers
+func validate(msg Message) error {
+	if msg.To == "" {
+		return fmt.Errorf("mail: no recipient")
+	}
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("mail: invalid header value")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "SkillSwap <no-reply@example.com>"}
+
+	err := m.Send(Message{To: "user@example.com", Subject: "Reset your password", Body: "Line one\nLine two"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one message file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	message := string(data)
+	for _, want := range []string{"To: user@example.com\r\n", "Subject: Reset your password\r\n", "\r\n\r\nLine one\r\nLine two"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected message to contain %q, got %q", want, message)
+		}
+	}
+}
+
+func TestMailersRejectHeaderInjection(t *testing.T) {
+	m := &FileMailer{Dir: t.TempDir(), From: "no-reply@example.com"}
+	msg := Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi", Body: "Body"}
+	if err := m.Send(msg); err == nil {
+		t.Error("Expected a recipient with a line break to be rejected")
+	}
+}
+
+func TestSMTPMailerTimesOut(t *testing.T) {
+	// A server that accepts the connection but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	m := &SMTPMailer{Host: "127.0.0.1", Port: addr.Port, From: "no-reply@example.com", Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	if err := m.Send(Message{To: "user@example.com", Subject: "Hi", Body: "Body"}); err == nil {
+		t.Fatal("Expected a hung server to fail the send")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the send to give up after the timeout, took %s", elapsed)
+	}
+}
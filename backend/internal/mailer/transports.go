@@ -0,0 +1,122 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"skillswap/backend/internal/config"
+)
+
+// SMTPMailer sends messages through an SMTP server. Without a username it sends
+// unauthenticated, which suits a local stand-in such as MailHog or Mailpit.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration // zero uses config.SMTPTimeout
+}
+
+// Send delivers the message to the SMTP server
+func (m *SMTPMailer) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("mail: invalid sender: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mail: invalid recipient: %w", err)
+	}
+
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = config.SMTPTimeout
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	// A hung server must not hold up the request that sends the mail
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	return m.deliver(c, from.Address, to.Address, format(m.From, msg, time.Now()))
+}
+
+// deliver sends one message over an open connection, as smtp.SendMail does
+func (m *SMTPMailer) deliver(c *smtp.Client, from, to string, body []byte) error {
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// FileMailer writes each message to its own .eml file in a directory
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes the message to the directory
+func (m *FileMailer) Send(msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg, now), 0o600)
+}
@@ -1,90 +1,93 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"skillswap/backend/internal/handlers/auth"
-	"skillswap/backend/internal/database"
 	"skillswap/backend/internal/utils"
+	"strings"
 )
 
-// AuthMiddleware checks if the user is authenticated before allowing access to protected routes
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get session from store
-		session, err := auth.Store.Get(r, "authentication")
-		if err != nil {
-			// utils.DebugPrint("AuthMiddleware: Failed to get session:", err)
-			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
-				"error":        "Authentication required",
-				"redirect":     "/auth/login",
-				"previousPath": r.URL.Path,
-			})
-			return
-		}
-
-		// Check if authenticated flag is set
-		authenticated, ok := session.Values["authenticated"].(bool)
-		if !ok || !authenticated {
-			// utils.DebugPrint("AuthMiddleware: User not authenticated")
-			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
-				"error":        "Authentication required",
-				"redirect":     "/auth/login",
-				"previousPath": r.URL.Path,
-			})
-			return
-		}
-
-		// Verify email exists in session
-		email, ok := session.Values["email"].(string)
-		if !ok || email == "" {
-			// utils.DebugPrint("AuthMiddleware: Invalid session - no email")
-			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
-				"error":        "Invalid session",
-				"redirect":     "/auth/login",
-				"previousPath": r.URL.Path,
-			})
-			return
-		}
+// authenticate resolves the request's principal, writing the error response and returning false if there is none
+func authenticate(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	p, err := auth.Authenticate(r)
+	switch {
+	case err == nil:
+		return p, true
+	case errors.Is(err, auth.ErrNotAuthenticated):
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"error":        "Authentication required",
+			"redirect":     "/auth/login",
+			"previousPath": r.URL.Path,
+		})
+	case errors.Is(err, auth.ErrInvalidSession):
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"error":        "Invalid session",
+			"redirect":     "/auth/login",
+			"previousPath": r.URL.Path,
+		})
+	case errors.Is(err, auth.ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, auth.ErrUserNotFound):
+		utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"error":        "User not found",
+			"redirect":     "/auth/login",
+			"previousPath": r.URL.Path,
+		})
+	default:
+		utils.HandleError(err)
+		utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
+			"error": "Failed to verify authentication",
+		})
+	}
+	return nil, false
+}
 
-		// Verify user exists in database
-		row, err := database.Query("SELECT id FROM users WHERE email = ?", email)
-		if err != nil {
-			utils.HandleError(err)
-			utils.SendJSONResponse(w, http.StatusInternalServerError, map[string]string{
-				"error": "Failed to verify authentication",
+// authorize checks that an API token has every scope the route needs, writing the error
+// response and returning false if not. Routes without scopes only accept sessions.
+func authorize(w http.ResponseWriter, p *auth.Principal, scopes []string) bool {
+	if !p.ViaToken() {
+		return true
+	}
+	if len(scopes) == 0 {
+		utils.SendJSONResponse(w, http.StatusForbidden, map[string]string{"error": auth.ErrSessionRequired.Error()})
+		return false
+	}
+	for _, scope := range scopes {
+		if !p.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+			utils.SendJSONResponse(w, http.StatusForbidden, map[string]interface{}{
+				"error":  auth.ErrInsufficientScope.Error(),
+				"scopes": scopes,
 			})
-			return
+			return false
 		}
-		defer row.Close()
+	}
+	return true
+}
 
-		if !row.Next() {
-			// utils.DebugPrint("AuthMiddleware: User not found in database")
-			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
-				"error":        "User not found",
-				"redirect":     "/auth/login",
-				"previousPath": r.URL.Path,
-			})
+// AuthMiddleware checks if the user is authenticated before allowing access to protected routes.
+// The resolved user is stored in the request context; handlers read it with auth.CurrentUser.
+// API tokens are accepted if they have all the scopes; without scopes the route needs a session.
+func AuthMiddleware(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := authenticate(w, r)
+		if !ok || !authorize(w, p, scopes) {
 			return
 		}
-
-		// User is authenticated, proceed to next handler
-		// utils.DebugPrint(fmt.Sprintf("AuthMiddleware: User %s authenticated successfully", email))
-		next(w, r)
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
 	}
 }
 
-// OptionalAuthMiddleware adds user information to request if authenticated, but doesn't block unauthenticated users
+// OptionalAuthMiddleware adds user information to request if authenticated, but doesn't block unauthenticated users.
+// API tokens are ignored since the route names no scopes.
 func OptionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Try to get session, but don't fail if it doesn't exist
-		session, err := auth.Store.Get(r, "authentication")
-		if err == nil {
-			if authenticated, ok := session.Values["authenticated"].(bool); ok && authenticated {
-				// utils.DebugPrint("OptionalAuthMiddleware: User authenticated")
-			}
+		if p, err := auth.Authenticate(r); err == nil && !p.ViaToken() {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), p))
 		}
 		// Always proceed to next handler
 		next(w, r)
 	}
 }
-
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"skillswap/backend/internal/handlers/auth"
+	"skillswap/backend/internal/utils"
+)
+
+// RequireVerifiedEmail only lets users with a confirmed email address through.
+// It must run inside AuthMiddleware, which resolves the user.
+func RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			utils.SendJSONResponse(w, http.StatusUnauthorized, map[string]interface{}{
+				"error":        "Authentication required",
+				"redirect":     "/auth/login",
+				"previousPath": r.URL.Path,
+			})
+			return
+		}
+		if !p.EmailVerified {
+			utils.SendJSONResponse(w, http.StatusForbidden, map[string]interface{}{
+				"error":        auth.ErrEmailNotVerified.Error(),
+				"redirect":     "/auth/verify-email",
+				"previousPath": r.URL.Path,
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// VerifiedMiddleware requires an authenticated user with a confirmed email address.
+// API tokens need the scopes, as with AuthMiddleware.
+func VerifiedMiddleware(next http.HandlerFunc, scopes ...string) http.HandlerFunc {
+	return AuthMiddleware(RequireVerifiedEmail(next), scopes...)
+}
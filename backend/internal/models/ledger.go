@@ -0,0 +1,16 @@
+package models
+
+// Reasons recorded with swap ledger entries
+const (
+	ReasonOpeningBalance   = "opening_balance"
+	ReasonSignupBonus      = "signup_bonus" // written by auth when an account is created
+	ReasonSwap             = "swap"
+	ReasonSwapPayment      = "swap_payment"   // recorded before swaps were paid through escrow
+	ReasonSwapFulfilled    = "swap_fulfilled" // recorded before swaps were paid through escrow
+	ReasonSwapRefund       = "swap_refund"    // recorded before swaps were paid through escrow
+	ReasonEscrowHold       = "escrow_hold"
+	ReasonEscrowRelease    = "escrow_release"
+	ReasonEscrowRefund     = "escrow_refund"
+	ReasonAdjustment       = "admin_adjustment"
+	ReasonMonthlyAllowance = "monthly_allowance" // the free credits granted once per calendar month
+)
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -53,8 +54,8 @@ func (fb FlexBool) MarshalJSON() ([]byte, error) {
 type UserInfo struct {
 	Username       string        `json:"username"`
 	Email          string        `json:"email"`
-	OldPassword    string        `json:"old_password"`
 	Password       string        `json:"password"`
+	NewPassword    string        `json:"new_password,omitempty"`
 	ID             int           `json:"id"`
 	ProfilePicture string        `json:"profile_picture"`
 	AboutMe        string        `json:"aboutme"`
@@ -62,10 +63,18 @@ type UserInfo struct {
 	Professions    string        `json:"profession"`
 	Contacts       []UserContact `json:"contacts"`
 	Skills         []UserSkill   `json:"skills"`
+	WantedSkills   []WantedSkill `json:"wanted_skills"`
+	Reputation     Reputation    `json:"reputation"`
 	Location       string        `json:"location"`
 	Joined         string        `json:"created_at"`
 }
 
+// Reputation aggregates the published reviews a user received from swap partners
+type Reputation struct {
+	Rating  float64 `json:"rating"`
+	Reviews int     `json:"reviews"`
+}
+
 type UserProject struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -73,8 +82,78 @@ type UserProject struct {
 }
 
 type UserSkill struct {
-	Name     string   `json:"name"`
-	Verified FlexBool `json:"verified"`
+	Name            string   `json:"name"`
+	Verified        FlexBool `json:"verified"`
+	TeachingStyle   string   `json:"teaching_style"`
+	Proficiency     string   `json:"proficiency"`
+	YearsExperience int      `json:"years_experience"`
+}
+
+// Teaching styles, as stored in user_skills.teaching_skill
+const (
+	StyleShowHow   = "Show how it's done"
+	StyleExplain   = "Explain in slight details"
+	StyleHomework  = "Give homework report on subject"
+	StyleProfessor = "Professor"
+	DefaultStyle   = StyleShowHow
+)
+
+// TeachingStyles lists the valid teaching styles
+var TeachingStyles = []string{StyleShowHow, StyleExplain, StyleHomework, StyleProfessor}
+
+// ProficiencyLevels lists the valid proficiency levels, lowest first
+var ProficiencyLevels = []string{"beginner", "intermediate", "advanced", "expert"}
+
+// DefaultProficiency is used when a skill is saved without a proficiency level
+const DefaultProficiency = "intermediate"
+
+// MaxYearsOfSkill is the most years of experience a skill can list
+const MaxYearsOfSkill = 80
+
+// ProficiencyRank returns the position of a level in ProficiencyLevels, or -1 if it is not valid
+func ProficiencyRank(level string) int {
+	for i, l := range ProficiencyLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidTeachingStyle reports whether style is one of TeachingStyles
+func ValidTeachingStyle(style string) bool {
+	for _, s := range TeachingStyles {
+		if s == style {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize fills in the default teaching style and proficiency of a skill
+// and checks its details are valid
+func (s *UserSkill) Normalize() error {
+	if s.TeachingStyle == "" {
+		s.TeachingStyle = DefaultStyle
+	}
+	if s.Proficiency == "" {
+		s.Proficiency = DefaultProficiency
+	}
+	if !ValidTeachingStyle(s.TeachingStyle) {
+		return fmt.Errorf("invalid teaching style %q for skill %s", s.TeachingStyle, s.Name)
+	}
+	if ProficiencyRank(s.Proficiency) < 0 {
+		return fmt.Errorf("invalid proficiency %q for skill %s", s.Proficiency, s.Name)
+	}
+	if s.YearsExperience < 0 || s.YearsExperience > MaxYearsOfSkill {
+		return fmt.Errorf("years of experience for skill %s must be between 0 and %d", s.Name, MaxYearsOfSkill)
+	}
+	return nil
+}
+
+// WantedSkill is a skill the user wants to learn
+type WantedSkill struct {
+	Name string `json:"name"`
 }
 
 type UserContact struct {
@@ -240,3 +240,39 @@ func TestFlexBoolComparison(t *testing.T) {
 		t.Error("FlexBool(false) should be falsy")
 	}
 }
+
+func TestUserSkillNormalize(t *testing.T) {
+	skill := UserSkill{Name: "Go"}
+	if err := skill.Normalize(); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if skill.TeachingStyle != DefaultStyle || skill.Proficiency != DefaultProficiency {
+		t.Errorf("Normalize() defaults got = %q/%q", skill.TeachingStyle, skill.Proficiency)
+	}
+
+	invalid := []UserSkill{
+		{Name: "Go", TeachingStyle: "Lecture"},
+		{Name: "Go", Proficiency: "guru"},
+		{Name: "Go", YearsExperience: -1},
+		{Name: "Go", YearsExperience: MaxYearsOfSkill + 1},
+	}
+	for _, s := range invalid {
+		if err := s.Normalize(); err == nil {
+			t.Errorf("Normalize() expected an error for %+v", s)
+		}
+	}
+}
+
+func TestUserSkillDetailsUnmarshal(t *testing.T) {
+	input := `{"name": "Go", "verified": 1, "teaching_style": "Professor", "proficiency": "expert", "years_experience": 7}`
+	var skill UserSkill
+	if err := json.Unmarshal([]byte(input), &skill); err != nil {
+		t.Fatalf("Unmarshal UserSkill error = %v", err)
+	}
+	if skill.TeachingStyle != StyleProfessor || skill.Proficiency != "expert" || skill.YearsExperience != 7 {
+		t.Errorf("Unmarshal UserSkill details got = %+v", skill)
+	}
+	if ProficiencyRank("expert") <= ProficiencyRank("beginner") {
+		t.Error("ProficiencyRank() should order levels from lowest to highest")
+	}
+}
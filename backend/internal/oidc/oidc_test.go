@@ -0,0 +1,107 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"skillswap/backend/internal/oidc/oidctest"
+)
+
+func newTestProvider(issuer *oidctest.Issuer) *Provider {
+	return &Provider{
+		Name:         "company",
+		Issuer:       issuer.URL(),
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		RedirectURL:  "https://skillswap.test/api/oidc/company/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	issuer := oidctest.NewIssuer("skillswap", "secret")
+	defer issuer.Close()
+	issuer.SignIn(oidctest.User{Subject: "u-1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"})
+	p := newTestProvider(issuer)
+	ctx := context.Background()
+
+	verifier, _ := RandomString()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	q, _ := url.Parse(authURL)
+	if q.Query().Get("code_challenge") != CodeChallenge(verifier) || q.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("Expected a S256 PKCE challenge in %s", authURL)
+	}
+
+	callback, err := issuer.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize failed: %v", err)
+	}
+	params, _ := url.Parse(callback)
+	if params.Query().Get("state") != "state-1" {
+		t.Errorf("Expected the state to come back, got %s", callback)
+	}
+	code := params.Query().Get("code")
+
+	if _, err := p.Exchange(ctx, code, "wrong-verifier", "nonce-1"); !errors.Is(err, ErrTokenExchange) {
+		t.Errorf("Expected a wrong verifier to be refused, got %v", err)
+	}
+
+	callback, _ = issuer.Authorize(authURL)
+	params, _ = url.Parse(callback)
+	claims, err := p.Exchange(ctx, params.Query().Get("code"), verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if claims.Subject != "u-1" || claims.Email != "ada@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := oidctest.NewIssuer("skillswap", "secret")
+	defer issuer.Close()
+	p := newTestProvider(issuer)
+	ctx := context.Background()
+	issued := time.Unix(1700000000, 0)
+	now = func() time.Time { return issued }
+	defer func() { now = time.Now }()
+
+	claims := func(change func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   issuer.URL(),
+			"sub":   "u-1",
+			"aud":   []string{"skillswap"},
+			"exp":   issued.Add(time.Hour).Unix(),
+			"iat":   issued.Unix(),
+			"nonce": "nonce-1",
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	if _, err := p.VerifyIDToken(ctx, issuer.IDToken(claims(nil)), "nonce-1"); err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+
+	tests := map[string]string{
+		"wrong issuer":   issuer.IDToken(claims(func(c map[string]interface{}) { c["iss"] = "https://evil.test" })),
+		"wrong audience": issuer.IDToken(claims(func(c map[string]interface{}) { c["aud"] = "other" })),
+		"no azp":         issuer.IDToken(claims(func(c map[string]interface{}) { c["aud"] = []string{"skillswap", "other"} })),
+		"expired":        issuer.IDToken(claims(func(c map[string]interface{}) { c["exp"] = issued.Add(-2 * time.Minute).Unix() })),
+		"wrong nonce":    issuer.IDToken(claims(func(c map[string]interface{}) { c["nonce"] = "replayed" })),
+		"tampered":       issuer.IDToken(claims(nil))[:40] + "x" + issuer.IDToken(claims(nil))[41:],
+		"unsigned":       "eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1LTEifQ.",
+	}
+	for name, token := range tests {
+		if _, err := p.VerifyIDToken(ctx, token, "nonce-1"); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("%s: expected ErrInvalidIDToken, got %v", name, err)
+		}
+	}
+}
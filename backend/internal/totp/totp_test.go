@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists eight digit codes; the six digit codes are their last six digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		code, err := Code(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("Code failed: %v", err)
+		}
+		if code != v.code {
+			t.Errorf("At %d expected %s, got %s", v.unix, v.code, code)
+		}
+	}
+}
+
+func TestValidateAllowsSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	previous, _ := Code(rfcSecret, now.Add(-Period))
+	stale, _ := Code(rfcSecret, now.Add(-3*Period))
+
+	step, ok := Validate(rfcSecret, previous, now, 1)
+	if !ok || step != Step(now)-1 {
+		t.Errorf("Expected the previous period's code to match step %d, got %d (%v)", Step(now)-1, step, ok)
+	}
+	if _, ok := Validate(rfcSecret, previous, now, 0); ok {
+		t.Error("Expected no skew to refuse the previous period's code")
+	}
+	if _, ok := Validate(rfcSecret, stale, now, 1); ok {
+		t.Error("Expected a code three periods old to be refused")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Error("Expected a short code to be refused")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("Expected a 32 character secret, got %q", secret)
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Errorf("Expected the generated secret to be usable, got %v", err)
+	}
+
+	uri := URI("SkillSwap", "user@example.com", secret)
+	for _, want := range []string{"otpauth://totp/SkillSwap:user@example.com?", "secret=" + secret, "issuer=SkillSwap", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("Expected %q in %q", want, uri)
+		}
+	}
+}